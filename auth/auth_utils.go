@@ -7,6 +7,16 @@ import (
 	"strconv"
 )
 
+// CredentialsError is returned when the FID or signer private key has not
+// been saved yet
+type CredentialsError struct {
+	Missing string
+}
+
+func (e *CredentialsError) Error() string {
+	return fmt.Sprintf("%s not found. Please set your %s first", e.Missing, e.Missing)
+}
+
 func SaveFidAndPrivateKey(fid uint64, privateKey string) error {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -40,7 +50,7 @@ func FindFidAndPrivateKey() (uint64, string, error) {
 	fidBytes, err := os.ReadFile(fidPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return 0, "", fmt.Errorf("FID not found. Please set your FID first")
+			return 0, "", &CredentialsError{Missing: "FID"}
 		} else {
 			return 0, "", err
 		}
@@ -54,7 +64,7 @@ func FindFidAndPrivateKey() (uint64, string, error) {
 	privateKey, err := os.ReadFile(privatePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return 0, "", fmt.Errorf("Private Key not found. Please set your Private Key first")
+			return 0, "", &CredentialsError{Missing: "Private Key"}
 		} else {
 			return 0, "", err
 		}
@@ -0,0 +1,51 @@
+package channels
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	idStyle          = lipgloss.NewStyle().Foreground(lipgloss.Color("#7C65C1")).Bold(true)
+	followerStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#767676"))
+	descriptionStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#767676"))
+)
+
+// SearchChannels prints up to limit channels from the directory matching
+// query, best matches first
+func SearchChannels(query string, limit int, refresh bool) error {
+	matches, err := Matches(query, limit, refresh)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range matches {
+		fmt.Printf("%s %s\n", idStyle.Render("/"+c.ID), followerStyle.Render(FormatFollowers(c.FollowerCount)+" followers"))
+		if description := Summary(c.Description, 76); description != "" {
+			fmt.Printf("  %s\n", descriptionStyle.Render(description))
+		}
+	}
+	return nil
+}
+
+// Refresh fetches the channel directory and replaces the cache
+func Refresh() error {
+	directory, err := Directory(true)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Cached %d channels\n", len(directory))
+	return nil
+}
+
+// Summary collapses whitespace in text and cuts it to width runes
+func Summary(text string, width int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) > width {
+		return string(runes[:width-1]) + "…"
+	}
+	return text
+}
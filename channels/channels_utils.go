@@ -0,0 +1,338 @@
+package channels
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+const defaultTTL = 24 * time.Hour
+
+// The Warpcast endpoints are variables so tests can point them at a local
+// server
+var (
+	directoryURL = "https://api.warpcast.com/v2/all-channels"
+	channelURL   = "https://api.warpcast.com/v1/channel"
+	membersURL   = "https://api.warpcast.com/fc/channel-members"
+)
+
+type Channel struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	ImageURL      string `json:"imageUrl"`
+	LeadFid       int    `json:"leadFid"`
+	ModeratorFids []int  `json:"moderatorFids"`
+	CreatedAt     int64  `json:"createdAt"`
+	FollowerCount int    `json:"followerCount"`
+	MemberCount   int    `json:"memberCount"`
+	PublicCasting bool   `json:"publicCasting"`
+}
+
+type directoryResponse struct {
+	Result struct {
+		Channels []Channel `json:"channels"`
+	} `json:"result"`
+}
+
+type channelResponse struct {
+	Result struct {
+		Channel Channel `json:"channel"`
+	} `json:"result"`
+}
+
+type cache struct {
+	FetchedAt time.Time `json:"fetchedAt"`
+	Channels  []Channel `json:"channels"`
+}
+
+func cachePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".fc-cast-channels.json"), nil
+}
+
+func readCache() (cache, error) {
+	var c cache
+	path, err := cachePath()
+	if err != nil {
+		return c, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("Failed to read channel cache %s: %v", path, err)
+	}
+	return c, nil
+}
+
+func writeCache(c cache) error {
+	path, err := cachePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+func fetchDirectory() ([]Channel, error) {
+	resp, err := http.Get(directoryURL)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch channel directory: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Channel directory fetch failed. HTTP status: %d", resp.StatusCode)
+	}
+
+	var response directoryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("Failed to decode channel directory: %v", err)
+	}
+	return response.Result.Channels, nil
+}
+
+func fetchChannel(channelID string) (Channel, error) {
+	resp, err := http.Get(channelURL + "?channelId=" + url.QueryEscape(channelID))
+	if err != nil {
+		return Channel{}, fmt.Errorf("Failed to send GET request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Channel{}, fmt.Errorf("Channel fetch failed for %q. HTTP status: %d", channelID, resp.StatusCode)
+	}
+
+	var response channelResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return Channel{}, fmt.Errorf("Failed to decode channel %q: %v", channelID, err)
+	}
+	if response.Result.Channel.URL == "" {
+		return Channel{}, errNotFound
+	}
+	return response.Result.Channel, nil
+}
+
+// Directory returns every known channel. The cached copy is used while it is
+// younger than the TTL preference; after that the directory is fetched again,
+// falling back to the stale cache when the fetch fails so lookups keep
+// working offline.
+func Directory(refresh bool) ([]Channel, error) {
+	cached, cacheErr := readCache()
+	if !refresh && cacheErr == nil && time.Since(cached.FetchedAt) < RetrieveTTLPreference() {
+		return cached.Channels, nil
+	}
+
+	channels, err := fetchDirectory()
+	if err != nil {
+		if cacheErr == nil && !refresh {
+			return cached.Channels, nil
+		}
+		return nil, err
+	}
+
+	if err := writeCache(cache{FetchedAt: time.Now(), Channels: channels}); err != nil {
+		return nil, fmt.Errorf("Failed to save channel cache: %v", err)
+	}
+	return channels, nil
+}
+
+// Cached returns the channels from the local cache without touching the
+// network, however old they are
+func Cached() ([]Channel, error) {
+	c, err := readCache()
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("The channel cache is empty, run mast channels refresh")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.Channels, nil
+}
+
+// Find returns the channel with the given id
+func Find(channels []Channel, channelID string) (Channel, bool) {
+	channelID = strings.ToLower(strings.TrimPrefix(channelID, "/"))
+	for _, c := range channels {
+		if c.ID == channelID {
+			return c, true
+		}
+	}
+	return Channel{}, false
+}
+
+// Matches returns up to limit channels from the directory matching query,
+// best matches first
+func Matches(query string, limit int, refresh bool) ([]Channel, error) {
+	directory, err := Directory(refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := Search(directory, query)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("No channels match %q", query)
+	}
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// Search fuzzy matches query against channel ids and names. Exact id matches
+// come first, an empty query returns every channel by follower count.
+func Search(channels []Channel, query string) []Channel {
+	query = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(query, "/")))
+	if query == "" {
+		sorted := append([]Channel(nil), channels...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].FollowerCount > sorted[j].FollowerCount
+		})
+		return sorted
+	}
+
+	targets := make([]string, len(channels))
+	for i, c := range channels {
+		targets[i] = strings.ToLower(c.ID + " " + c.Name)
+	}
+
+	var exact, fuzzy []Channel
+	for _, rank := range list.DefaultFilter(query, targets) {
+		c := channels[rank.Index]
+		if c.ID == query {
+			exact = append(exact, c)
+		} else {
+			fuzzy = append(fuzzy, c)
+		}
+	}
+	return append(exact, fuzzy...)
+}
+
+// FormatFollowers abbreviates follower counts as 950, 12.3k or 1.2m
+func FormatFollowers(n int) string {
+	switch {
+	case n >= 1000000:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1000000), ".0") + "m"
+	case n >= 1000:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1000), ".0") + "k"
+	}
+	return fmt.Sprintf("%d", n)
+}
+
+func SaveTTLPreference(ttl time.Duration) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+
+	ttlPath := filepath.Join(home, ".fc-cast-channels-ttl")
+	return os.WriteFile(ttlPath, []byte(ttl.String()), 0600)
+}
+
+// RetrieveTTLPreference returns how long the channel cache stays fresh,
+// defaulting to a day
+func RetrieveTTLPreference() time.Duration {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return defaultTTL
+	}
+
+	ttl, err := os.ReadFile(filepath.Join(homeDir, ".fc-cast-channels-ttl"))
+	if err != nil {
+		return defaultTTL
+	}
+
+	d, err := time.ParseDuration(strings.TrimSpace(string(ttl)))
+	if err != nil || d < 0 {
+		return defaultTTL
+	}
+	return d
+}
+
+type channelMember struct {
+	Fid       uint64 `json:"fid"`
+	ChannelID string `json:"channelId"`
+	MemberAt  int64  `json:"memberAt"`
+}
+
+type membersResponse struct {
+	Result struct {
+		Members []channelMember `json:"members"`
+	} `json:"result"`
+}
+
+// MembershipError is returned by CheckMembership when fid cannot cast in the
+// channel
+type MembershipError struct {
+	ChannelID string
+	Fid       uint64
+}
+
+func (e *MembershipError) Error() string {
+	return fmt.Sprintf("fid %d is not a member of /%s and the channel does not allow public casting, so the cast would not show up in it. Join the channel first, or pass --force to send anyway", e.Fid, e.ChannelID)
+}
+
+// IsMember asks Warpcast whether fid is a member of the channel
+func IsMember(channelID string, fid uint64) (bool, error) {
+	resp, err := http.Get(fmt.Sprintf("%s?channelId=%s&fid=%d", membersURL, url.QueryEscape(channelID), fid))
+	if err != nil {
+		return false, fmt.Errorf("Failed to send GET request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("Channel membership check failed. HTTP status: %d", resp.StatusCode)
+	}
+
+	var response membersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return false, fmt.Errorf("Failed to decode channel members: %v", err)
+	}
+	for _, member := range response.Result.Members {
+		if member.Fid == fid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CheckMembership returns a *MembershipError when fid is not allowed to cast
+// in channel, and other errors when that could not be determined
+func CheckMembership(channel Channel, fid uint64) error {
+	if channel.PublicCasting {
+		return nil
+	}
+	member, err := IsMember(channel.ID, fid)
+	if err != nil {
+		return err
+	}
+	if !member {
+		return &MembershipError{ChannelID: channel.ID, Fid: fid}
+	}
+	return nil
+}
+
+// FindByURL returns the channel in the directory whose parent URL is
+// parentURL
+func FindByURL(channels []Channel, parentURL string) (Channel, bool) {
+	for _, c := range channels {
+		if c.URL == parentURL {
+			return c, true
+		}
+	}
+	return Channel{}, false
+}
@@ -0,0 +1,138 @@
+package channels
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testChannels = []Channel{
+	{ID: "dev", Name: "Developers", URL: "chain://eip155:1/erc721:0x7dd4e31f1530ac682c8ea4d8016e95773e08d8b0", FollowerCount: 40000},
+	{ID: "farcaster", Name: "Farcaster", URL: "https://farcaster.xyz", FollowerCount: 90000},
+	{ID: "devops", Name: "DevOps", URL: "https://warpcast.com/~/channel/devops", FollowerCount: 900},
+}
+
+func serveDirectory(t *testing.T, channels []Channel) *int {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var response directoryResponse
+		response.Result.Channels = channels
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	previous := directoryURL
+	directoryURL = server.URL
+	t.Cleanup(func() { directoryURL = previous })
+	return &requests
+}
+
+func TestSearchRanksExactIDFirst(t *testing.T) {
+	matches := Search(testChannels, "dev")
+	if len(matches) != 2 || matches[0].ID != "dev" || matches[1].ID != "devops" {
+		t.Errorf("Search(dev) = %v", matches)
+	}
+
+	if matches := Search(testChannels, "/fcaster"); len(matches) != 1 || matches[0].ID != "farcaster" {
+		t.Errorf("Search(/fcaster) = %v", matches)
+	}
+
+	all := Search(testChannels, "")
+	if len(all) != 3 || all[0].ID != "farcaster" {
+		t.Errorf("empty Search should order by followers, got %v", all)
+	}
+}
+
+func TestDirectoryUsesCacheUntilTTL(t *testing.T) {
+	requests := serveDirectory(t, testChannels)
+
+	for i := 0; i < 2; i++ {
+		directory, err := Directory(false)
+		if err != nil || len(directory) != 3 {
+			t.Fatalf("Directory = %d channels, %v", len(directory), err)
+		}
+	}
+	if *requests != 1 {
+		t.Errorf("fresh cache was fetched %d times, want 1", *requests)
+	}
+
+	if err := SaveTTLPreference(time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Directory(false); err != nil {
+		t.Fatal(err)
+	}
+	if *requests != 2 {
+		t.Errorf("stale cache was not refreshed, %d requests", *requests)
+	}
+}
+
+func TestDirectoryWorksOfflineOnceWarm(t *testing.T) {
+	serveDirectory(t, testChannels)
+	if _, err := Directory(false); err != nil {
+		t.Fatal(err)
+	}
+
+	directoryURL = "http://127.0.0.1:1"
+	if err := SaveTTLPreference(time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+
+	directory, err := Directory(false)
+	if err != nil {
+		t.Fatalf("stale cache should be used offline: %v", err)
+	}
+	if c, ok := Find(directory, "/dev"); !ok || c.FollowerCount != 40000 {
+		t.Errorf("Find(/dev) = %v, %v", c, ok)
+	}
+
+	if _, err := Directory(true); err == nil {
+		t.Error("an explicit refresh should report the failed fetch")
+	}
+}
+
+func TestFormatFollowers(t *testing.T) {
+	for n, want := range map[int]string{950: "950", 1000: "1k", 12345: "12.3k", 1200000: "1.2m"} {
+		if got := FormatFollowers(n); got != want {
+			t.Errorf("FormatFollowers(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestCheckMembership(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var response membersResponse
+		if r.URL.Query().Get("channelId") == "dev" && r.URL.Query().Get("fid") == "6596" {
+			response.Result.Members = []channelMember{{Fid: 6596, ChannelID: "dev"}}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+	previous := membersURL
+	membersURL = server.URL
+	defer func() { membersURL = previous }()
+
+	if err := CheckMembership(Channel{ID: "dev"}, 6596); err != nil {
+		t.Errorf("member was refused: %v", err)
+	}
+
+	var membershipErr *MembershipError
+	if err := CheckMembership(Channel{ID: "dev"}, 3); !errors.As(err, &membershipErr) {
+		t.Errorf("non member error = %v", err)
+	}
+
+	if err := CheckMembership(Channel{ID: "farcaster", PublicCasting: true}, 3); err != nil {
+		t.Errorf("public channel was refused: %v", err)
+	}
+
+	membersURL = "http://127.0.0.1:1"
+	if err := CheckMembership(Channel{ID: "dev"}, 3); err == nil || errors.As(err, &membershipErr) {
+		t.Errorf("unreachable API should not look like a membership failure: %v", err)
+	}
+}
@@ -0,0 +1,305 @@
+package channels
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mast/hub"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var neynarURL = "https://api.neynar.com/v2/farcaster/channel"
+
+var defaultResolvers = []string{"file", "warpcast", "neynar"}
+
+var errNotFound = errors.New("channel not found")
+
+// Resolver turns a channel id into the channel, and with it the parent URL
+// casts in that channel use
+type Resolver interface {
+	Name() string
+	Resolve(channelID string) (Channel, error)
+}
+
+// WarpcastResolver looks channels up in the cached Warpcast directory. When
+// Offline is set it never fetches anything.
+type WarpcastResolver struct {
+	Offline bool
+}
+
+func (WarpcastResolver) Name() string { return "warpcast" }
+
+func (r WarpcastResolver) Resolve(channelID string) (Channel, error) {
+	if r.Offline {
+		directory, err := Cached()
+		if err != nil {
+			return Channel{}, err
+		}
+		if c, ok := Find(directory, channelID); ok {
+			return c, nil
+		}
+		return Channel{}, errNotFound
+	}
+
+	directory, err := Directory(false)
+	if err == nil {
+		if c, ok := Find(directory, channelID); ok {
+			return c, nil
+		}
+	}
+	// The channel may be newer than the cached directory
+	return fetchChannel(channelID)
+}
+
+// NeynarResolver asks the Neynar API, using the API key saved with the hub
+// preference
+type NeynarResolver struct {
+	APIKey string
+}
+
+type neynarChannelResponse struct {
+	Channel struct {
+		ID            string `json:"id"`
+		URL           string `json:"url"`
+		Name          string `json:"name"`
+		Description   string `json:"description"`
+		ImageURL      string `json:"image_url"`
+		FollowerCount int    `json:"follower_count"`
+		MemberCount   int    `json:"member_count"`
+		CreatedAt     int64  `json:"created_at"`
+		Lead          struct {
+			Fid int `json:"fid"`
+		} `json:"lead"`
+	} `json:"channel"`
+}
+
+func (NeynarResolver) Name() string { return "neynar" }
+
+func (r NeynarResolver) Resolve(channelID string) (Channel, error) {
+	if r.APIKey == "" {
+		return Channel{}, fmt.Errorf("no Neynar API key, set one with mast hub")
+	}
+
+	req, err := http.NewRequest("GET", neynarURL+"?type=id&id="+url.QueryEscape(channelID), nil)
+	if err != nil {
+		return Channel{}, fmt.Errorf("Failed to create request: %v", err)
+	}
+	req.Header.Set("x-api-key", r.APIKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Channel{}, fmt.Errorf("Failed to send GET request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return Channel{}, errNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Channel{}, fmt.Errorf("Channel fetch failed. HTTP status: %d", resp.StatusCode)
+	}
+
+	var response neynarChannelResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return Channel{}, fmt.Errorf("Failed to decode channel %q: %v", channelID, err)
+	}
+	c := response.Channel
+	if c.URL == "" {
+		return Channel{}, errNotFound
+	}
+	return Channel{
+		ID:            c.ID,
+		URL:           c.URL,
+		Name:          c.Name,
+		Description:   c.Description,
+		ImageURL:      c.ImageURL,
+		LeadFid:       c.Lead.Fid,
+		CreatedAt:     c.CreatedAt,
+		FollowerCount: c.FollowerCount,
+		MemberCount:   c.MemberCount,
+	}, nil
+}
+
+// FileResolver reads a static mapping file with one "id url" pair per line,
+// blank lines and lines starting with # are ignored
+type FileResolver struct {
+	Path string
+}
+
+func (FileResolver) Name() string { return "file" }
+
+func (r FileResolver) Resolve(channelID string) (Channel, error) {
+	mapping, err := readMapping(r.Path)
+	if err != nil {
+		return Channel{}, err
+	}
+	if parentURL, ok := mapping[channelID]; ok {
+		return Channel{ID: channelID, URL: parentURL}, nil
+	}
+	return Channel{}, errNotFound
+}
+
+func readMapping(path string) (map[string]string, error) {
+	mapping := map[string]string{}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return mapping, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("Invalid line %d in %s, expected: channel-id parent-url", line, path)
+		}
+		mapping[strings.ToLower(strings.TrimPrefix(fields[0], "/"))] = fields[1]
+	}
+	return mapping, scanner.Err()
+}
+
+// Chain tries each resolver in turn until one knows the channel
+type Chain []Resolver
+
+func (c Chain) Resolve(channelID string) (Channel, error) {
+	channelID = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(channelID), "/"))
+
+	var failures []string
+	for _, r := range c {
+		channel, err := r.Resolve(channelID)
+		if err == nil {
+			return channel, nil
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", r.Name(), err))
+	}
+	return Channel{}, fmt.Errorf("Could not resolve channel %q (%s). Pass --parent-url to cast to it directly", channelID, strings.Join(failures, "; "))
+}
+
+func mappingPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".fc-cast-channels-map"), nil
+}
+
+// Resolvers builds the chain from the saved resolver preference. Offline
+// chains leave out everything that needs the network.
+func Resolvers(offline bool) (Chain, error) {
+	names, err := RetrieveResolverPreference()
+	if err != nil {
+		return nil, err
+	}
+	path, err := mappingPath()
+	if err != nil {
+		return nil, err
+	}
+
+	var chain Chain
+	for _, name := range names {
+		switch name {
+		case "file":
+			chain = append(chain, FileResolver{Path: path})
+		case "warpcast":
+			chain = append(chain, WarpcastResolver{Offline: offline})
+		case "neynar":
+			if !offline {
+				_, apiKey, _ := hub.RetrieveHubPreference()
+				chain = append(chain, NeynarResolver{APIKey: apiKey})
+			}
+		}
+	}
+	return chain, nil
+}
+
+// Lookup resolves a channel id with the configured resolvers
+func Lookup(channelID string) (Channel, error) {
+	chain, err := Resolvers(false)
+	if err != nil {
+		return Channel{}, err
+	}
+	return chain.Resolve(channelID)
+}
+
+// ParentURL returns the parent URL for a channel id, or the input itself
+// when it is already a URL
+func ParentURL(channelID string, offline bool) (string, error) {
+	if strings.Contains(channelID, "://") {
+		return channelID, nil
+	}
+	chain, err := Resolvers(offline)
+	if err != nil {
+		return "", err
+	}
+	channel, err := chain.Resolve(channelID)
+	if err != nil {
+		return "", err
+	}
+	return channel.URL, nil
+}
+
+// AddMapping saves a channel id to parent URL pair in the mapping file
+func AddMapping(channelID string, parentURL string) error {
+	if !strings.Contains(parentURL, "://") {
+		return fmt.Errorf("Invalid parent URL %q", parentURL)
+	}
+	path, err := mappingPath()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s %s\n", strings.ToLower(strings.TrimPrefix(channelID, "/")), parentURL)
+	return err
+}
+
+func SaveResolverPreference(names []string) error {
+	for _, name := range names {
+		if name != "file" && name != "warpcast" && name != "neynar" {
+			return fmt.Errorf("Unknown resolver %q, expected file, warpcast or neynar", name)
+		}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+
+	resolverPath := filepath.Join(home, ".fc-cast-channel-resolvers")
+	return os.WriteFile(resolverPath, []byte(strings.Join(names, ",")), 0600)
+}
+
+// RetrieveResolverPreference returns the resolver names in the order they
+// are tried, defaulting to file, warpcast, neynar
+func RetrieveResolverPreference() ([]string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return defaultResolvers, nil
+	}
+
+	saved, err := os.ReadFile(filepath.Join(homeDir, ".fc-cast-channel-resolvers"))
+	if err != nil || len(strings.TrimSpace(string(saved))) == 0 {
+		return defaultResolvers, nil
+	}
+
+	var names []string
+	for _, name := range strings.Split(strings.TrimSpace(string(saved)), ",") {
+		names = append(names, strings.TrimSpace(name))
+	}
+	return names, nil
+}
@@ -0,0 +1,86 @@
+package channels
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChainFallsBackWhenAProviderFails(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	previousDirectory, previousChannel := directoryURL, channelURL
+	directoryURL, channelURL = down.URL, down.URL
+	defer func() { directoryURL, channelURL = previousDirectory, previousChannel }()
+
+	neynar := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("id") != "dev" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var response neynarChannelResponse
+		response.Channel.ID = "dev"
+		response.Channel.URL = "chain://eip155:1/erc721:0x7dd4e31f1530ac682c8ea4d8016e95773e08d8b0"
+		response.Channel.FollowerCount = 40000
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer neynar.Close()
+	previousNeynar := neynarURL
+	neynarURL = neynar.URL
+	defer func() { neynarURL = previousNeynar }()
+
+	mapping := filepath.Join(t.TempDir(), "map")
+	os.WriteFile(mapping, []byte("# local channels\n/memes https://example.com/memes\n"), 0600)
+
+	chain := Chain{FileResolver{Path: mapping}, WarpcastResolver{}, NeynarResolver{APIKey: "test-key"}}
+
+	channel, err := chain.Resolve("/dev")
+	if err != nil || channel.FollowerCount != 40000 || !strings.HasPrefix(channel.URL, "chain://") {
+		t.Errorf("Resolve(dev) = %v, %v", channel, err)
+	}
+
+	channel, err = chain.Resolve("memes")
+	if err != nil || channel.URL != "https://example.com/memes" {
+		t.Errorf("Resolve(memes) = %v, %v", channel, err)
+	}
+
+	_, err = chain.Resolve("missing")
+	if err == nil || !strings.Contains(err.Error(), "warpcast:") || !strings.Contains(err.Error(), "neynar:") {
+		t.Errorf("Resolve(missing) error = %v", err)
+	}
+}
+
+func TestParentURLOffline(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if parentURL, err := ParentURL("https://example.com/anything", true); err != nil || parentURL != "https://example.com/anything" {
+		t.Errorf("raw URL = %q, %v", parentURL, err)
+	}
+
+	if _, err := ParentURL("dev", true); err == nil {
+		t.Error("offline resolution should fail without a cache or mapping")
+	}
+
+	if err := AddMapping("dev", "https://example.com/dev"); err != nil {
+		t.Fatal(err)
+	}
+	if parentURL, err := ParentURL("dev", true); err != nil || parentURL != "https://example.com/dev" {
+		t.Errorf("mapped channel = %q, %v", parentURL, err)
+	}
+
+	if err := SaveResolverPreference([]string{"warpcast", "ipfs"}); err == nil {
+		t.Error("unknown resolver names should be rejected")
+	}
+}
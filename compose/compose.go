@@ -2,7 +2,19 @@ package compose
 
 import (
 	"fmt"
-
+	"mast/channels"
+	"mast/output"
+	"mast/preview"
+	"mast/protobufs"
+	"mast/templates"
+	"mast/upload"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/filepicker"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -11,17 +23,14 @@ import (
 
 type CastData struct {
 	Message string
-	URL1    string
-	URL2    string
-	Channel string
+	// Embeds are URLs, casts and files, up to MaxEmbeds
+	Embeds    []Embed
+	Channel   string
+	ParentURL string
+	// ParentCast makes the cast a reply, taking precedence over the channel
+	ParentCast *protobufs.CastId
 }
 
-const (
-	url1 = iota
-	url2
-	channel
-)
-
 var (
 	inputStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#7C65C1"))
 	continueStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#767676"))
@@ -29,14 +38,116 @@ var (
 	promptStyle   = lipgloss.NewStyle().Border(lipgloss.NormalBorder(), false, false, false, true).BorderForeground(lipgloss.Color("#7C65C1"))
 )
 
+// maxSuggestions is how many channels are listed under the Channel ID input
+const maxSuggestions = 5
+
 type errMsg error
 
+type channelsMsg []channels.Channel
+
+// loadChannels reads the channel directory in the background so the picker
+// fills in once it is available, the input still accepts any id without it
+func loadChannels() tea.Msg {
+	directory, err := channels.Directory(false)
+	if err != nil {
+		return nil
+	}
+	return channelsMsg(directory)
+}
+
+type uploadedMsg struct {
+	target int
+	embed  Embed
+	err    error
+}
+
+// uploadFile sends path through the saved uploader in the background and
+// reports the embed for the target input
+func uploadFile(path string, target int) tea.Cmd {
+	return func() tea.Msg {
+		fileURL, err := upload.File(path)
+		return uploadedMsg{target: target, embed: Embed{Kind: EmbedFile, Path: path, URL: fileURL}, err: err}
+	}
+}
+
+type previewMsg struct {
+	url     string
+	preview preview.Preview
+	err     error
+}
+
+// linkPreview is the preview of an embed URL, loading until the fetch
+// finishes
+type linkPreview struct {
+	preview preview.Preview
+	err     error
+	loading bool
+}
+
+func fetchPreview(u string) tea.Cmd {
+	return func() tea.Msg {
+		p, err := preview.Fetch(u)
+		return previewMsg{url: u, preview: p, err: err}
+	}
+}
+
+func newFilePicker() filepicker.Model {
+	fp := filepicker.New()
+	fp.CurrentDirectory, _ = os.Getwd()
+	fp.AutoHeight = false
+	fp.Height = 10
+	fp.ShowPermissions = false
+	// esc leaves the picker instead of going up a directory
+	fp.KeyMap.Back = key.NewBinding(key.WithKeys("h", "backspace", "left"), key.WithHelp("h", "back"))
+	fp.Styles.Cursor = inputStyle
+	fp.Styles.Selected = inputStyle
+	return fp
+}
+
+func newEmbedInput() textinput.Model {
+	input := textinput.New()
+	input.Placeholder = "https://github.com/stevedylandev/mast-cli or cast:<fid>:<hash>"
+	input.CharLimit = 256
+	input.Width = 70
+	input.Prompt = ""
+	return input
+}
+
+// inputModel holds the message, one input per embed and the channel input,
+// which always comes last in inputs
 type inputModel struct {
 	messageArea textarea.Model
 	inputs      []textinput.Model
-	focused     int
-	err         error
-	canceled    bool
+	// uploads holds, per embed input, the file uploaded into it
+	uploads   []Embed
+	focused   int
+	err       error
+	canceled  bool
+	directory []channels.Channel
+	matches   []channels.Channel
+	selected  int
+	replyTo   string
+	// heading is shown above a new cast, replies use replyTo instead
+	heading string
+	// picking is set while the file picker for an attachment is open,
+	// uploading names the file being uploaded into attachTo
+	picking   bool
+	picker    filepicker.Model
+	attachTo  int
+	uploading string
+	status    string
+	// choosing is set while a template is picked from templateList,
+	// missing lists the variables of chosen still to be asked for
+	choosing      bool
+	templateList  []templates.Template
+	templateIndex int
+	chosen        templates.Template
+	vars          map[string]string
+	missing       []string
+	varInput      textinput.Model
+	// previews are keyed by normalized URL, fetched once an embed input
+	// holding the URL loses focus
+	previews map[string]*linkPreview
 }
 
 func initialInputModel() inputModel {
@@ -49,44 +160,62 @@ func initialInputModel() inputModel {
 	ta.SetWidth(70)
 	ta.SetHeight(10)
 
-	var inputs []textinput.Model = make([]textinput.Model, 3)
-
-	inputs[url1] = textinput.New()
-	inputs[url1].Placeholder = "https://github.com/stevedylandev/mast-cli"
-	inputs[url1].CharLimit = 100
-	inputs[url1].Width = 70
-	inputs[url1].Prompt = ""
-
-	inputs[url2] = textinput.New()
-	inputs[url2].Placeholder = "https://docs.farcaster.xyz"
-	inputs[url2].CharLimit = 100
-	inputs[url2].Width = 70
-	inputs[url2].Prompt = ""
-
-	inputs[channel] = textinput.New()
-	inputs[channel].Placeholder = "dev"
-	inputs[channel].CharLimit = 100
-	inputs[channel].Width = 70
-	inputs[channel].Prompt = ""
+	channelInput := textinput.New()
+	channelInput.Placeholder = "dev"
+	channelInput.CharLimit = 100
+	channelInput.Width = 70
+	channelInput.Prompt = ""
 
 	return inputModel{
 		messageArea: ta,
-		inputs:      inputs,
+		inputs:      []textinput.Model{newEmbedInput(), channelInput},
+		uploads:     make([]Embed, 1),
+		previews:    map[string]*linkPreview{},
 		focused:     -1, // -1 represents textarea focus
 		err:         nil,
 	}
 }
 
 func (m inputModel) Init() tea.Cmd {
-	return tea.Batch(textarea.Blink, textinput.Blink)
+	return tea.Batch(textarea.Blink, textinput.Blink, loadChannels, m.fetchPreviews())
 }
 
 func (m inputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 
+	if done, ok := msg.(uploadedMsg); ok {
+		m.uploading = ""
+		if done.err != nil {
+			m.status = done.err.Error()
+		} else {
+			m.status = "Attached " + filepath.Base(done.embed.Path)
+			m.inputs[done.target].SetValue(done.embed.URL)
+			m.uploads[done.target] = done.embed
+		}
+		return m, m.fetchPreviews()
+	}
+	if done, ok := msg.(previewMsg); ok {
+		m.previews[done.url] = &linkPreview{preview: done.preview, err: done.err}
+		return m, nil
+	}
+	if m.picking {
+		return m.updatePicker(msg)
+	}
+	if m.choosing {
+		return m.updateTemplates(msg)
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.Type {
+		case tea.KeyCtrlO:
+			return m.openPicker()
+		case tea.KeyCtrlT:
+			return m.openTemplates()
+		case tea.KeyCtrlA:
+			m.addEmbed()
+		case tea.KeyCtrlX:
+			m.removeEmbed()
 		case tea.KeyEnter:
 			if m.focused == -1 {
 				// When focused on textarea, handle Enter normally for new lines
@@ -94,8 +223,21 @@ func (m inputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.messageArea, cmd = m.messageArea.Update(msg)
 				return m, cmd
 			} else {
+				// Enter on the channel input first takes the highlighted
+				// suggestion, submitting only once it is filled in
+				if m.focused == m.channelIndex() && m.pickChannel() {
+					return m, nil
+				}
 				// For input fields, handle Enter for submission
-				if m.focused == len(m.inputs)-1 {
+				if m.focused == m.lastInput() {
+					if m.uploading != "" {
+						m.status = "Wait for " + m.uploading + " to finish uploading"
+						return m, nil
+					}
+					if _, err := m.embeds(); err != nil {
+						m.status = err.Error()
+						return m, nil
+					}
 					if m.isValid() {
 						return m, tea.Quit
 					}
@@ -106,16 +248,26 @@ func (m inputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyCtrlC, tea.KeyEsc:
 			m.canceled = true
 			return m, tea.Quit
+		case tea.KeyUp, tea.KeyDown:
+			if m.focused == m.channelIndex() && len(m.matches) > 0 {
+				if msg.Type == tea.KeyUp {
+					m.selected = (m.selected - 1 + len(m.matches)) % len(m.matches)
+				} else {
+					m.selected = (m.selected + 1) % len(m.matches)
+				}
+				return m, nil
+			}
 		case tea.KeyShiftTab, tea.KeyCtrlP:
 			m.prevInput()
 		case tea.KeyTab, tea.KeyCtrlN:
 			m.nextInput()
 		}
+		cmds = append(cmds, m.fetchPreviews())
 
 		if m.focused == -1 {
 			var cmd tea.Cmd
 			m.messageArea, cmd = m.messageArea.Update(msg)
-			return m, cmd
+			return m, tea.Batch(append(cmds, cmd)...)
 		} else {
 			m.messageArea.Blur()
 			for i := range m.inputs {
@@ -127,6 +279,11 @@ func (m inputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case errMsg:
 		m.err = msg
 		return m, nil
+
+	case channelsMsg:
+		m.directory = msg
+		m.filterChannels()
+		return m, nil
 	}
 
 	var cmd tea.Cmd
@@ -135,50 +292,451 @@ func (m inputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds = append(cmds, cmd)
 	}
 
+	query := m.inputs[m.channelIndex()].Value()
 	for i := range m.inputs {
 		m.inputs[i], cmd = m.inputs[i].Update(msg)
 		cmds = append(cmds, cmd)
 	}
+	if m.inputs[m.channelIndex()].Value() != query {
+		m.filterChannels()
+	}
 
 	return m, tea.Batch(cmds...)
 }
 
+// channelIndex is the position of the channel input, after the embeds
+func (m inputModel) channelIndex() int {
+	return len(m.inputs) - 1
+}
+
+func (m inputModel) isEmbedInput(i int) bool {
+	return i >= 0 && i < m.channelIndex()
+}
+
+// addEmbed adds an empty embed input after the others and focuses it
+func (m *inputModel) addEmbed() bool {
+	n := m.channelIndex()
+	if n >= MaxEmbeds {
+		m.status = fmt.Sprintf("A cast can have at most %d embeds", MaxEmbeds)
+		return false
+	}
+	m.inputs = append(m.inputs[:n], newEmbedInput(), m.inputs[n])
+	m.uploads = append(m.uploads, Embed{})
+	m.focused = n
+	m.status = ""
+	return true
+}
+
+// removeEmbed drops the focused embed input, or clears it when it is the
+// only one
+func (m *inputModel) removeEmbed() {
+	if !m.isEmbedInput(m.focused) {
+		return
+	}
+	if m.uploading != "" {
+		m.status = "Wait for " + m.uploading + " to finish uploading"
+		return
+	}
+	m.status = ""
+	if m.channelIndex() == 1 {
+		m.inputs[0].SetValue("")
+		m.uploads[0] = Embed{}
+		return
+	}
+	m.inputs = append(m.inputs[:m.focused], m.inputs[m.focused+1:]...)
+	m.uploads = append(m.uploads[:m.focused], m.uploads[m.focused+1:]...)
+	if m.focused >= m.channelIndex() {
+		m.focused = m.channelIndex() - 1
+	}
+}
+
+// setEmbeds fills the embed inputs from a draft
+func (m *inputModel) setEmbeds(embeds []Embed) {
+	for i, embed := range embeds {
+		if i > 0 && !m.addEmbed() {
+			break
+		}
+		m.inputs[i].SetValue(embed.String())
+		if embed.Kind == EmbedFile && embed.URL != "" {
+			m.uploads[i] = embed
+		}
+	}
+	m.focused = -1
+}
+
+// embeds parses and validates what the embed inputs hold
+func (m inputModel) embeds() ([]Embed, error) {
+	var embeds []Embed
+	for i := 0; i < m.channelIndex(); i++ {
+		value := strings.TrimSpace(m.inputs[i].Value())
+		if value == "" {
+			continue
+		}
+		if m.uploads[i].URL == value {
+			embeds = append(embeds, m.uploads[i])
+			continue
+		}
+		embed, err := ParseEmbed(value)
+		if err != nil {
+			return nil, err
+		}
+		embeds = append(embeds, embed)
+	}
+	return NormalizeEmbeds(embeds)
+}
+
+// previewURL is the normalized URL an embed input holds, or "" when it
+// holds something else. It runs on every keypress and View, so it skips
+// ParseEmbed, which looks up the usernames in cast embeds on the hub.
+func (m inputModel) previewURL(i int) string {
+	u, err := NormalizeURL(m.inputs[i].Value())
+	if err != nil {
+		return ""
+	}
+	return u
+}
+
+// fetchPreviews starts fetching a preview for every URL in an embed input
+// other than the focused one, so nothing is fetched while a URL is typed
+func (m inputModel) fetchPreviews() tea.Cmd {
+	var cmds []tea.Cmd
+	for i := 0; i < m.channelIndex(); i++ {
+		u := m.previewURL(i)
+		if i == m.focused || u == "" || m.previews[u] != nil {
+			continue
+		}
+		m.previews[u] = &linkPreview{loading: true}
+		cmds = append(cmds, fetchPreview(u))
+	}
+	return tea.Batch(cmds...)
+}
+
+// openPicker shows the file picker for the focused embed input, or the
+// first empty one, adding an input when there is room
+func (m inputModel) openPicker() (tea.Model, tea.Cmd) {
+	if m.uploading != "" {
+		m.status = "Wait for " + m.uploading + " to finish uploading"
+		return m, nil
+	}
+	m.attachTo = -1
+	if m.isEmbedInput(m.focused) {
+		m.attachTo = m.focused
+	} else {
+		for i := 0; i < m.channelIndex(); i++ {
+			if m.inputs[i].Value() == "" {
+				m.attachTo = i
+				break
+			}
+		}
+	}
+	if m.attachTo == -1 {
+		focused := m.focused
+		if !m.addEmbed() {
+			m.status = "Every embed is filled, remove one with ctrl+x to attach a file"
+			return m, nil
+		}
+		m.attachTo, m.focused = m.focused, focused
+	}
+	m.picking = true
+	m.status = ""
+	m.picker = newFilePicker()
+	return m, m.picker.Init()
+}
+
+func (m inputModel) updatePicker(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			m.canceled = true
+			return m, tea.Quit
+		case tea.KeyEsc, tea.KeyCtrlO:
+			m.picking = false
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.picker, cmd = m.picker.Update(msg)
+	if ok, path := m.picker.DidSelectFile(msg); ok {
+		m.picking = false
+		m.uploading = filepath.Base(path)
+		return m, uploadFile(path, m.attachTo)
+	}
+	return m, cmd
+}
+
+// openTemplates lists the saved templates to fill the cast in from
+func (m inputModel) openTemplates() (tea.Model, tea.Cmd) {
+	list, err := templates.List()
+	if err != nil {
+		m.status = err.Error()
+		return m, nil
+	}
+	if len(list) == 0 {
+		m.status = "No templates yet, create one with mast templates edit <name>"
+		return m, nil
+	}
+	m.choosing = true
+	m.templateList = list
+	m.templateIndex = 0
+	m.missing = nil
+	m.status = ""
+	return m, nil
+}
+
+func (m inputModel) updateTemplates(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		if len(m.missing) > 0 {
+			var cmd tea.Cmd
+			m.varInput, cmd = m.varInput.Update(msg)
+			return m, cmd
+		}
+		return m, nil
+	}
+
+	switch key.Type {
+	case tea.KeyCtrlC:
+		m.canceled = true
+		return m, tea.Quit
+	case tea.KeyEsc, tea.KeyCtrlT:
+		m.choosing = false
+		return m, nil
+	}
+
+	if len(m.missing) > 0 {
+		if key.Type != tea.KeyEnter {
+			var cmd tea.Cmd
+			m.varInput, cmd = m.varInput.Update(msg)
+			return m, cmd
+		}
+		m.vars[m.missing[0]] = m.varInput.Value()
+		m.missing = m.missing[1:]
+		m.varInput.SetValue("")
+		if len(m.missing) > 0 {
+			return m, nil
+		}
+		return m.applyTemplate()
+	}
+
+	switch key.Type {
+	case tea.KeyUp:
+		m.templateIndex = (m.templateIndex - 1 + len(m.templateList)) % len(m.templateList)
+	case tea.KeyDown:
+		m.templateIndex = (m.templateIndex + 1) % len(m.templateList)
+	case tea.KeyEnter:
+		m.chosen = m.templateList[m.templateIndex]
+		m.vars = map[string]string{}
+		m.missing = m.chosen.Vars()
+		if len(m.missing) == 0 {
+			return m.applyTemplate()
+		}
+		m.varInput = textinput.New()
+		m.varInput.CharLimit = 320
+		m.varInput.Width = 70
+		m.varInput.Prompt = ""
+		m.varInput.Focus()
+		return m, textinput.Blink
+	}
+	return m, nil
+}
+
+// applyTemplate renders the chosen template and replaces the message,
+// embeds and channel with it
+func (m inputModel) applyTemplate() (tea.Model, tea.Cmd) {
+	m.choosing = false
+	rendered, err := m.chosen.Render(m.vars)
+	if err != nil {
+		m.status = err.Error()
+		return m, nil
+	}
+	casts, err := FromTemplate(rendered)
+	if err != nil {
+		m.status = err.Error()
+		return m, nil
+	}
+
+	m.messageArea.SetValue(casts[0].Message)
+	m.inputs = []textinput.Model{newEmbedInput(), m.inputs[m.channelIndex()]}
+	m.uploads = make([]Embed, 1)
+	m.setEmbeds(casts[0].Embeds)
+	if m.replyTo == "" {
+		m.inputs[m.channelIndex()].SetValue(casts[0].Channel)
+		m.filterChannels()
+	}
+	for i := range m.inputs {
+		m.inputs[i].Blur()
+	}
+	m.messageArea.Focus()
+
+	m.status = "Filled in from template " + m.chosen.Name
+	if len(casts) > 1 {
+		m.status = fmt.Sprintf("Template %s splits into %d casts, only the first is filled in, send the thread with mast new --template %s", m.chosen.Name, len(casts), m.chosen.Name)
+	}
+	return m, m.fetchPreviews()
+}
+
+func (m inputModel) templatesView() string {
+	if len(m.missing) > 0 {
+		return fmt.Sprintf("\n %s\n %s\n\n %s\n %s\n",
+			inputStyle.Width(70).Render("Template "+m.chosen.Name),
+			continueStyle.Render("enter = next, esc = back to the cast"),
+			inputStyle.Render(m.missing[0]),
+			m.varInput.View(),
+		)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n %s\n %s\n", inputStyle.Width(70).Render("Use a template"), continueStyle.Render("enter = choose, esc = back to the cast"))
+	for i, t := range m.templateList {
+		marker := "  "
+		name := continueStyle.Render(t.Name)
+		if i == m.templateIndex {
+			marker = inputStyle.Render("> ")
+			name = inputStyle.Render(t.Name)
+		}
+		fmt.Fprintf(&b, "\n %s%s %s", marker, name, continueStyle.Render(t.Description))
+	}
+	return b.String() + "\n"
+}
+
+func (m inputModel) embedsView() string {
+	var b strings.Builder
+	for i := 0; i < m.channelIndex(); i++ {
+		label := inputStyle.Render(fmt.Sprintf("Embed %d", i+1))
+		if m.uploads[i].URL != "" && m.uploads[i].URL == m.inputs[i].Value() {
+			label += continueStyle.Render("  " + filepath.Base(m.uploads[i].Path))
+		}
+		fmt.Fprintf(&b, "\n %s\n %s\n", label, m.inputs[i].View())
+		if link := m.previews[m.previewURL(i)]; link != nil && i != m.focused {
+			if link.loading {
+				b.WriteString(" " + continueStyle.Render("Fetching preview…") + "\n")
+				continue
+			}
+			for _, line := range preview.Render(link.preview, link.err, 70) {
+				b.WriteString(" " + line + "\n")
+			}
+		}
+	}
+	switch {
+	case m.uploading != "":
+		b.WriteString(" " + continueStyle.Render("Uploading "+m.uploading+"…") + "\n")
+	case m.status != "":
+		b.WriteString(" " + continueStyle.Render(m.status) + "\n")
+	}
+	return b.String()
+}
+
+func (m *inputModel) filterChannels() {
+	m.selected = 0
+	m.matches = nil
+	query := m.inputs[m.channelIndex()].Value()
+	if query == "" {
+		return
+	}
+	m.matches = channels.Search(m.directory, query)
+	if len(m.matches) > maxSuggestions {
+		m.matches = m.matches[:maxSuggestions]
+	}
+}
+
+// pickChannel fills the Channel ID input with the highlighted suggestion,
+// returning false when it already holds it so Enter submits instead
+func (m *inputModel) pickChannel() bool {
+	input := &m.inputs[m.channelIndex()]
+	if len(m.matches) == 0 || input.Value() == m.matches[m.selected].ID {
+		return false
+	}
+	input.SetValue(m.matches[m.selected].ID)
+	input.CursorEnd()
+	m.filterChannels()
+	return true
+}
+
+func (m inputModel) suggestionsView() string {
+	if m.focused != m.channelIndex() || len(m.matches) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i, c := range m.matches {
+		marker := "  "
+		id := continueStyle.Render(c.ID)
+		if i == m.selected {
+			marker = inputStyle.Render("> ")
+			id = inputStyle.Render(c.ID)
+		}
+		fmt.Fprintf(&b, "\n %s%s %s", marker, id, continueStyle.Render(fmt.Sprintf("%s followers  %s", channels.FormatFollowers(c.FollowerCount), channels.Summary(c.Description, 40))))
+	}
+	return b.String()
+}
+
 func (m inputModel) View() string {
-	return fmt.Sprintf(
-		`
+	if m.picking {
+		return fmt.Sprintf("\n %s\n %s\n\n%s\n",
+			inputStyle.Width(70).Render("Attach a file"),
+			continueStyle.Render("enter = upload, esc = back to the cast"),
+			m.picker.View(),
+		)
+	}
+	if m.choosing {
+		return m.templatesView()
+	}
+	if m.replyTo != "" {
+		return fmt.Sprintf(
+			`
  %s
  %s
  %s
  %s
-
+%s
  %s
+`,
+			inputStyle.Width(70).Render(m.replyTo),
+			continueStyle.Render("enter = new line, ctrl+t = use a template"),
+			continueStyle.Render("tab = next field, ctrl+a/ctrl+x = add/remove embed, ctrl+o = attach a file"),
+			textareaStyle.Render(m.messageArea.View()),
+			m.embedsView(),
+			continueStyle.Render("Press Enter to send the reply (at least Message or an embed must be filled)"),
+		) + "\n"
+	}
+	heading := ""
+	if m.heading != "" {
+		heading = "\n " + inputStyle.Width(70).Render(m.heading) + "\n"
+	}
+	return heading + fmt.Sprintf(
+		`
  %s
-
  %s
  %s
-
  %s
+%s
  %s
+ %s%s
 
  %s
 `,
 		inputStyle.Width(50).Render("Message"),
-		continueStyle.Render("enter = new line"),
-		continueStyle.Render("tab = next field"),
+		continueStyle.Render("enter = new line, ctrl+t = use a template"),
+		continueStyle.Render("tab = next field, ctrl+a/ctrl+x = add/remove embed, ctrl+o = attach a file"),
 		textareaStyle.Render(m.messageArea.View()),
-		inputStyle.Width(50).Render("URL"),
-		m.inputs[url1].View(),
-		inputStyle.Width(50).Render("URL"),
-		m.inputs[url2].View(),
+		m.embedsView(),
 		inputStyle.Width(50).Render("Channel ID"),
-		m.inputs[channel].View(),
-		continueStyle.Render("Press Enter to submit (at least Message or a URL must be filled)"),
+		m.inputs[m.channelIndex()].View(),
+		m.suggestionsView(),
+		continueStyle.Render("Press Enter to submit (at least Message or an embed must be filled)"),
 	) + "\n"
 }
 
+// lastInput is the index of the last field, replies have no channel field
+func (m inputModel) lastInput() int {
+	if m.replyTo != "" {
+		return m.channelIndex() - 1
+	}
+	return m.channelIndex()
+}
+
 func (m *inputModel) nextInput() {
 	m.focused++
-	if m.focused >= len(m.inputs) {
+	if m.focused > m.lastInput() {
 		m.focused = -1
 		m.messageArea.Focus()
 	} else {
@@ -189,7 +747,7 @@ func (m *inputModel) nextInput() {
 func (m *inputModel) prevInput() {
 	m.focused--
 	if m.focused < -1 {
-		m.focused = len(m.inputs) - 1
+		m.focused = m.lastInput()
 		m.messageArea.Blur()
 	} else if m.focused == -1 {
 		m.messageArea.Focus()
@@ -197,14 +755,63 @@ func (m *inputModel) prevInput() {
 }
 
 func (m inputModel) isValid() bool {
-	return m.messageArea.Value() != "" ||
-		m.inputs[url1].Value() != "" ||
-		m.inputs[url2].Value() != "" ||
-		m.inputs[channel].Value() != ""
+	for _, input := range m.inputs {
+		if input.Value() != "" {
+			return true
+		}
+	}
+	return m.messageArea.Value() != ""
 }
 
 func ComposeCast() (CastData, error) {
-	p := tea.NewProgram(initialInputModel())
+	return runCompose(initialInputModel())
+}
+
+// ComposeReply opens the compose TUI for a reply to parent, with replyTo as
+// the heading. The channel field is left out since replies follow their
+// parent.
+func ComposeReply(parent *protobufs.CastId, replyTo string) (CastData, error) {
+	m := initialInputModel()
+	m.replyTo = replyTo
+
+	castData, err := runCompose(m)
+	if err != nil {
+		return CastData{}, err
+	}
+	castData.ParentCast = parent
+	return castData, nil
+}
+
+// ComposeDraft opens the compose TUI filled in with draft so it can be
+// reviewed and edited before sending. Drafts with a ParentCast are shown as
+// replies with heading above them.
+func ComposeDraft(draft CastData, heading string) (CastData, error) {
+	m := initialInputModel()
+	m.messageArea.SetValue(draft.Message)
+	m.setEmbeds(draft.Embeds)
+	if draft.ParentCast != nil {
+		m.replyTo = heading
+	} else {
+		m.heading = heading
+		m.inputs[m.channelIndex()].SetValue(draft.Channel)
+	}
+
+	castData, err := runCompose(m)
+	if err != nil {
+		return CastData{}, err
+	}
+	castData.ParentCast = draft.ParentCast
+	if draft.ParentCast == nil && castData.Channel == draft.Channel {
+		castData.ParentURL = draft.ParentURL
+	}
+	return castData, nil
+}
+
+func runCompose(model inputModel) (CastData, error) {
+	if output.IsJSON() {
+		return CastData{}, output.Errorf(output.CodeInteractive, "The compose screen is off with --output json, pass --message, --file or - instead")
+	}
+	p := tea.NewProgram(model)
 
 	m, err := p.Run()
 	if err != nil {
@@ -216,13 +823,66 @@ func ComposeCast() (CastData, error) {
 			return CastData{}, fmt.Errorf("cast composition canceled")
 		}
 
-		return CastData{
+		embeds, err := m.embeds()
+		if err != nil {
+			return CastData{}, err
+		}
+		castData := CastData{
 			Message: m.messageArea.Value(),
-			URL1:    m.inputs[url1].Value(),
-			URL2:    m.inputs[url2].Value(),
-			Channel: m.inputs[channel].Value(),
-		}, nil
+			Embeds:  embeds,
+		}
+		if m.replyTo == "" {
+			castData.Channel = m.inputs[m.channelIndex()].Value()
+		}
+		return castData, nil
 	}
 
 	return CastData{}, fmt.Errorf("could not get model from program")
 }
+
+// EditDraft writes draft with its frontmatter to a temp file, opens it in
+// $VISUAL or $EDITOR and reads the saved file back
+func EditDraft(draft CastData) (CastData, error) {
+	if output.IsJSON() {
+		return CastData{}, output.Interactive("--editor")
+	}
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	file, err := os.CreateTemp("", "mast-cast-*.md")
+	if err != nil {
+		return CastData{}, fmt.Errorf("Failed to create draft file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(FormatDraft(draft))
+	file.Close()
+	if err != nil {
+		return CastData{}, fmt.Errorf("Failed to write draft file: %v", err)
+	}
+
+	// EDITOR may carry arguments, such as "code --wait"
+	args := append(strings.Fields(editor), file.Name())
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return CastData{}, fmt.Errorf("Editor %s failed: %v", args[0], err)
+	}
+
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return CastData{}, fmt.Errorf("Failed to read draft file: %v", err)
+	}
+	castData, err := ParseDraft(string(data))
+	if err != nil {
+		return CastData{}, err
+	}
+	if castData.Message == "" && len(castData.Embeds) == 0 {
+		return CastData{}, fmt.Errorf("cast composition canceled, the draft was empty")
+	}
+	return castData, nil
+}
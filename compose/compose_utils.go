@@ -1,16 +1,22 @@
 package compose
 
 import (
-	"bytes"
-	"crypto/ed25519"
+	"context"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
+	"mast/channels"
 	"mast/hub"
+	"mast/message"
+	"mast/output"
 	"mast/protobufs"
-	"net/http"
+	"mast/templates"
+	"mast/upload"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -20,7 +26,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/golang/protobuf/proto"
-	"github.com/zeebo/blake3"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
 type spinnerModel struct {
@@ -68,7 +74,8 @@ func (m spinnerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m spinnerModel) View() string {
 	if m.err != nil {
-		return fmt.Sprintf("Error: %v\n", m.err)
+		// SendCast returns the error so it is only reported once by the caller
+		return ""
 	}
 	if m.done {
 		return fmt.Sprintf("Cast Successful!\nHash: %s\n", m.castHash)
@@ -78,176 +85,756 @@ func (m spinnerModel) View() string {
 
 type doneMsg string
 
-const farcasterEpoch int64 = 1609459200 // January 1, 2021 UTC
+type SendOptions struct {
+	DryRun   bool
+	Encoding string
+	Network  protobufs.FarcasterNetwork
+	Force    bool
+}
+
+var warningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#767676"))
+
+// BuildCast resolves the channel and embeds in castData and returns the
+// signed CAST_ADD message together with the MessageData it was built from.
+// File embeds are uploaded first, unless offline is set for dry runs and
+// mast sign, which refuse them instead.
+func BuildCast(castData CastData, network protobufs.FarcasterNetwork, offline bool) (*protobufs.MessageData, *protobufs.Message, error) {
+	fid, privateKeyHex, err := auth.FindFidAndPrivateKey()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Problem retrieving credentials, run mast auth to authorize the CLI: %w", err)
+	}
+	castEmbeds, err := NormalizeEmbeds(castData.Embeds)
+	if err != nil {
+		return nil, nil, err
+	}
+	if offline {
+		err = checkUploaded(castEmbeds)
+	} else {
+		castEmbeds, err = UploadEmbeds(castEmbeds)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var embeds []*protobufs.Embed
+	for _, embed := range castEmbeds {
+		embeds = append(embeds, embed.proto())
+	}
+
+	castAdd := &protobufs.CastAddBody{
+		Text:   castData.Message,
+		Embeds: embeds,
+	}
+
+	if castData.ParentCast != nil {
+		castAdd.Parent = &protobufs.CastAddBody_ParentCastId{
+			ParentCastId: castData.ParentCast,
+		}
+	} else if castData.ParentURL != "" {
+		castAdd.Parent = &protobufs.CastAddBody_ParentUrl{
+			ParentUrl: castData.ParentURL,
+		}
+	} else if castData.Channel != "" {
+		parentURL, err := channels.ParentURL(castData.Channel, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		castAdd.Parent = &protobufs.CastAddBody_ParentUrl{
+			ParentUrl: parentURL,
+		}
+	}
+
+	msgData := &protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_CAST_ADD,
+		Fid:       fid,
+		Timestamp: message.Timestamp(time.Now()),
+		Network:   network,
+		Body:      &protobufs.MessageData_CastAddBody{CastAddBody: castAdd},
+	}
+
+	logMessageData(msgData)
+	msg, err := message.Sign(msgData, privateKeyHex)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return msgData, msg, nil
+}
+
+// CheckMembership stops casts to channels the author can't post in, which
+// hubs accept but clients then leave out of the channel. It only warns when
+// membership can't be checked, and skips parent URLs that aren't channels in
+// the Warpcast directory.
+func CheckMembership(castData CastData) error {
+	if castData.ParentCast != nil {
+		return nil
+	}
+	parentURL := castData.ParentURL
+	if parentURL == "" && castData.Channel != "" {
+		var err error
+		parentURL, err = channels.ParentURL(castData.Channel, false)
+		if err != nil {
+			return err
+		}
+	}
+	if parentURL == "" {
+		return nil
+	}
 
-func SendCast(castData CastData) error {
-	rawFid, privateKeyHex, err := auth.FindFidAndPrivateKey()
+	directory, err := channels.Directory(false)
 	if err != nil {
-		log.Fatalf("Problem retrieving credentials, run cast auth to authorize tbe CLI")
+		fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("Warning: could not check channel membership: %v", err)))
+		return nil
+	}
+	channel, ok := channels.FindByURL(directory, parentURL)
+	if !ok {
+		if castData.ParentURL == "" && !strings.Contains(castData.Channel, "://") {
+			fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("Warning: /%s is not in the channel directory, membership was not checked", castData.Channel)))
+		}
+		return nil
+	}
+
+	fid, _, err := auth.FindFidAndPrivateKey()
+	if err != nil {
+		return fmt.Errorf("Problem retrieving credentials, run mast auth to authorize the CLI: %w", err)
+	}
+
+	err = channels.CheckMembership(channel, fid)
+	var membershipErr *channels.MembershipError
+	if errors.As(err, &membershipErr) {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, warningStyle.Render(fmt.Sprintf("Warning: could not check membership of /%s: %v", channel.ID, err)))
+	}
+	return nil
+}
+
+func SendCast(castData CastData, opts SendOptions) error {
+	if output.IsJSON() {
+		return sendJSON(castData, opts)
+	}
+	if opts.DryRun {
+		msgData, msg, err := BuildCast(castData, opts.Network, true)
+		if err != nil {
+			return err
+		}
+		return message.Print(os.Stdout, msgData, msg, opts.Encoding)
+	}
+
+	if !opts.Force {
+		if err := CheckMembership(castData); err != nil {
+			return err
+		}
 	}
-	fid := uint64(rawFid)
-	network := protobufs.FarcasterNetwork_FARCASTER_NETWORK_MAINNET
 
 	resultChan := make(chan string)
 	errorChan := make(chan error)
 	go func() {
-		var embeds []*protobufs.Embed
+		_, hash, err := submitCast(castData, opts.Network)
+		if err != nil {
+			errorChan <- err
+			return
+		}
+		resultChan <- hash
+	}()
+	p := tea.NewProgram(initialSpinnerModel())
 
-		if castData.URL1 != "" {
-			embeds = append(embeds, &protobufs.Embed{
-				Embed: &protobufs.Embed_Url{
-					Url: castData.URL1,
-				},
-			})
+	go func() {
+		select {
+		case hash := <-resultChan:
+			p.Send(doneMsg(hash))
+		case err := <-errorChan:
+			p.Send(errMsg(err))
 		}
+	}()
 
-		if castData.URL2 != "" {
-			embeds = append(embeds, &protobufs.Embed{
-				Embed: &protobufs.Embed_Url{
-					Url: castData.URL2,
-				},
-			})
+	m, err := p.Run()
+	if err != nil {
+		return err
+	}
+
+	if m, ok := m.(spinnerModel); ok && m.err != nil {
+		return m.err
+	}
+
+	return nil
+}
+
+// sendJSON sends or, with DryRun, signs castData and prints the result as
+// JSON instead of showing the spinner
+func sendJSON(castData CastData, opts SendOptions) error {
+	if opts.DryRun {
+		msgData, msg, err := BuildCast(castData, opts.Network, true)
+		if err != nil {
+			return err
 		}
+		result, err := dryRunResult(msgData, msg, opts.Encoding)
+		if err != nil {
+			return err
+		}
+		return output.Print(result)
+	}
+	msgData, hash, err := PublishMessage(castData, opts)
+	if err != nil {
+		return err
+	}
+	return output.Print(output.NewCastResult(msgData, hash))
+}
 
-		castAdd := &protobufs.CastAddBody{
-			Text:   castData.Message,
-			Embeds: embeds,
+// dryRunResult describes a signed cast that was not sent, with the
+// message bytes in encoding
+func dryRunResult(msgData *protobufs.MessageData, msg *protobufs.Message, encoding string) (output.CastResult, error) {
+	msgBytes, err := proto.Marshal(msg)
+	if err != nil {
+		return output.CastResult{}, fmt.Errorf("Failed to encode message: %v", err)
+	}
+	result := output.NewCastResult(msgData, fmt.Sprintf("0x%x", msg.Hash))
+	result.DryRun = true
+	if result.Message, err = message.EncodeBytes(msgBytes, encoding); err != nil {
+		return output.CastResult{}, err
+	}
+	return result, nil
+}
+
+func submitCast(castData CastData, network protobufs.FarcasterNetwork) (*protobufs.MessageData, string, error) {
+	if err := hub.CheckNetwork(network); err != nil {
+		return nil, "", err
+	}
+
+	msgData, msg, err := BuildCast(castData, network, false)
+	if err != nil {
+		return nil, "", err
+	}
+
+	msgBytes, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to encode message: %v", err)
+	}
+	hash, err := hub.SubmitMessage(msgBytes)
+	if err != nil {
+		return nil, "", err
+	}
+	return msgData, hash, nil
+}
+
+// Publish sends castData like SendCast but without the spinner, for
+// commands that run unattended, and returns the cast's hash
+func Publish(castData CastData, opts SendOptions) (string, error) {
+	_, hash, err := PublishMessage(castData, opts)
+	return hash, err
+}
+
+// PublishMessage is Publish returning the MessageData that was sent too
+func PublishMessage(castData CastData, opts SendOptions) (*protobufs.MessageData, string, error) {
+	if !opts.Force {
+		if err := CheckMembership(castData); err != nil {
+			return nil, "", err
 		}
+	}
+	return submitCast(castData, opts.Network)
+}
 
-		if castData.Channel != "" {
-			url := fmt.Sprintf("https://api.warpcast.com/v1/channel?channelId=%s", castData.Channel)
-			resp, err := http.Get(url)
-			if err != nil {
-				log.Fatalf("Failed to send GET request: %v", err)
-				return
-			}
-			defer resp.Body.Close()
-			if resp.StatusCode != 200 {
-				log.Fatalf("Channel fetch failed")
+// SendThread sends casts in order, each one replying to the cast before
+// it. With DryRun each cast is printed instead, its parent being the hash
+// the previous one would have.
+func SendThread(casts []CastData, opts SendOptions) error {
+	if len(casts) == 1 {
+		return SendCast(casts[0], opts)
+	}
+	if !opts.DryRun {
+		if err := hub.CheckNetwork(opts.Network); err != nil {
+			return err
+		}
+		if !opts.Force {
+			if err := CheckMembership(casts[0]); err != nil {
+				return err
 			}
-			var response GetChannelResonse
-			err = json.NewDecoder(resp.Body).Decode(&response)
+		}
+	}
+
+	var parent *protobufs.CastId
+	for i, castData := range casts {
+		if parent != nil {
+			castData.ParentCast = parent
+		}
+		msgData, msg, err := BuildCast(castData, opts.Network, opts.DryRun)
+		if err != nil {
+			return err
+		}
+		parent = &protobufs.CastId{Fid: msgData.Fid, Hash: msg.Hash}
+
+		if opts.DryRun && output.IsJSON() {
+			result, err := dryRunResult(msgData, msg, opts.Encoding)
 			if err != nil {
-				log.Fatalf("Faled to decode json")
+				return err
 			}
-			castAdd.Parent = &protobufs.CastAddBody_ParentUrl{
-				ParentUrl: response.Result.Channel.URL,
+			if err := output.Print(result); err != nil {
+				return err
+			}
+			continue
+		}
+		if opts.DryRun {
+			fmt.Printf("Cast %d of %d\n", i+1, len(casts))
+			if err := message.Print(os.Stdout, msgData, msg, opts.Encoding); err != nil {
+				return err
 			}
+			fmt.Println()
+			continue
 		}
 
-		msgData := &protobufs.MessageData{
-			Type:      protobufs.MessageType_MESSAGE_TYPE_CAST_ADD,
-			Fid:       fid,
-			Timestamp: uint32(time.Now().Unix() - farcasterEpoch),
-			Network:   network,
-			Body:      &protobufs.MessageData_CastAddBody{castAdd},
+		msgBytes, err := proto.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("Failed to encode message: %v", err)
+		}
+		hash, err := hub.SubmitMessage(msgBytes)
+		if err != nil {
+			if i > 0 {
+				return fmt.Errorf("Failed to send cast %d of %d, the first %d were sent: %v", i+1, len(casts), i, err)
+			}
+			return err
+		}
+		if output.IsJSON() {
+			if err := output.Print(output.NewCastResult(msgData, hash)); err != nil {
+				return err
+			}
+			continue
 		}
+		fmt.Printf("Cast %d of %d sent\nHash: %s\n", i+1, len(casts), hash)
+	}
+	return nil
+}
 
-		msgDataBytes, err := proto.Marshal(msgData)
+// FromTemplate turns a rendered template into the casts to send, the first
+// one carrying the embeds and channel and the rest sent as replies by
+// SendThread
+func FromTemplate(r templates.Rendered) ([]CastData, error) {
+	var embeds []Embed
+	for _, value := range r.Embeds {
+		embed, err := ParseEmbed(value)
 		if err != nil {
-			log.Fatalf("Failed to encode message data: %v", err)
-			return
+			return nil, err
 		}
+		embeds = append(embeds, embed)
+	}
+	embeds, err := NormalizeEmbeds(embeds)
+	if err != nil {
+		return nil, err
+	}
 
-		hasher := blake3.New()
-		hasher.Write(msgDataBytes)
-		hash := hasher.Sum(nil)[:20]
+	casts := make([]CastData, len(r.Parts))
+	for i, part := range r.Parts {
+		casts[i].Message = part
+	}
+	casts[0].Embeds = embeds
+	casts[0].Channel = r.Channel
+	return casts, nil
+}
 
-		msg := &protobufs.Message{
-			HashScheme:      protobufs.HashScheme_HASH_SCHEME_BLAKE3,
-			Hash:            hash,
-			SignatureScheme: protobufs.SignatureScheme_SIGNATURE_SCHEME_ED25519,
-		}
+// Like signs a REACTION_ADD liking target and submits it to the preferred
+// hub, returning the reaction's hash
+func Like(target *protobufs.CastId, network protobufs.FarcasterNetwork) (string, error) {
+	fid, privateKeyHex, err := auth.FindFidAndPrivateKey()
+	if err != nil {
+		return "", fmt.Errorf("Problem retrieving credentials, run mast auth to authorize the CLI: %w", err)
+	}
+	if err := hub.CheckNetwork(network); err != nil {
+		return "", err
+	}
 
-		if strings.HasPrefix(privateKeyHex, "0x") {
-			privateKeyHex = privateKeyHex[2:]
-		}
-		privateKeyBytes, err := hex.DecodeString(privateKeyHex)
+	msgData := &protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_REACTION_ADD,
+		Fid:       fid,
+		Timestamp: message.Timestamp(time.Now()),
+		Network:   network,
+		Body: &protobufs.MessageData_ReactionBody{ReactionBody: &protobufs.ReactionBody{
+			Type:   protobufs.ReactionType_REACTION_TYPE_LIKE,
+			Target: &protobufs.ReactionBody_TargetCastId{TargetCastId: target},
+		}},
+	}
+	logMessageData(msgData)
+	msg, err := message.Sign(msgData, privateKeyHex)
+	if err != nil {
+		return "", err
+	}
+
+	msgBytes, err := proto.Marshal(msg)
+	if err != nil {
+		return "", fmt.Errorf("Failed to encode message: %v", err)
+	}
+	return hub.SubmitMessage(msgBytes)
+}
+
+// logMessageData logs msgData before it is signed when --debug is on
+func logMessageData(msgData *protobufs.MessageData) {
+	if !slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	dataJSON, _ := protojson.Marshal(msgData)
+	slog.Debug("Built message", "type", msgData.Type.String(), "data", string(dataJSON))
+}
+
+// SignCast builds and signs castData and writes the resulting message to
+// out, or to stdout when out is empty or "-". It never touches the network,
+// so channels are resolved from the mapping file and channel cache only.
+func SignCast(castData CastData, network protobufs.FarcasterNetwork, out string, format string, appendTo bool) error {
+	if castData.Channel != "" && castData.ParentURL == "" {
+		parentURL, err := channels.ParentURL(castData.Channel, true)
 		if err != nil {
-			log.Fatalf("Invalid hex string: %v", err)
-			return
+			return fmt.Errorf("%v. Signing is offline, so only the channel mapping file and cache are used; run mast channels refresh while online", err)
 		}
-		privateKey := ed25519.NewKeyFromSeed(privateKeyBytes)
-		signature := ed25519.Sign(privateKey, hash)
+		castData.ParentURL = parentURL
+	}
 
-		msg.Signature = signature
-		msg.Signer = privateKey.Public().(ed25519.PublicKey)
+	msgData, msg, err := BuildCast(castData, network, true)
+	if err != nil {
+		return err
+	}
 
-		msg.DataBytes = msgDataBytes
-		msgBytes, err := proto.Marshal(msg)
+	if output.IsJSON() && (out == "" || out == "-") {
+		// The signed message goes in the result instead of raw on stdout
+		result, err := dryRunResult(msgData, msg, "hex")
 		if err != nil {
-			log.Fatalf("Failed to encode message: %v", err)
-			return
+			return err
 		}
+		return output.Print(result)
+	}
+	if out == "" || out == "-" {
+		return message.Write(os.Stdout, []*protobufs.Message{msg}, format)
+	}
 
-		hub, apiKey, err := hub.RetrieveHubPreference()
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendTo {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(out, flags, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = message.Write(f, []*protobufs.Message{msg}, format)
+	if err != nil {
+		return err
+	}
+
+	if output.IsJSON() {
+		return output.Print(output.NewCastResult(msgData, fmt.Sprintf("0x%x", msg.Hash)))
+	}
+	fmt.Fprintf(os.Stderr, "Signed cast 0x%x written to %s\n", msg.Hash, out)
+	return nil
+}
+
+// EmbedKind says what an embed points at
+type EmbedKind int
+
+const (
+	EmbedURL EmbedKind = iota
+	EmbedCast
+	// EmbedFile is a local file, embedded by the URL it is uploaded to
+	EmbedFile
+)
+
+const (
+	// MaxEmbeds is how many embeds hubs accept on a cast
+	MaxEmbeds = 2
+	// maxEmbedURLBytes is the longest embed URL hubs accept
+	maxEmbedURLBytes = 256
+)
+
+// Embed is one entry of a cast's embed list
+type Embed struct {
+	Kind EmbedKind
+	// URL is the link of an EmbedURL, and of an EmbedFile once uploaded
+	URL  string
+	Cast *protobufs.CastId
+	// Path is the local file of an EmbedFile
+	Path string
+}
+
+// ParseEmbed reads an embed written as a URL, as cast:<fid>:<hash> with a
+// fid or username, or as file:<path>
+func ParseEmbed(value string) (Embed, error) {
+	value = strings.TrimSpace(value)
+	if rest, ok := strings.CutPrefix(value, "cast:"); ok {
+		user, hashValue, ok := strings.Cut(rest, ":")
+		if !ok {
+			return Embed{}, fmt.Errorf("Cast embed %q should look like cast:<fid>:<hash>", value)
+		}
+		hash, err := parseCastHash(hashValue)
 		if err != nil {
-			log.Fatal(err)
+			return Embed{}, err
 		}
-
-		url := hub + "/v1/submitMessage"
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(msgBytes))
+		fid, err := hub.ParseUser(strings.TrimPrefix(user, "@"))
 		if err != nil {
-			log.Fatalf("Failed to create request: %v", err)
+			return Embed{}, err
 		}
-		req.Header.Set("Content-Type", "application/octet-stream")
-		
-		// Add API key header if available (for Neynar)
-		if apiKey != "" {
-			req.Header.Set("x-api-key", apiKey)
+		return Embed{Kind: EmbedCast, Cast: &protobufs.CastId{Fid: fid, Hash: hash}}, nil
+	}
+	if path, ok := strings.CutPrefix(value, "file:"); ok {
+		if path == "" {
+			return Embed{}, fmt.Errorf("file: needs the path of the file to attach")
 		}
+		return Embed{Kind: EmbedFile, Path: path}, nil
+	}
+	if !strings.Contains(value, "://") {
+		return Embed{}, fmt.Errorf("Embed %q is not a URL, use https://…, cast:<fid>:<hash> or file:<path>", value)
+	}
+	return Embed{Kind: EmbedURL, URL: value}, nil
+}
 
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Fatalf("Failed to send POST request: %v", err)
-			return
+// parseCastHash accepts a cast hash with or without its 0x prefix
+func parseCastHash(value string) ([]byte, error) {
+	hash, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(value), "0x"))
+	if err != nil || len(hash) != 20 {
+		return nil, fmt.Errorf("Invalid cast hash %q, expected 20 bytes of hex", value)
+	}
+	return hash, nil
+}
+
+// String writes the embed the way ParseEmbed reads it. Uploaded files are
+// written as their URL.
+func (e Embed) String() string {
+	switch {
+	case e.Kind == EmbedCast:
+		return fmt.Sprintf("cast:%d:0x%x", e.Cast.GetFid(), e.Cast.GetHash())
+	case e.Kind == EmbedFile && e.URL == "":
+		return "file:" + e.Path
+	}
+	return e.URL
+}
+
+func (e Embed) proto() *protobufs.Embed {
+	if e.Kind == EmbedCast {
+		return &protobufs.Embed{Embed: &protobufs.Embed_CastId{CastId: e.Cast}}
+	}
+	return &protobufs.Embed{Embed: &protobufs.Embed_Url{Url: e.URL}}
+}
+
+// NormalizeURL checks an embed URL is http(s) and short enough for hubs, and
+// lowercases its scheme and host and drops a default port
+func NormalizeURL(value string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return "", fmt.Errorf("Invalid URL %q: %v", value, err)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return "", fmt.Errorf("Embed %q must be an http or https URL", value)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("Embed %q has no host", value)
+	}
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && !(u.Scheme == "https" && port == "443") && !(u.Scheme == "http" && port == "80") {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u.Host = host
+	normalized := u.String()
+	if len(normalized) > maxEmbedURLBytes {
+		return "", fmt.Errorf("Embed URL is %d bytes, hubs accept up to %d", len(normalized), maxEmbedURLBytes)
+	}
+	return normalized, nil
+}
+
+// NormalizeEmbeds validates every embed, normalizes URLs, and rejects
+// duplicates and lists longer than MaxEmbeds
+func NormalizeEmbeds(embeds []Embed) ([]Embed, error) {
+	if len(embeds) > MaxEmbeds {
+		return nil, fmt.Errorf("A cast can have at most %d embeds, got %d", MaxEmbeds, len(embeds))
+	}
+	seen := map[string]bool{}
+	var normalized []Embed
+	for _, embed := range embeds {
+		var key string
+		switch embed.Kind {
+		case EmbedCast:
+			if embed.Cast == nil || embed.Cast.Fid == 0 || len(embed.Cast.Hash) != 20 {
+				return nil, fmt.Errorf("Cast embeds need a fid and a 20 byte hash")
+			}
+			key = embed.String()
+		case EmbedFile:
+			if embed.URL == "" {
+				path, err := filepath.Abs(embed.Path)
+				if err != nil {
+					return nil, err
+				}
+				key = "file:" + path
+				break
+			}
+			fallthrough
+		default:
+			u, err := NormalizeURL(embed.URL)
+			if err != nil {
+				return nil, err
+			}
+			embed.URL = u
+			key = strings.TrimSuffix(u, "/")
 		}
-		defer resp.Body.Close()
-
-		var response CastResponse
-		err = json.NewDecoder(resp.Body).Decode(&response)
-		if err != nil {
-			log.Fatalf("Faled to decode json")
-		}
-
-		if resp.StatusCode == http.StatusOK {
-			resultChan <- response.Hash
-		} else {
-			// Read the response body for more detailed error information
-			bodyBytes, _ := io.ReadAll(resp.Body)
-			bodyStr := string(bodyBytes)
-			
-			var errorMsg string
-			switch resp.StatusCode {
-			case 401:
-				errorMsg = fmt.Sprintf("Authentication failed (401). Please check your API key.")
-			case 402:
-				errorMsg = fmt.Sprintf("Payment required (402). Please check your Neynar account status and billing.")
-			case 403:
-				errorMsg = fmt.Sprintf("Forbidden (403). You may not have permission to use this endpoint.")
-			case 429:
-				errorMsg = fmt.Sprintf("Rate limited (429). Please try again later.")
-			default:
-				errorMsg = fmt.Sprintf("Failed to send the message. HTTP status: %d. Response: %s", resp.StatusCode, bodyStr)
+		if seen[key] {
+			return nil, fmt.Errorf("%s is embedded twice", embed)
+		}
+		seen[key] = true
+		normalized = append(normalized, embed)
+	}
+	return normalized, nil
+}
+
+// UploadEmbeds uploads the file embeds that don't have a URL yet with the
+// saved uploader
+func UploadEmbeds(embeds []Embed) ([]Embed, error) {
+	uploaded := make([]Embed, len(embeds))
+	for i, embed := range embeds {
+		if embed.Kind == EmbedFile && embed.URL == "" {
+			fileURL, err := upload.File(embed.Path)
+			if err != nil {
+				return nil, err
 			}
-			errorChan <- fmt.Errorf(errorMsg)
+			embed.URL = fileURL
 		}
-	}()
-	p := tea.NewProgram(initialSpinnerModel())
+		uploaded[i] = embed
+	}
+	return uploaded, nil
+}
 
-	go func() {
-		select {
-		case hash := <-resultChan:
-			p.Send(doneMsg(hash))
-		case err := <-errorChan:
-			p.Send(errMsg(err))
+// checkUploaded refuses file embeds that would need uploading, for dry runs
+// and mast sign which must not reach the uploader
+func checkUploaded(embeds []Embed) error {
+	for _, embed := range embeds {
+		if embed.Kind == EmbedFile && embed.URL == "" {
+			return output.Errorf(output.CodeInvalidInput, "%s would have to be uploaded, which dry runs and mast sign never do. Run mast upload %s and embed the URL it prints instead", embed.Path, embed.Path)
 		}
-	}()
+	}
+	return nil
+}
 
-	if _, err := p.Run(); err != nil {
-		return err
+// draftKeys are the frontmatter fields a draft may set, in the order
+// FormatDraft writes them. embed can be given once per embed, url and url2
+// are still read for drafts written before embed existed.
+var draftKeys = []string{"embed", "channel", "parent-url", "reply", "reply-fid", "url", "url2"}
+
+// ParseDraft reads cast text with an optional frontmatter block in front of
+// it, such as
+//
+//	---
+//	embed: https://example.com
+//	embed: cast:6596:0x1234…
+//	channel: dev
+//	reply: 0x1234…
+//	reply-fid: 6596
+//	---
+//	The cast text
+//
+// embed takes the same forms as --embed. reply is the hash of the cast to
+// reply to and reply-fid its author's fid or username. Empty fields are
+// ignored.
+func ParseDraft(text string) (CastData, error) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	fields := map[string]string{}
+	var embeds []string
+
+	lines := strings.Split(text, "\n")
+	if strings.TrimSpace(lines[0]) == "---" {
+		closing := 0
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == "---" {
+				closing = i
+				break
+			}
+		}
+		if closing == 0 {
+			return CastData{}, fmt.Errorf("Frontmatter is missing its closing ---")
+		}
+
+		for i, line := range lines[1:closing] {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				return CastData{}, fmt.Errorf("Frontmatter line %d should be key: value, got %q", i+2, line)
+			}
+			key = strings.ToLower(strings.TrimSpace(key))
+			value = strings.TrimSpace(value)
+			if !isDraftKey(key) {
+				return CastData{}, fmt.Errorf("Unknown frontmatter key %q, expected one of %s", key, strings.Join(draftKeys[:5], ", "))
+			}
+			if key == "embed" || key == "url" || key == "url2" {
+				if value != "" {
+					embeds = append(embeds, value)
+				}
+				continue
+			}
+			fields[key] = value
+		}
+		text = strings.Join(lines[closing+1:], "\n")
 	}
 
-	return nil
+	castData := CastData{
+		Message:   strings.TrimSpace(text),
+		Channel:   fields["channel"],
+		ParentURL: fields["parent-url"],
+	}
+	for _, value := range embeds {
+		embed, err := ParseEmbed(value)
+		if err != nil {
+			return CastData{}, err
+		}
+		castData.Embeds = append(castData.Embeds, embed)
+	}
+
+	if fields["reply"] != "" || fields["reply-fid"] != "" {
+		if fields["reply"] == "" || fields["reply-fid"] == "" {
+			return CastData{}, fmt.Errorf("reply and reply-fid go together, hubs look casts up by author and hash")
+		}
+		hash, err := parseCastHash(fields["reply"])
+		if err != nil {
+			return CastData{}, err
+		}
+		fid, err := hub.ParseUser(strings.TrimPrefix(fields["reply-fid"], "@"))
+		if err != nil {
+			return CastData{}, err
+		}
+		castData.ParentCast = &protobufs.CastId{Fid: fid, Hash: hash}
+	}
+	return castData, nil
+}
+
+func isDraftKey(key string) bool {
+	for _, k := range draftKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatDraft writes castData as text ParseDraft reads back, with an embed
+// line for each free embed so they can be filled in
+func FormatDraft(castData CastData) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	for i := 0; i < MaxEmbeds || i < len(castData.Embeds); i++ {
+		line := "embed:"
+		if i < len(castData.Embeds) {
+			line += " " + castData.Embeds[i].String()
+		}
+		b.WriteString(line + "\n")
+	}
+	reply, replyFid := "", ""
+	if castData.ParentCast != nil {
+		reply = fmt.Sprintf("0x%x", castData.ParentCast.Hash)
+		replyFid = fmt.Sprintf("%d", castData.ParentCast.Fid)
+	}
+	for _, field := range [][2]string{{"channel", castData.Channel}, {"parent-url", castData.ParentURL}, {"reply", reply}, {"reply-fid", replyFid}} {
+		b.WriteString(strings.TrimRight(field[0]+": "+field[1], " ") + "\n")
+	}
+	b.WriteString("---\n")
+	b.WriteString(castData.Message)
+	if castData.Message != "" && !strings.HasSuffix(castData.Message, "\n") {
+		b.WriteString("\n")
+	}
+	return b.String()
 }
@@ -0,0 +1,277 @@
+package compose
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"mast/channels"
+	"mast/devhub"
+	"mast/hub"
+	"mast/message"
+	"mast/protobufs"
+	"mast/upload"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const testPrivateKey = "0707070707070707070707070707070707070707070707070707070707070707"
+
+func setupAccount(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.WriteFile(filepath.Join(home, ".fc-cast-fid"), []byte("6596"), 0600)
+	os.WriteFile(filepath.Join(home, ".fc-cast-signer"), []byte(testPrivateKey), 0600)
+}
+
+func TestBuildCastReplyTakesPrecedence(t *testing.T) {
+	setupAccount(t)
+	parent := &protobufs.CastId{Fid: 3, Hash: bytes.Repeat([]byte{1}, 20)}
+
+	msgData, msg, err := BuildCast(CastData{
+		Message:    "gm",
+		Embeds:     []Embed{{Kind: EmbedURL, URL: "https://example.com"}},
+		ParentURL:  "https://example.com/channel",
+		ParentCast: parent,
+	}, protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := msgData.GetCastAddBody()
+	if body.GetParentCastId().GetFid() != 3 || body.GetParentUrl() != "" {
+		t.Errorf("parent = %v", body.Parent)
+	}
+	if len(body.Embeds) != 1 || msgData.Fid != 6596 {
+		t.Errorf("embeds %v, fid %d", body.Embeds, msgData.Fid)
+	}
+	if err := message.Verify(msg); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestLikeSubmitsReaction(t *testing.T) {
+	setupAccount(t)
+	handler, err := devhub.NewHandler(devhub.Options{Network: protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	if err := hub.SaveHubPreference(server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	// SetDefault sends the log package to slog too, put both back after
+	var logs bytes.Buffer
+	defer log.SetFlags(log.Flags())
+	defer log.SetOutput(log.Writer())
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	target := &protobufs.CastId{Fid: 3, Hash: bytes.Repeat([]byte{2}, 20)}
+	if _, err := Like(target, protobufs.FarcasterNetwork_FARCASTER_NETWORK_MAINNET); err == nil {
+		t.Error("a like for the wrong network should fail the network check")
+	}
+	if _, err := Like(target, protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logs.String(), "REACTION_TYPE_LIKE") {
+		t.Errorf("--debug did not log the reaction's MessageData:\n%s", logs.String())
+	}
+
+	page, err := hub.GetMessages("/v1/reactionsByCast", url.Values{
+		"target_fid":  {strconv.Itoa(3)},
+		"target_hash": {"0x0202020202020202020202020202020202020202"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Messages) != 1 || page.Messages[0].Data.GetReactionBody().GetType() != protobufs.ReactionType_REACTION_TYPE_LIKE {
+		t.Errorf("reactionsByCast = %v", page.Messages)
+	}
+}
+
+func TestParseDraft(t *testing.T) {
+	draft, err := ParseDraft("---\nurl: https://example.com/a?b=c\n# a comment\nchannel: dev\nurl2:\n---\n\nFirst line\nsecond line\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := CastData{Message: "First line\nsecond line", Embeds: []Embed{{Kind: EmbedURL, URL: "https://example.com/a?b=c"}}, Channel: "dev"}
+	if !reflect.DeepEqual(draft, want) {
+		t.Errorf("draft = %+v", draft)
+	}
+
+	draft, err = ParseDraft("Just text\r\n")
+	if err != nil || draft.Message != "Just text" {
+		t.Errorf("plain draft = %+v, %v", draft, err)
+	}
+
+	for _, text := range []string{
+		"---\nurl: https://example.com\nno closing",
+		"---\ncolour: red\n---\nhi",
+		"---\nreply: 0x0101010101010101010101010101010101010101\n---\nhi",
+		"---\nreply: 0x01\nreply-fid: 3\n---\nhi",
+	} {
+		if _, err := ParseDraft(text); err == nil {
+			t.Errorf("ParseDraft accepted %q", text)
+		}
+	}
+}
+
+func TestFormatDraftRoundTrips(t *testing.T) {
+	castData := CastData{
+		Message: "Multi\nline",
+		Embeds: []Embed{
+			{Kind: EmbedURL, URL: "https://example.com"},
+			{Kind: EmbedCast, Cast: &protobufs.CastId{Fid: 3, Hash: bytes.Repeat([]byte{2}, 20)}},
+		},
+		ParentCast: &protobufs.CastId{Fid: 3, Hash: bytes.Repeat([]byte{1}, 20)},
+	}
+	text := FormatDraft(castData)
+	if !strings.Contains(text, "\nchannel:\n") || !strings.Contains(text, "reply-fid: 3\n") {
+		t.Errorf("draft text = %q", text)
+	}
+	draft, err := ParseDraft(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(draft, castData) {
+		t.Errorf("round trip = %+v", draft)
+	}
+}
+
+func TestParseEmbed(t *testing.T) {
+	embed, err := ParseEmbed("cast:3:0x0202020202020202020202020202020202020202")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if embed.Kind != EmbedCast || embed.Cast.Fid != 3 || !bytes.Equal(embed.Cast.Hash, bytes.Repeat([]byte{2}, 20)) {
+		t.Errorf("cast embed = %+v", embed)
+	}
+	if embed, err := ParseEmbed("file:./cat.png"); err != nil || embed.Kind != EmbedFile || embed.Path != "./cat.png" {
+		t.Errorf("file embed = %+v, %v", embed, err)
+	}
+
+	for _, value := range []string{"example.com", "cast:3", "cast:3:0x01", "file:"} {
+		if _, err := ParseEmbed(value); err == nil {
+			t.Errorf("ParseEmbed accepted %q", value)
+		}
+	}
+}
+
+func TestNormalizeEmbeds(t *testing.T) {
+	embeds, err := NormalizeEmbeds([]Embed{
+		{Kind: EmbedURL, URL: "HTTPS://Example.COM:443/Path?q=1"},
+		{Kind: EmbedURL, URL: "http://example.com:8080"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if embeds[0].URL != "https://example.com/Path?q=1" || embeds[1].URL != "http://example.com:8080" {
+		t.Errorf("embeds = %+v", embeds)
+	}
+
+	cast := Embed{Kind: EmbedCast, Cast: &protobufs.CastId{Fid: 3, Hash: bytes.Repeat([]byte{2}, 20)}}
+	for name, embeds := range map[string][]Embed{
+		"scheme":    {{Kind: EmbedURL, URL: "ftp://example.com"}},
+		"too long":  {{Kind: EmbedURL, URL: "https://example.com/" + strings.Repeat("a", 250)}},
+		"duplicate": {{Kind: EmbedURL, URL: "https://example.com"}, {Kind: EmbedURL, URL: "https://EXAMPLE.com/"}},
+		"same cast": {cast, cast},
+		"limit":     {cast, {Kind: EmbedURL, URL: "https://a.example"}, {Kind: EmbedURL, URL: "https://b.example"}},
+		"bad cast":  {{Kind: EmbedCast, Cast: &protobufs.CastId{Fid: 3}}},
+	} {
+		if _, err := NormalizeEmbeds(embeds); err == nil {
+			t.Errorf("%s: NormalizeEmbeds accepted %+v", name, embeds)
+		}
+	}
+}
+
+func TestEnterPicksChannelSuggestion(t *testing.T) {
+	m := initialInputModel()
+	m.directory = []channels.Channel{{ID: "dev", FollowerCount: 200}, {ID: "design", FollowerCount: 100}}
+	m.focused = m.channelIndex()
+	m.inputs[m.channelIndex()].SetValue("de")
+	m.filterChannels()
+	if len(m.matches) != 2 {
+		t.Fatalf("matches = %v, want dev and design", m.matches)
+	}
+	want := m.matches[1].ID
+
+	model, _ := m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = model.(inputModel)
+	if got := m.inputs[m.channelIndex()].Value(); got != want {
+		t.Errorf("channel input = %q after Enter, want %q", got, want)
+	}
+	if cmd != nil {
+		t.Error("Enter on a suggestion should fill the input, not submit")
+	}
+}
+
+func TestDryRunAndSignNeverUpload(t *testing.T) {
+	setupAccount(t)
+	uploads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uploads++
+	}))
+	defer server.Close()
+	if err := upload.SaveConfig(upload.Config{Type: upload.TypeHTTP, URL: server.URL + "/"}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "photo.png")
+	os.WriteFile(path, []byte("png"), 0600)
+	castData := CastData{Message: "gm", Embeds: []Embed{{Kind: EmbedFile, Path: path}}}
+	devnet := protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET
+
+	if err := SendCast(castData, SendOptions{DryRun: true, Network: devnet}); err == nil {
+		t.Error("dry run with an attachment succeeded without uploading it")
+	}
+	if err := SendThread([]CastData{castData, {Message: "part 2"}}, SendOptions{DryRun: true, Network: devnet}); err == nil {
+		t.Error("dry run thread with an attachment succeeded without uploading it")
+	}
+	out := filepath.Join(t.TempDir(), "signed.bin")
+	if err := SignCast(castData, devnet, out, message.FormatBinary, false); err == nil {
+		t.Error("mast sign with an attachment succeeded without uploading it")
+	}
+	if uploads != 0 {
+		t.Errorf("dry runs and sign made %d upload requests", uploads)
+	}
+
+	if _, err := UploadEmbeds(castData.Embeds); err != nil || uploads != 1 {
+		t.Errorf("UploadEmbeds = %v after %d uploads, want one upload", err, uploads)
+	}
+}
+
+func TestPreviewURLSkipsHubLookups(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	if err := hub.SaveHubPreference(server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	m := initialInputModel()
+	m.inputs[0].SetValue("cast:dwr:0x" + strings.Repeat("ab", 20))
+	if u := m.previewURL(0); u != "" || requests != 0 {
+		t.Errorf("previewURL(cast embed) = %q after %d hub requests", u, requests)
+	}
+	m.inputs[0].SetValue(" HTTPS://Example.com/post ")
+	if u := m.previewURL(0); u != "https://example.com/post" {
+		t.Errorf("previewURL = %q", u)
+	}
+}
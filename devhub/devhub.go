@@ -0,0 +1,730 @@
+package devhub
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mast/hub"
+	"mast/output"
+	"mast/protobufs"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+type Options struct {
+	Addr     string
+	Network  protobufs.FarcasterNetwork
+	DataFile string
+	// Signers restricts which keys may sign for each fid. When empty every
+	// signer is accepted and remembered for /v1/onChainSignersByFid.
+	Signers map[uint64][][]byte
+}
+
+// NewHandler builds the mock hub's HTTP API without starting a listener
+func NewHandler(opts Options) (http.Handler, error) {
+	s := newStore(opts.Network, opts.Signers)
+	if opts.DataFile != "" {
+		if err := s.load(opts.DataFile); err != nil {
+			return nil, err
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/info", s.handleInfo)
+	mux.HandleFunc("/v1/submitMessage", s.handleSubmit)
+	mux.HandleFunc("/v1/onChainSignersByFid", s.handleSigners)
+	mux.HandleFunc("/v1/storageLimitsByFid", s.handleStorageLimits)
+	mux.HandleFunc("/v1/userNameProofsByFid", s.handleUsernameProofs)
+	mux.HandleFunc("/v1/userNameProofByName", s.handleUsernameProofByName)
+
+	mux.HandleFunc("/v1/castById", s.single(castByID))
+	mux.HandleFunc("/v1/castsByFid", s.list(castsByFid))
+	mux.HandleFunc("/v1/castsByParent", s.list(castsByParent))
+	mux.HandleFunc("/v1/castsByMention", s.list(castsByMention))
+	mux.HandleFunc("/v1/reactionById", s.single(reactionByID))
+	mux.HandleFunc("/v1/reactionsByFid", s.list(reactionsByFid))
+	mux.HandleFunc("/v1/reactionsByCast", s.list(reactionsByTarget))
+	mux.HandleFunc("/v1/reactionsByTarget", s.list(reactionsByTarget))
+	mux.HandleFunc("/v1/linkById", s.single(linkByID))
+	mux.HandleFunc("/v1/linksByFid", s.list(linksByFid))
+	mux.HandleFunc("/v1/linksByTargetFid", s.list(linksByTargetFid))
+	mux.HandleFunc("/v1/userDataByFid", s.handleUserData)
+	mux.HandleFunc("/v1/verificationsByFid", s.list(verificationsByFid))
+
+	// A stand-in for the http uploader, files are kept in memory
+	mux.Handle("/uploads/", &uploads{files: map[string]upload{}})
+	return mux, nil
+}
+
+// Listening is printed once the mock hub is up under --output json
+type Listening struct {
+	HubURL  string `json:"hub_url"`
+	Network string `json:"network"`
+	// DataFile is empty when messages are only kept in memory
+	DataFile  string `json:"data_file,omitempty"`
+	UploadURL string `json:"upload_url"`
+}
+
+// Serve runs the mock hub on opts.Addr until the listener fails
+func Serve(opts Options) error {
+	handler, err := NewHandler(opts)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", opts.Addr)
+	if err != nil {
+		return err
+	}
+
+	if output.IsJSON() {
+		output.Print(Listening{
+			HubURL:    "http://" + listener.Addr().String(),
+			Network:   hub.NetworkName(opts.Network),
+			DataFile:  opts.DataFile,
+			UploadURL: "http://" + listener.Addr().String() + "/uploads",
+		})
+		return http.Serve(listener, handler)
+	}
+
+	storage := "in memory"
+	if opts.DataFile != "" {
+		storage = "in " + opts.DataFile
+	}
+	fmt.Printf("Mock %s hub listening on http://%s, keeping messages %s\n", hub.NetworkName(opts.Network), listener.Addr(), storage)
+	fmt.Printf("Point mast at it with: mast hub (Custom, http://%s) and mast network %s\n", listener.Addr(), hub.NetworkName(opts.Network))
+	fmt.Printf("Attachments can be uploaded to it with: mast uploader --type http --url http://%s/uploads\n", listener.Addr())
+
+	return http.Serve(listener, handler)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, errCode string, details string) {
+	writeJSON(w, status, map[string]interface{}{
+		"errCode":     errCode,
+		"presentable": false,
+		"name":        "HubError",
+		"details":     details,
+	})
+}
+
+func (s *store) handleInfo(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"version":        "mast-devhub",
+		"isSyncing":      false,
+		"nickname":       "mast dev hub",
+		"rootHash":       "",
+		"peerId":         "",
+		"hubOperatorFid": 0,
+		"network":        s.network.String(),
+		"dbStats": map[string]interface{}{
+			"numMessages":    s.count(),
+			"numFidEvents":   0,
+			"numFnameEvents": 0,
+		},
+	})
+}
+
+func (s *store) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "bad_request", "submitMessage requires POST")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	msg := &protobufs.Message{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		msg, err = hub.DecodeMessageJSON(body)
+	} else {
+		err = proto.Unmarshal(body, msg)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request.parse_failure", err.Error())
+		return
+	}
+
+	stored, err := s.merge(msg, true)
+	if err != nil {
+		var mergeErr *mergeError
+		if errors.As(err, &mergeErr) {
+			log.Printf("rejected 0x%x: %s", msg.Hash, mergeErr.details)
+			writeError(w, http.StatusBadRequest, mergeErr.errCode, mergeErr.details)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "unavailable.storage_failure", err.Error())
+		return
+	}
+
+	log.Printf("merged %s 0x%x from fid %d", stored.data.Type, msg.Hash, stored.data.Fid)
+	writeMessage(w, stored)
+}
+
+func writeMessage(w http.ResponseWriter, m *storedMessage) {
+	body, err := encodeStored(m)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unavailable", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func encodeStored(m *storedMessage) (map[string]interface{}, error) {
+	msg := proto.Clone(m.msg).(*protobufs.Message)
+	msg.Data = m.data
+	return hub.EncodeMessageJSON(msg)
+}
+
+func (s *store) handleSigners(w http.ResponseWriter, r *http.Request) {
+	fid, err := uintParam(r, "fid")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request.validation_failure", err.Error())
+		return
+	}
+
+	if signer := r.URL.Query().Get("signer"); signer != "" {
+		key, err := hex.DecodeString(strings.TrimPrefix(signer, "0x"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request.validation_failure", "signer must be hex")
+			return
+		}
+		if !s.signerRegistered(fid, key) {
+			writeError(w, http.StatusNotFound, "not_found", fmt.Sprintf("no signer 0x%x for fid %d", key, fid))
+			return
+		}
+		writeJSON(w, http.StatusOK, signerEvent(fid, key))
+		return
+	}
+
+	events := []interface{}{}
+	for _, key := range s.fidSigners(fid) {
+		events = append(events, signerEvent(fid, key))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"events": events})
+}
+
+func signerEvent(fid uint64, key []byte) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "EVENT_TYPE_SIGNER",
+		"fid":  fid,
+		"signerEventBody": map[string]interface{}{
+			"key":       "0x" + hex.EncodeToString(key),
+			"keyType":   1,
+			"eventType": "SIGNER_EVENT_TYPE_ADD",
+		},
+	}
+}
+
+func (s *store) handleStorageLimits(w http.ResponseWriter, r *http.Request) {
+	fid, err := uintParam(r, "fid")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request.validation_failure", err.Error())
+		return
+	}
+
+	stores := []struct {
+		name  string
+		limit int
+		types []protobufs.MessageType
+	}{
+		{"CASTS", 5000, []protobufs.MessageType{protobufs.MessageType_MESSAGE_TYPE_CAST_ADD}},
+		{"LINKS", 2500, []protobufs.MessageType{protobufs.MessageType_MESSAGE_TYPE_LINK_ADD}},
+		{"REACTIONS", 2500, []protobufs.MessageType{protobufs.MessageType_MESSAGE_TYPE_REACTION_ADD}},
+		{"USER_DATA", 50, []protobufs.MessageType{protobufs.MessageType_MESSAGE_TYPE_USER_DATA_ADD}},
+		{"USERNAME_PROOFS", 5, []protobufs.MessageType{protobufs.MessageType_MESSAGE_TYPE_USERNAME_PROOF}},
+		{"VERIFICATIONS", 25, []protobufs.MessageType{protobufs.MessageType_MESSAGE_TYPE_VERIFICATION_ADD_ETH_ADDRESS}},
+	}
+
+	limits := []interface{}{}
+	for _, store := range stores {
+		used := len(s.find(func(m *storedMessage) bool {
+			return m.data.Fid == fid && hasType(m, store.types...)
+		}, false))
+		limits = append(limits, map[string]interface{}{
+			"storeType": "STORE_TYPE_" + store.name,
+			"name":      store.name,
+			"limit":     store.limit,
+			"used":      used,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"limits": limits, "units": 1})
+}
+
+func (s *store) handleUsernameProofs(w http.ResponseWriter, r *http.Request) {
+	fid, err := uintParam(r, "fid")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request.validation_failure", err.Error())
+		return
+	}
+
+	proofs := []interface{}{}
+	for _, m := range s.find(func(m *storedMessage) bool {
+		return hasType(m, protobufs.MessageType_MESSAGE_TYPE_USERNAME_PROOF) && m.data.GetUsernameProofBody().GetFid() == fid
+	}, false) {
+		proofs = append(proofs, hub.EncodeJSON(m.data.GetUsernameProofBody()))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"proofs": proofs})
+}
+
+func (s *store) handleUsernameProofByName(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	matches := s.find(func(m *storedMessage) bool {
+		return hasType(m, protobufs.MessageType_MESSAGE_TYPE_USERNAME_PROOF) && string(m.data.GetUsernameProofBody().GetName()) == name
+	}, true)
+	if len(matches) == 0 {
+		writeError(w, http.StatusNotFound, "not_found", fmt.Sprintf("no username proof for %q", name))
+		return
+	}
+	writeJSON(w, http.StatusOK, hub.EncodeJSON(matches[0].data.GetUsernameProofBody()))
+}
+
+func (s *store) handleUserData(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("user_data_type") != "" {
+		s.single(userDataByType)(w, r)
+		return
+	}
+	s.list(userDataByFid)(w, r)
+}
+
+// filterFunc turns query parameters into a predicate over stored messages
+type filterFunc func(r *http.Request) (func(*storedMessage) bool, error)
+
+func (s *store) single(filter filterFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		match, err := filter(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request.validation_failure", err.Error())
+			return
+		}
+		matches := s.find(match, true)
+		if len(matches) == 0 {
+			writeError(w, http.StatusNotFound, "not_found", "message not found")
+			return
+		}
+		writeMessage(w, matches[0])
+	}
+}
+
+func (s *store) list(filter filterFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		match, err := filter(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request.validation_failure", err.Error())
+			return
+		}
+
+		start, stop, err := timestampRange(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request.validation_failure", err.Error())
+			return
+		}
+		inRange := func(m *storedMessage) bool {
+			return match(m) && m.data.Timestamp >= start && m.data.Timestamp <= stop
+		}
+
+		reverse, _ := strconv.ParseBool(r.URL.Query().Get("reverse"))
+		matches := s.find(inRange, reverse)
+
+		offset, pageSize, err := page(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request.validation_failure", err.Error())
+			return
+		}
+
+		messages := []interface{}{}
+		end := offset + pageSize
+		if end > len(matches) {
+			end = len(matches)
+		}
+		for i := offset; i < end; i++ {
+			body, err := encodeStored(matches[i])
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "unavailable", err.Error())
+				return
+			}
+			messages = append(messages, body)
+		}
+
+		nextPageToken := ""
+		if end < len(matches) {
+			nextPageToken = base64.StdEncoding.EncodeToString([]byte(strconv.Itoa(end)))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"messages":      messages,
+			"nextPageToken": nextPageToken,
+		})
+	}
+}
+
+func page(r *http.Request) (int, int, error) {
+	offset := 0
+	if token := r.URL.Query().Get("pageToken"); token != "" {
+		raw, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid pageToken")
+		}
+		offset, err = strconv.Atoi(string(raw))
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid pageToken")
+		}
+	}
+
+	pageSize := defaultPageSize
+	if size := r.URL.Query().Get("pageSize"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("invalid pageSize")
+		}
+		pageSize = n
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return offset, pageSize, nil
+}
+
+func timestampRange(r *http.Request) (uint32, uint32, error) {
+	start, stop := uint32(0), ^uint32(0)
+	for name, target := range map[string]*uint32{"startTimestamp": &start, "stopTimestamp": &stop} {
+		if value := r.URL.Query().Get(name); value != "" {
+			n, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return 0, 0, fmt.Errorf("invalid %s", name)
+			}
+			*target = uint32(n)
+		}
+	}
+	return start, stop, nil
+}
+
+func uintParam(r *http.Request, name string) (uint64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, fmt.Errorf("%s is required", name)
+	}
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return n, nil
+}
+
+func hashParam(r *http.Request, name string) ([]byte, error) {
+	value := r.URL.Query().Get(name)
+	hash, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+	if err != nil || len(hash) == 0 {
+		return nil, fmt.Errorf("invalid %s", name)
+	}
+	return hash, nil
+}
+
+// enumParam accepts an enum's full name, its short name or its number
+func enumParam(r *http.Request, name string, prefix string, values map[string]int32) (int32, bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, false, nil
+	}
+	if n, err := strconv.Atoi(value); err == nil {
+		return int32(n), true, nil
+	}
+	key := strings.ToUpper(value)
+	if !strings.HasPrefix(key, prefix) {
+		key = prefix + key
+	}
+	n, ok := values[key]
+	if !ok {
+		return 0, false, fmt.Errorf("invalid %s", name)
+	}
+	return n, true, nil
+}
+
+func hasType(m *storedMessage, types ...protobufs.MessageType) bool {
+	for _, t := range types {
+		if m.data.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+func castByID(r *http.Request) (func(*storedMessage) bool, error) {
+	fid, err := uintParam(r, "fid")
+	if err != nil {
+		return nil, err
+	}
+	hash, err := hashParam(r, "hash")
+	if err != nil {
+		return nil, err
+	}
+	return func(m *storedMessage) bool {
+		return hasType(m, protobufs.MessageType_MESSAGE_TYPE_CAST_ADD) && m.data.Fid == fid && bytes.Equal(m.msg.Hash, hash)
+	}, nil
+}
+
+func castsByFid(r *http.Request) (func(*storedMessage) bool, error) {
+	fid, err := uintParam(r, "fid")
+	if err != nil {
+		return nil, err
+	}
+	return func(m *storedMessage) bool {
+		return hasType(m, protobufs.MessageType_MESSAGE_TYPE_CAST_ADD) && m.data.Fid == fid
+	}, nil
+}
+
+func castsByParent(r *http.Request) (func(*storedMessage) bool, error) {
+	if url := r.URL.Query().Get("url"); url != "" {
+		return func(m *storedMessage) bool {
+			return hasType(m, protobufs.MessageType_MESSAGE_TYPE_CAST_ADD) && m.data.GetCastAddBody().GetParentUrl() == url
+		}, nil
+	}
+
+	fid, err := uintParam(r, "fid")
+	if err != nil {
+		return nil, fmt.Errorf("either url or fid and hash are required")
+	}
+	hash, err := hashParam(r, "hash")
+	if err != nil {
+		return nil, err
+	}
+	parent := &protobufs.CastId{Fid: fid, Hash: hash}
+	return func(m *storedMessage) bool {
+		return hasType(m, protobufs.MessageType_MESSAGE_TYPE_CAST_ADD) && sameCastID(m.data.GetCastAddBody().GetParentCastId(), parent)
+	}, nil
+}
+
+func castsByMention(r *http.Request) (func(*storedMessage) bool, error) {
+	fid, err := uintParam(r, "fid")
+	if err != nil {
+		return nil, err
+	}
+	return func(m *storedMessage) bool {
+		if !hasType(m, protobufs.MessageType_MESSAGE_TYPE_CAST_ADD) {
+			return false
+		}
+		for _, mention := range m.data.GetCastAddBody().GetMentions() {
+			if mention == fid {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func reactionTypeFilter(r *http.Request) (func(*storedMessage) bool, error) {
+	reactionType, set, err := enumParam(r, "reaction_type", "REACTION_TYPE_", protobufs.ReactionType_value)
+	if err != nil {
+		return nil, err
+	}
+	return func(m *storedMessage) bool {
+		return hasType(m, protobufs.MessageType_MESSAGE_TYPE_REACTION_ADD) &&
+			(!set || int32(m.data.GetReactionBody().GetType()) == reactionType)
+	}, nil
+}
+
+func reactionByID(r *http.Request) (func(*storedMessage) bool, error) {
+	fid, err := uintParam(r, "fid")
+	if err != nil {
+		return nil, err
+	}
+	byTarget, err := reactionsByTarget(r)
+	if err != nil {
+		return nil, err
+	}
+	return func(m *storedMessage) bool {
+		return m.data.Fid == fid && byTarget(m)
+	}, nil
+}
+
+func reactionsByFid(r *http.Request) (func(*storedMessage) bool, error) {
+	fid, err := uintParam(r, "fid")
+	if err != nil {
+		return nil, err
+	}
+	byType, err := reactionTypeFilter(r)
+	if err != nil {
+		return nil, err
+	}
+	return func(m *storedMessage) bool {
+		return byType(m) && m.data.Fid == fid
+	}, nil
+}
+
+func reactionsByTarget(r *http.Request) (func(*storedMessage) bool, error) {
+	byType, err := reactionTypeFilter(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if url := r.URL.Query().Get("url"); url != "" {
+		return func(m *storedMessage) bool {
+			return byType(m) && m.data.GetReactionBody().GetTargetUrl() == url
+		}, nil
+	}
+
+	fid, err := uintParam(r, "target_fid")
+	if err != nil {
+		return nil, err
+	}
+	hash, err := hashParam(r, "target_hash")
+	if err != nil {
+		return nil, err
+	}
+	target := &protobufs.CastId{Fid: fid, Hash: hash}
+	return func(m *storedMessage) bool {
+		return byType(m) && sameCastID(m.data.GetReactionBody().GetTargetCastId(), target)
+	}, nil
+}
+
+func linkTypeFilter(r *http.Request) func(*storedMessage) bool {
+	linkType := r.URL.Query().Get("link_type")
+	return func(m *storedMessage) bool {
+		return hasType(m, protobufs.MessageType_MESSAGE_TYPE_LINK_ADD) &&
+			(linkType == "" || m.data.GetLinkBody().GetType() == linkType)
+	}
+}
+
+func linkByID(r *http.Request) (func(*storedMessage) bool, error) {
+	fid, err := uintParam(r, "fid")
+	if err != nil {
+		return nil, err
+	}
+	targetFid, err := uintParam(r, "target_fid")
+	if err != nil {
+		return nil, err
+	}
+	byType := linkTypeFilter(r)
+	return func(m *storedMessage) bool {
+		return byType(m) && m.data.Fid == fid && m.data.GetLinkBody().GetTargetFid() == targetFid
+	}, nil
+}
+
+func linksByFid(r *http.Request) (func(*storedMessage) bool, error) {
+	fid, err := uintParam(r, "fid")
+	if err != nil {
+		return nil, err
+	}
+	byType := linkTypeFilter(r)
+	return func(m *storedMessage) bool {
+		return byType(m) && m.data.Fid == fid
+	}, nil
+}
+
+func linksByTargetFid(r *http.Request) (func(*storedMessage) bool, error) {
+	targetFid, err := uintParam(r, "target_fid")
+	if err != nil {
+		return nil, err
+	}
+	byType := linkTypeFilter(r)
+	return func(m *storedMessage) bool {
+		return byType(m) && m.data.GetLinkBody().GetTargetFid() == targetFid
+	}, nil
+}
+
+func userDataByFid(r *http.Request) (func(*storedMessage) bool, error) {
+	fid, err := uintParam(r, "fid")
+	if err != nil {
+		return nil, err
+	}
+	return func(m *storedMessage) bool {
+		return hasType(m, protobufs.MessageType_MESSAGE_TYPE_USER_DATA_ADD) && m.data.Fid == fid
+	}, nil
+}
+
+func userDataByType(r *http.Request) (func(*storedMessage) bool, error) {
+	byFid, err := userDataByFid(r)
+	if err != nil {
+		return nil, err
+	}
+	userDataType, _, err := enumParam(r, "user_data_type", "USER_DATA_TYPE_", protobufs.UserDataType_value)
+	if err != nil {
+		return nil, err
+	}
+	return func(m *storedMessage) bool {
+		return byFid(m) && int32(m.data.GetUserDataBody().GetType()) == userDataType
+	}, nil
+}
+
+func verificationsByFid(r *http.Request) (func(*storedMessage) bool, error) {
+	fid, err := uintParam(r, "fid")
+	if err != nil {
+		return nil, err
+	}
+	return func(m *storedMessage) bool {
+		return hasType(m, protobufs.MessageType_MESSAGE_TYPE_VERIFICATION_ADD_ETH_ADDRESS) && m.data.Fid == fid
+	}, nil
+}
+
+type upload struct {
+	contentType string
+	data        []byte
+}
+
+// uploads accepts files PUT under /uploads/ and serves them back, so
+// attachments can be tried without a storage provider
+type uploads struct {
+	mu    sync.RWMutex
+	files map[string]upload
+}
+
+func (u *uploads) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/uploads/")
+	if name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(io.LimitReader(r.Body, 32<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		u.mu.Lock()
+		u.files[name] = upload{contentType: r.Header.Get("Content-Type"), data: data}
+		u.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet, http.MethodHead:
+		u.mu.RLock()
+		file, ok := u.files[name]
+		u.mu.RUnlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if file.contentType != "" {
+			w.Header().Set("Content-Type", file.contentType)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(file.data)))
+		if r.Method == http.MethodGet {
+			w.Write(file.data)
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
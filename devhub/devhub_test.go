@@ -0,0 +1,227 @@
+package devhub
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mast/hub"
+	"mast/message"
+	"mast/protobufs"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+const testPrivateKey = "0707070707070707070707070707070707070707070707070707070707070707"
+
+func newTestHub(t *testing.T, opts Options) *httptest.Server {
+	t.Helper()
+	if opts.Network == protobufs.FarcasterNetwork_FARCASTER_NETWORK_NONE {
+		opts.Network = protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET
+	}
+	handler, err := NewHandler(opts)
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func signCast(t *testing.T, text string, network protobufs.FarcasterNetwork, ts uint32) *protobufs.Message {
+	t.Helper()
+	msg, err := message.Sign(&protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_CAST_ADD,
+		Fid:       6596,
+		Timestamp: ts,
+		Network:   network,
+		Body: &protobufs.MessageData_CastAddBody{
+			CastAddBody: &protobufs.CastAddBody{Text: text},
+		},
+	}, testPrivateKey)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	return msg
+}
+
+func submit(t *testing.T, server *httptest.Server, msg *protobufs.Message) (int, map[string]interface{}) {
+	t.Helper()
+	msgBytes, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(server.URL+"/v1/submitMessage", "application/octet-stream", bytes.NewReader(msgBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+func get(t *testing.T, server *httptest.Server, path string) (int, []byte) {
+	t.Helper()
+	resp, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, body
+}
+
+type listResponse struct {
+	Messages      []json.RawMessage `json:"messages"`
+	NextPageToken string            `json:"nextPageToken"`
+}
+
+func TestSubmitValidatesLikeAHub(t *testing.T) {
+	server := newTestHub(t, Options{})
+	now := message.Timestamp(time.Now())
+	devnet := protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET
+
+	msg := signCast(t, "gm", devnet, now)
+	status, body := submit(t, server, msg)
+	if status != http.StatusOK || body["hash"] != fmt.Sprintf("0x%x", msg.Hash) {
+		t.Fatalf("submit = %d %v", status, body)
+	}
+
+	status, body = submit(t, server, msg)
+	if status != http.StatusBadRequest || body["errCode"] != "bad_request.duplicate" {
+		t.Errorf("duplicate submit = %d %v", status, body)
+	}
+
+	status, body = submit(t, server, signCast(t, "wrong network", protobufs.FarcasterNetwork_FARCASTER_NETWORK_MAINNET, now))
+	if status != http.StatusBadRequest || body["errCode"] != "bad_request.validation_failure" {
+		t.Errorf("mainnet submit = %d %v", status, body)
+	}
+
+	tampered := signCast(t, "tampered", devnet, now)
+	tampered.Signature[0] ^= 0xff
+	status, body = submit(t, server, tampered)
+	if status != http.StatusBadRequest || body["errCode"] != "bad_request.validation_failure" {
+		t.Errorf("tampered submit = %d %v", status, body)
+	}
+
+	// The submit path in mast should treat the duplicate as such
+	var hubErr *hub.HubError
+	if err := submitViaHubPackage(t, server, msg); !errors.As(err, &hubErr) || !hubErr.Duplicate() {
+		t.Errorf("hub.SubmitMessage duplicate error = %v", err)
+	}
+}
+
+func submitViaHubPackage(t *testing.T, server *httptest.Server, msg *protobufs.Message) error {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	if err := hub.SaveHubPreference(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	msgBytes, _ := proto.Marshal(msg)
+	_, err := hub.SubmitMessage(msgBytes)
+	return err
+}
+
+func TestCastsByFidPagesAndRemoves(t *testing.T) {
+	server := newTestHub(t, Options{})
+	devnet := protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET
+	now := message.Timestamp(time.Now())
+
+	var casts []*protobufs.Message
+	for i := 0; i < 3; i++ {
+		msg := signCast(t, fmt.Sprintf("cast %d", i), devnet, now-uint32(10-i))
+		if status, body := submit(t, server, msg); status != http.StatusOK {
+			t.Fatalf("submit = %d %v", status, body)
+		}
+		casts = append(casts, msg)
+	}
+
+	status, body := get(t, server, "/v1/castsByFid?fid=6596&pageSize=2&reverse=true")
+	var page listResponse
+	if status != http.StatusOK || json.Unmarshal(body, &page) != nil {
+		t.Fatalf("castsByFid = %d %s", status, body)
+	}
+	if len(page.Messages) != 2 || page.NextPageToken == "" {
+		t.Fatalf("first page has %d messages, token %q", len(page.Messages), page.NextPageToken)
+	}
+	newest, err := hub.DecodeMessageJSON(page.Messages[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newest.GetData().GetCastAddBody().GetText() != "cast 2" {
+		t.Errorf("newest cast = %q", newest.GetData().GetCastAddBody().GetText())
+	}
+
+	_, body = get(t, server, "/v1/castsByFid?fid=6596&pageSize=2&reverse=true&pageToken="+page.NextPageToken)
+	json.Unmarshal(body, &page)
+	if len(page.Messages) != 1 || page.NextPageToken != "" {
+		t.Errorf("second page has %d messages, token %q", len(page.Messages), page.NextPageToken)
+	}
+
+	remove, err := message.Sign(&protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_CAST_REMOVE,
+		Fid:       6596,
+		Timestamp: now,
+		Network:   devnet,
+		Body: &protobufs.MessageData_CastRemoveBody{
+			CastRemoveBody: &protobufs.CastRemoveBody{TargetHash: casts[0].Hash},
+		},
+	}, testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, body := submit(t, server, remove); status != http.StatusOK {
+		t.Fatalf("remove = %d %v", status, body)
+	}
+
+	_, body = get(t, server, "/v1/castsByFid?fid=6596")
+	json.Unmarshal(body, &page)
+	if len(page.Messages) != 2 {
+		t.Errorf("after remove castsByFid returned %d casts, want 2", len(page.Messages))
+	}
+
+	status, _ = get(t, server, fmt.Sprintf("/v1/castById?fid=6596&hash=0x%x", casts[0].Hash))
+	if status != http.StatusNotFound {
+		t.Errorf("removed castById = %d, want 404", status)
+	}
+}
+
+func TestStrictSignersAndPersistence(t *testing.T) {
+	dataFile := filepath.Join(t.TempDir(), "hub.bin")
+	key, err := message.ParsePrivateKey(testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public := key.Public().(ed25519.PublicKey)
+	devnet := protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET
+	now := message.Timestamp(time.Now())
+
+	strict := newTestHub(t, Options{DataFile: dataFile, Signers: map[uint64][][]byte{6596: {make([]byte, 32)}}})
+	if status, _ := submit(t, strict, signCast(t, "unregistered", devnet, now)); status != http.StatusBadRequest {
+		t.Errorf("unregistered signer accepted with status %d", status)
+	}
+
+	server := newTestHub(t, Options{DataFile: dataFile, Signers: map[uint64][][]byte{6596: {public}}})
+	if status, body := submit(t, server, signCast(t, "persisted", devnet, now)); status != http.StatusOK {
+		t.Fatalf("submit = %d %v", status, body)
+	}
+	if status, _ := get(t, server, fmt.Sprintf("/v1/onChainSignersByFid?fid=6596&signer=0x%x", public)); status != http.StatusOK {
+		t.Errorf("onChainSignersByFid = %d", status)
+	}
+
+	reloaded := newTestHub(t, Options{DataFile: dataFile})
+	_, body := get(t, reloaded, "/v1/castsByFid?fid=6596")
+	var page listResponse
+	json.Unmarshal(body, &page)
+	if len(page.Messages) != 1 {
+		t.Errorf("reloaded hub has %d casts, want 1", len(page.Messages))
+	}
+}
@@ -0,0 +1,274 @@
+package devhub
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"mast/hub"
+	"mast/inspect"
+	"mast/message"
+	"mast/protobufs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type storedMessage struct {
+	msg   *protobufs.Message
+	data  *protobufs.MessageData
+	order int
+}
+
+// mergeError mirrors the errCode/details body real hubs send back
+type mergeError struct {
+	errCode string
+	details string
+}
+
+func (e *mergeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.errCode, e.details)
+}
+
+// store keeps the messages that are currently active, the way a hub's CRDT
+// sets would after applying removes and replacements
+type store struct {
+	mu            sync.RWMutex
+	network       protobufs.FarcasterNetwork
+	messages      []*storedMessage
+	seen          map[string]bool
+	signers       map[uint64]map[string]bool
+	strictSigners bool
+	dataFile      string
+	merged        int
+}
+
+func newStore(network protobufs.FarcasterNetwork, signers map[uint64][][]byte) *store {
+	s := &store{
+		network: network,
+		seen:    map[string]bool{},
+		signers: map[uint64]map[string]bool{},
+	}
+	for fid, keys := range signers {
+		for _, key := range keys {
+			s.addSigner(fid, key)
+		}
+		s.strictSigners = true
+	}
+	return s
+}
+
+func (s *store) addSigner(fid uint64, key []byte) {
+	if s.signers[fid] == nil {
+		s.signers[fid] = map[string]bool{}
+	}
+	s.signers[fid][string(key)] = true
+}
+
+// load replays every message saved in dataFile and keeps appending to it
+func (s *store) load(dataFile string) error {
+	s.dataFile = dataFile
+	f, err := os.Open(dataFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	msgs, err := message.Read(f, message.FormatBinary)
+	if err != nil {
+		return fmt.Errorf("Failed to load %s: %v", dataFile, err)
+	}
+
+	for _, msg := range msgs {
+		if _, err := s.merge(msg, false); err != nil {
+			return fmt.Errorf("Failed to replay %s: %v", dataFile, err)
+		}
+	}
+	return nil
+}
+
+func (s *store) merge(msg *protobufs.Message, persist bool) (*storedMessage, error) {
+	msgData, err := inspect.Validate(msg, time.Now())
+	if err != nil {
+		return nil, &mergeError{errCode: "bad_request.validation_failure", details: err.Error()}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if msgData.Network != s.network {
+		return nil, &mergeError{
+			errCode: "bad_request.validation_failure",
+			details: fmt.Sprintf("incorrect network: message is for %s, hub runs %s", hub.NetworkName(msgData.Network), hub.NetworkName(s.network)),
+		}
+	}
+
+	if s.strictSigners && !s.signers[msgData.Fid][string(msg.Signer)] {
+		return nil, &mergeError{errCode: "bad_request.validation_failure", details: fmt.Sprintf("invalid signer: signer 0x%x is not registered for fid %d", msg.Signer, msgData.Fid)}
+	}
+
+	if s.seen[string(msg.Hash)] {
+		return nil, &mergeError{errCode: "bad_request.duplicate", details: "message has already been merged"}
+	}
+
+	if persist && s.dataFile != "" {
+		f, err := os.OpenFile(s.dataFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, err
+		}
+		err = message.Write(f, []*protobufs.Message{msg}, message.FormatBinary)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.seen[string(msg.Hash)] = true
+	if !s.strictSigners {
+		s.addSigner(msgData.Fid, msg.Signer)
+	}
+
+	stored := &storedMessage{msg: msg, data: msgData, order: s.merged}
+	s.merged++
+	s.apply(stored)
+	return stored, nil
+}
+
+// apply drops whatever stored message the new one removes or replaces, then
+// keeps it unless it is itself a remove
+func (s *store) apply(m *storedMessage) {
+	kept := s.messages[:0]
+	for _, existing := range s.messages {
+		if !supersedes(m.data, existing) {
+			kept = append(kept, existing)
+		}
+	}
+	s.messages = kept
+
+	switch m.data.Type {
+	case protobufs.MessageType_MESSAGE_TYPE_CAST_REMOVE,
+		protobufs.MessageType_MESSAGE_TYPE_REACTION_REMOVE,
+		protobufs.MessageType_MESSAGE_TYPE_LINK_REMOVE,
+		protobufs.MessageType_MESSAGE_TYPE_VERIFICATION_REMOVE:
+		return
+	}
+	s.messages = append(s.messages, m)
+}
+
+func supersedes(data *protobufs.MessageData, existing *storedMessage) bool {
+	old := existing.data
+	if old.Fid != data.Fid && data.Type != protobufs.MessageType_MESSAGE_TYPE_USERNAME_PROOF {
+		return false
+	}
+
+	switch data.Type {
+	case protobufs.MessageType_MESSAGE_TYPE_CAST_REMOVE:
+		return old.Type == protobufs.MessageType_MESSAGE_TYPE_CAST_ADD &&
+			bytes.Equal(existing.msg.Hash, data.GetCastRemoveBody().GetTargetHash())
+	case protobufs.MessageType_MESSAGE_TYPE_REACTION_ADD, protobufs.MessageType_MESSAGE_TYPE_REACTION_REMOVE:
+		return old.Type == protobufs.MessageType_MESSAGE_TYPE_REACTION_ADD &&
+			sameReaction(old.GetReactionBody(), data.GetReactionBody())
+	case protobufs.MessageType_MESSAGE_TYPE_LINK_ADD, protobufs.MessageType_MESSAGE_TYPE_LINK_REMOVE:
+		return old.Type == protobufs.MessageType_MESSAGE_TYPE_LINK_ADD &&
+			old.GetLinkBody().GetType() == data.GetLinkBody().GetType() &&
+			old.GetLinkBody().GetTargetFid() == data.GetLinkBody().GetTargetFid()
+	case protobufs.MessageType_MESSAGE_TYPE_VERIFICATION_ADD_ETH_ADDRESS:
+		return old.Type == protobufs.MessageType_MESSAGE_TYPE_VERIFICATION_ADD_ETH_ADDRESS &&
+			bytes.Equal(old.GetVerificationAddEthAddressBody().GetAddress(), data.GetVerificationAddEthAddressBody().GetAddress())
+	case protobufs.MessageType_MESSAGE_TYPE_VERIFICATION_REMOVE:
+		return old.Type == protobufs.MessageType_MESSAGE_TYPE_VERIFICATION_ADD_ETH_ADDRESS &&
+			bytes.Equal(old.GetVerificationAddEthAddressBody().GetAddress(), data.GetVerificationRemoveBody().GetAddress())
+	case protobufs.MessageType_MESSAGE_TYPE_USER_DATA_ADD:
+		return old.Type == protobufs.MessageType_MESSAGE_TYPE_USER_DATA_ADD &&
+			old.GetUserDataBody().GetType() == data.GetUserDataBody().GetType()
+	case protobufs.MessageType_MESSAGE_TYPE_USERNAME_PROOF:
+		return old.Type == protobufs.MessageType_MESSAGE_TYPE_USERNAME_PROOF &&
+			bytes.Equal(old.GetUsernameProofBody().GetName(), data.GetUsernameProofBody().GetName())
+	}
+	return false
+}
+
+func sameReaction(a *protobufs.ReactionBody, b *protobufs.ReactionBody) bool {
+	if a.GetType() != b.GetType() {
+		return false
+	}
+	if a.GetTargetUrl() != "" || b.GetTargetUrl() != "" {
+		return a.GetTargetUrl() == b.GetTargetUrl()
+	}
+	return sameCastID(a.GetTargetCastId(), b.GetTargetCastId())
+}
+
+func sameCastID(a *protobufs.CastId, b *protobufs.CastId) bool {
+	return a != nil && b != nil && a.Fid == b.Fid && bytes.Equal(a.Hash, b.Hash)
+}
+
+// find returns the active messages matching filter ordered by timestamp,
+// newest first when reverse is set
+func (s *store) find(filter func(*storedMessage) bool, reverse bool) []*storedMessage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matches []*storedMessage
+	for _, m := range s.messages {
+		if filter(m) {
+			matches = append(matches, m)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.data.Timestamp != b.data.Timestamp {
+			return (a.data.Timestamp < b.data.Timestamp) != reverse
+		}
+		return (a.order < b.order) != reverse
+	})
+	return matches
+}
+
+func (s *store) count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.messages)
+}
+
+func (s *store) signerRegistered(fid uint64, key []byte) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.strictSigners {
+		return true
+	}
+	return s.signers[fid][string(key)]
+}
+
+func (s *store) fidSigners(fid uint64) [][]byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var keys [][]byte
+	for key := range s.signers[fid] {
+		keys = append(keys, []byte(key))
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+	return keys
+}
+
+// ParseSigner reads a "fid:0xpublickey" pair as passed to --signer
+func ParseSigner(value string) (uint64, []byte, error) {
+	fidString, keyHex, ok := strings.Cut(value, ":")
+	if !ok {
+		return 0, nil, fmt.Errorf("Invalid signer %q, expected fid:0xpublickey", value)
+	}
+	fid, err := strconv.ParseUint(fidString, 10, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Invalid signer fid %q", fidString)
+	}
+	key, err := hex.DecodeString(strings.TrimPrefix(keyHex, "0x"))
+	if err != nil || len(key) != 32 {
+		return 0, nil, fmt.Errorf("Invalid signer key %q, expected a 32 byte hex public key", keyHex)
+	}
+	return fid, key, nil
+}
@@ -0,0 +1,87 @@
+package export
+
+import (
+	"fmt"
+	"mast/auth"
+	"mast/hub"
+	"mast/output"
+	"path/filepath"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	okStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#7C65C1"))
+	mutedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#767676"))
+	warnStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6B6B"))
+)
+
+// Summary is what an export prints as under --output json
+type Summary struct {
+	Fid  uint64 `json:"fid"`
+	File string `json:"file"`
+	// RawFile is set when signed messages were exported too
+	RawFile string `json:"raw_file,omitempty"`
+	// New counts the messages added from each store
+	New     map[string]int `json:"new"`
+	Total   int            `json:"total"`
+	Skipped int            `json:"skipped,omitempty"`
+}
+
+// Export resolves user, or the configured account when user is empty, and
+// archives its messages into dir, printing a line per store, or a Summary
+// under --output json
+func Export(user string, dir string, format string, raw bool) error {
+	var fid uint64
+	var err error
+	if user == "" {
+		fid, _, err = auth.FindFidAndPrivateKey()
+	} else {
+		fid, err = hub.ParseUser(user)
+	}
+	if err != nil {
+		return err
+	}
+	if dir == "" {
+		dir = fmt.Sprintf("mast-export-%d", fid)
+	}
+
+	opts := Options{Fid: fid, Dir: dir, Format: format, Raw: raw}
+	total, skipped := 0, 0
+	added := map[string]int{}
+	state, err := Run(opts, func(p Progress) {
+		if !p.Done {
+			return
+		}
+		total += p.New
+		skipped += p.Skipped
+		added[p.Store] = p.New
+		if output.IsJSON() {
+			return
+		}
+		fmt.Printf("%-15s %s\n", p.Store, okStyle.Render(fmt.Sprintf("%d new", p.New)))
+	})
+	if err != nil {
+		return err
+	}
+
+	archived := 0
+	for _, s := range state.Stores {
+		archived += s.Count
+	}
+	if output.IsJSON() {
+		summary := Summary{Fid: fid, File: filepath.Join(dir, fileNames[format]), New: added, Total: archived, Skipped: skipped}
+		if raw {
+			summary.RawFile = filepath.Join(dir, rawFile)
+		}
+		return output.Print(summary)
+	}
+	fmt.Println(mutedStyle.Render(fmt.Sprintf("Exported %d new messages for fid %d to %s, %d in total", total, fid, filepath.Join(dir, fileNames[format]), archived)))
+	if raw {
+		fmt.Println(mutedStyle.Render(fmt.Sprintf("Signed messages appended to %s", filepath.Join(dir, rawFile))))
+	}
+	if skipped > 0 {
+		fmt.Println(warnStyle.Render(fmt.Sprintf("%d messages could not be rebuilt byte for byte from the hub's JSON and were left out of %s", skipped, rawFile)))
+	}
+	return nil
+}
@@ -0,0 +1,388 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mast/hub"
+	"mast/message"
+	"mast/protobufs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatJSONL    = "jsonl"
+	FormatCSV      = "csv"
+	FormatMarkdown = "markdown"
+
+	pageSize  = 1000
+	stateFile = "state.json"
+	rawFile   = "messages.bin"
+)
+
+// Store is one kind of message and the endpoint that lists it by fid
+type Store struct {
+	Name string
+	Path string
+}
+
+var Stores = []Store{
+	{"casts", "/v1/castsByFid"},
+	{"reactions", "/v1/reactionsByFid"},
+	{"links", "/v1/linksByFid"},
+	{"user data", "/v1/userDataByFid"},
+	{"verifications", "/v1/verificationsByFid"},
+}
+
+var fileNames = map[string]string{
+	FormatJSONL:    "messages.jsonl",
+	FormatCSV:      "messages.csv",
+	FormatMarkdown: "messages.md",
+}
+
+// Options describes an export. Dir keeps the output files and the state
+// that lets the next run pick up where this one stopped.
+type Options struct {
+	Fid    uint64
+	Dir    string
+	Format string
+	Raw    bool
+}
+
+// State is saved in the export directory after every page
+type State struct {
+	Fid    uint64                 `json:"fid"`
+	Format string                 `json:"format"`
+	Raw    bool                   `json:"raw"`
+	Stores map[string]*StoreState `json:"stores"`
+}
+
+// StoreState is the newest timestamp exported from a store and the hashes
+// exported at that timestamp, since more messages can share it
+type StoreState struct {
+	Timestamp uint32   `json:"timestamp"`
+	Hashes    []string `json:"hashes"`
+	Count     int      `json:"count"`
+}
+
+func (s *StoreState) seen(ts uint32, hash string) bool {
+	if ts != s.Timestamp {
+		return ts < s.Timestamp
+	}
+	for _, h := range s.Hashes {
+		if h == hash {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *StoreState) add(ts uint32, hash string) {
+	if ts > s.Timestamp {
+		s.Timestamp = ts
+		s.Hashes = nil
+	}
+	s.Hashes = append(s.Hashes, hash)
+	s.Count++
+}
+
+// LoadState reads the state of a previous export in opts.Dir, or starts a
+// new one. Resuming with a different fid, format or raw setting is refused
+// since the files would no longer hold a complete archive.
+func LoadState(opts Options) (*State, error) {
+	data, err := os.ReadFile(filepath.Join(opts.Dir, stateFile))
+	if os.IsNotExist(err) {
+		return &State{Fid: opts.Fid, Format: opts.Format, Raw: opts.Raw, Stores: map[string]*StoreState{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := &State{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("Failed to read export state in %s: %v", opts.Dir, err)
+	}
+	if state.Stores == nil {
+		state.Stores = map[string]*StoreState{}
+	}
+	switch {
+	case state.Fid != opts.Fid:
+		return nil, fmt.Errorf("%s holds an export of fid %d, use another --out for fid %d", opts.Dir, state.Fid, opts.Fid)
+	case state.Format != opts.Format:
+		return nil, fmt.Errorf("%s was exported as %s, pass --format %s or use another --out", opts.Dir, state.Format, state.Format)
+	case state.Raw != opts.Raw:
+		return nil, fmt.Errorf("%s was exported with --raw=%t, pass the same or use another --out", opts.Dir, state.Raw)
+	}
+	return state, nil
+}
+
+func (s *State) save(dir string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, stateFile)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// writer appends messages to an output file in one format
+type writer interface {
+	Write(store string, msg *protobufs.Message) error
+	Close() error
+}
+
+func openAppend(path string) (*os.File, bool, error) {
+	info, err := os.Stat(path)
+	isNew := os.IsNotExist(err) || (err == nil && info.Size() == 0)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	return f, isNew, err
+}
+
+func newWriter(dir string, format string) (writer, error) {
+	name, ok := fileNames[format]
+	if !ok {
+		return nil, fmt.Errorf("Unknown format %q, expected jsonl, csv or markdown", format)
+	}
+	f, isNew, err := openAppend(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatCSV:
+		w := &csvWriter{f: f, csv: csv.NewWriter(f)}
+		if isNew {
+			w.csv.Write([]string{"timestamp", "store", "type", "hash", "target", "value"})
+		}
+		return w, nil
+	case FormatMarkdown:
+		if isNew {
+			fmt.Fprintf(f, "# Farcaster archive\n\n")
+		}
+		return &markdownWriter{f: f}, nil
+	}
+	return &jsonlWriter{f: f, encoder: json.NewEncoder(f)}, nil
+}
+
+type jsonlWriter struct {
+	f       *os.File
+	encoder *json.Encoder
+}
+
+func (w *jsonlWriter) Write(store string, msg *protobufs.Message) error {
+	return w.encoder.Encode(hub.EncodeJSON(msg))
+}
+
+func (w *jsonlWriter) Close() error { return w.f.Close() }
+
+type csvWriter struct {
+	f   *os.File
+	csv *csv.Writer
+}
+
+func (w *csvWriter) Write(store string, msg *protobufs.Message) error {
+	d := Describe(msg)
+	w.csv.Write([]string{formatTime(msg), store, d.Type, fmt.Sprintf("0x%x", msg.Hash), d.Target, d.Value})
+	// Flush every row so the file never lags behind the saved state
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func (w *csvWriter) Close() error { return w.f.Close() }
+
+type markdownWriter struct {
+	f *os.File
+}
+
+func (w *markdownWriter) Write(store string, msg *protobufs.Message) error {
+	d := Describe(msg)
+	var b strings.Builder
+	// Lines end in two spaces so Markdown keeps the line breaks
+	fmt.Fprintf(&b, "- **%s** · %s · `0x%x`  \n", d.Type, formatTime(msg), msg.Hash)
+	if d.Target != "" {
+		fmt.Fprintf(&b, "  ↳ %s  \n", d.Target)
+	}
+	for _, line := range strings.Split(d.Value, "\n") {
+		if strings.TrimSpace(line) != "" {
+			fmt.Fprintf(&b, "  %s  \n", line)
+		}
+	}
+	_, err := io.WriteString(w.f, b.String())
+	return err
+}
+
+func (w *markdownWriter) Close() error { return w.f.Close() }
+
+type rawWriter struct {
+	f *os.File
+}
+
+func (w *rawWriter) Write(store string, msg *protobufs.Message) error {
+	return message.Write(w.f, []*protobufs.Message{msg}, message.FormatBinary)
+}
+
+func (w *rawWriter) Close() error { return w.f.Close() }
+
+func formatTime(msg *protobufs.Message) string {
+	return message.FromTimestamp(msg.GetData().GetTimestamp()).UTC().Format(time.RFC3339)
+}
+
+// Description is a flat, human readable summary of a message used by the
+// CSV and Markdown formats
+type Description struct {
+	Type   string
+	Target string
+	Value  string
+}
+
+// Describe summarises msg by type: the text of a cast, the target of a
+// reaction or link, the value of user data, the address of a verification
+func Describe(msg *protobufs.Message) Description {
+	data := msg.GetData()
+	d := Description{Type: strings.ToLower(strings.TrimPrefix(data.GetType().String(), "MESSAGE_TYPE_"))}
+
+	switch body := data.GetBody().(type) {
+	case *protobufs.MessageData_CastAddBody:
+		d.Value = body.CastAddBody.Text
+		switch parent := body.CastAddBody.GetParent().(type) {
+		case *protobufs.CastAddBody_ParentCastId:
+			d.Target = castID(parent.ParentCastId)
+		case *protobufs.CastAddBody_ParentUrl:
+			d.Target = parent.ParentUrl
+		}
+		for _, embed := range body.CastAddBody.Embeds {
+			if u := embed.GetUrl(); u != "" {
+				d.Value += "\n" + u
+			} else if id := embed.GetCastId(); id != nil {
+				d.Value += "\n" + castID(id)
+			}
+		}
+	case *protobufs.MessageData_CastRemoveBody:
+		d.Target = fmt.Sprintf("0x%x", body.CastRemoveBody.TargetHash)
+	case *protobufs.MessageData_ReactionBody:
+		d.Value = strings.ToLower(strings.TrimPrefix(body.ReactionBody.Type.String(), "REACTION_TYPE_"))
+		if id := body.ReactionBody.GetTargetCastId(); id != nil {
+			d.Target = castID(id)
+		} else {
+			d.Target = body.ReactionBody.GetTargetUrl()
+		}
+	case *protobufs.MessageData_LinkBody:
+		d.Value = body.LinkBody.Type
+		d.Target = "fid:" + strconv.FormatUint(body.LinkBody.GetTargetFid(), 10)
+	case *protobufs.MessageData_UserDataBody:
+		d.Target = strings.ToLower(strings.TrimPrefix(body.UserDataBody.Type.String(), "USER_DATA_TYPE_"))
+		d.Value = body.UserDataBody.Value
+	case *protobufs.MessageData_VerificationAddEthAddressBody:
+		d.Value = fmt.Sprintf("0x%x", body.VerificationAddEthAddressBody.Address)
+	case *protobufs.MessageData_VerificationRemoveBody:
+		d.Value = fmt.Sprintf("0x%x", body.VerificationRemoveBody.Address)
+	}
+	return d
+}
+
+func castID(id *protobufs.CastId) string {
+	return fmt.Sprintf("fid:%d/0x%x", id.Fid, id.Hash)
+}
+
+// Progress is reported after every page of a store with the totals so far
+type Progress struct {
+	Store   string
+	New     int
+	Skipped int
+	Done    bool
+}
+
+// Run exports every message of opts.Fid newer than the saved state, saving
+// the state after each page so an interrupted export resumes where it
+// stopped. Raw messages whose bytes can't be reproduced from the hub's JSON
+// are left out of the raw file and counted as skipped.
+func Run(opts Options, progress func(Progress)) (*State, error) {
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, err
+	}
+	state, err := LoadState(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := newWriter(opts.Dir, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+
+	var raw writer
+	if opts.Raw {
+		f, _, err := openAppend(filepath.Join(opts.Dir, rawFile))
+		if err != nil {
+			return nil, err
+		}
+		raw = &rawWriter{f: f}
+		defer raw.Close()
+	}
+
+	for _, store := range Stores {
+		storeState := state.Stores[store.Name]
+		if storeState == nil {
+			storeState = &StoreState{}
+			state.Stores[store.Name] = storeState
+		}
+
+		params := url.Values{
+			"fid":      {strconv.FormatUint(opts.Fid, 10)},
+			"pageSize": {strconv.Itoa(pageSize)},
+		}
+		if storeState.Timestamp > 0 {
+			params.Set("startTimestamp", strconv.FormatUint(uint64(storeState.Timestamp), 10))
+		}
+
+		p := Progress{Store: store.Name}
+		for {
+			page, err := hub.GetMessages(store.Path, params)
+			if err != nil {
+				return state, err
+			}
+
+			for _, msg := range page.Messages {
+				ts := msg.GetData().GetTimestamp()
+				hash := fmt.Sprintf("0x%x", msg.Hash)
+				if storeState.seen(ts, hash) {
+					continue
+				}
+				if err := out.Write(store.Name, msg); err != nil {
+					return state, err
+				}
+				if raw != nil {
+					if message.Verify(msg) != nil {
+						p.Skipped++
+					} else if err := raw.Write(store.Name, msg); err != nil {
+						return state, err
+					}
+				}
+				storeState.add(ts, hash)
+				p.New++
+			}
+
+			if err := state.save(opts.Dir); err != nil {
+				return state, err
+			}
+			p.Done = page.NextPageToken == ""
+			progress(p)
+
+			if p.Done {
+				break
+			}
+			params.Set("pageToken", page.NextPageToken)
+		}
+	}
+	return state, nil
+}
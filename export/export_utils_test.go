@@ -0,0 +1,184 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"mast/devhub"
+	"mast/hub"
+	"mast/message"
+	"mast/protobufs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+const testPrivateKey = "0707070707070707070707070707070707070707070707070707070707070707"
+
+func startHub(t *testing.T) string {
+	t.Helper()
+	handler, err := devhub.NewHandler(devhub.Options{Network: protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	t.Setenv("HOME", t.TempDir())
+	if err := hub.SaveHubPreference(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	return server.URL
+}
+
+func submit(t *testing.T, hubURL string, msgData *protobufs.MessageData) *protobufs.Message {
+	t.Helper()
+	msgData.Network = protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET
+	msg, err := message.Sign(msgData, testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgBytes, _ := proto.Marshal(msg)
+	resp, err := http.Post(hubURL+"/v1/submitMessage", "application/octet-stream", bytes.NewReader(msgBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("submit returned %d", resp.StatusCode)
+	}
+	return msg
+}
+
+func cast(fid uint64, ts uint32, text string) *protobufs.MessageData {
+	return &protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_CAST_ADD,
+		Fid:       fid,
+		Timestamp: ts,
+		Body:      &protobufs.MessageData_CastAddBody{CastAddBody: &protobufs.CastAddBody{Text: text}},
+	}
+}
+
+func noProgress(Progress) {}
+
+func TestRunIsIncremental(t *testing.T) {
+	hubURL := startHub(t)
+	now := message.Timestamp(time.Now())
+	dir := filepath.Join(t.TempDir(), "archive")
+
+	first := submit(t, hubURL, cast(6596, now-100, "one"))
+	submit(t, hubURL, cast(6596, now-100, "two, same second"))
+	submit(t, hubURL, cast(3, now-90, "someone else"))
+	submit(t, hubURL, &protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_REACTION_ADD,
+		Fid:       6596,
+		Timestamp: now - 80,
+		Body: &protobufs.MessageData_ReactionBody{ReactionBody: &protobufs.ReactionBody{
+			Type:   protobufs.ReactionType_REACTION_TYPE_LIKE,
+			Target: &protobufs.ReactionBody_TargetCastId{TargetCastId: &protobufs.CastId{Fid: 6596, Hash: first.Hash}},
+		}},
+	})
+	submit(t, hubURL, &protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_USER_DATA_ADD,
+		Fid:       6596,
+		Timestamp: now - 70,
+		Body: &protobufs.MessageData_UserDataBody{UserDataBody: &protobufs.UserDataBody{
+			Type:  protobufs.UserDataType_USER_DATA_TYPE_BIO,
+			Value: "hello",
+		}},
+	})
+
+	opts := Options{Fid: 6596, Dir: dir, Format: FormatJSONL, Raw: true}
+	state, err := Run(opts, noProgress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Stores["casts"].Count != 2 || state.Stores["reactions"].Count != 1 || state.Stores["user data"].Count != 1 {
+		t.Fatalf("first run state = %+v", state.Stores)
+	}
+
+	// One more cast at the same second as the last exported ones, and a
+	// newer one, should be the only new messages
+	submit(t, hubURL, cast(6596, now-100, "three, same second"))
+	submit(t, hubURL, cast(6596, now-10, "four"))
+
+	var news []Progress
+	state, err = Run(opts, func(p Progress) { news = append(news, p) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Stores["casts"].Count != 4 {
+		t.Errorf("casts exported = %d, want 4", state.Stores["casts"].Count)
+	}
+	for _, p := range news {
+		if p.Store != "casts" && p.New != 0 {
+			t.Errorf("second run exported %d new %s", p.New, p.Store)
+		}
+	}
+
+	lines, err := os.ReadFile(filepath.Join(dir, "messages.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(lines), "\n"); n != 6 {
+		t.Errorf("messages.jsonl has %d lines, want 6", n)
+	}
+
+	raw, err := message.ReadFile(filepath.Join(dir, rawFile), message.FormatBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != 6 {
+		t.Fatalf("raw file has %d messages, want 6", len(raw))
+	}
+	for _, msg := range raw {
+		if err := message.Verify(msg); err != nil {
+			t.Errorf("raw message 0x%x: %v", msg.Hash, err)
+		}
+	}
+
+	if _, err := Run(Options{Fid: 6596, Dir: dir, Format: FormatCSV, Raw: true}, noProgress); err == nil {
+		t.Error("resuming with another format was accepted")
+	}
+}
+
+func TestCSVFormat(t *testing.T) {
+	hubURL := startHub(t)
+	now := message.Timestamp(time.Now())
+	dir := t.TempDir()
+
+	submit(t, hubURL, &protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_CAST_ADD,
+		Fid:       6596,
+		Timestamp: now,
+		Body: &protobufs.MessageData_CastAddBody{CastAddBody: &protobufs.CastAddBody{
+			Text:   "gm, \"world\"",
+			Parent: &protobufs.CastAddBody_ParentUrl{ParentUrl: "https://warpcast.com/~/channel/dev"},
+		}},
+	})
+
+	if _, err := Run(Options{Fid: 6596, Dir: dir, Format: FormatCSV}, noProgress); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filepath.Join(dir, "messages.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0][0] != "timestamp" {
+		t.Fatalf("rows = %v", rows)
+	}
+	row := rows[1]
+	if row[1] != "casts" || row[2] != "cast_add" || row[4] != "https://warpcast.com/~/channel/dev" || row[5] != "gm, \"world\"" {
+		t.Errorf("row = %v", row)
+	}
+}
@@ -0,0 +1,285 @@
+package feed
+
+import (
+	"fmt"
+	"io"
+	"mast/channels"
+	"mast/compose"
+	"mast/hub"
+	"mast/protobufs"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const itemHeight = 5
+
+var (
+	titleStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFFFF")).Background(lipgloss.Color("#7C65C1")).Padding(0, 1)
+	authorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#7C65C1")).Bold(true)
+	mutedStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#767676"))
+	errorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6B6B"))
+	selectedStyle = lipgloss.NewStyle().Border(lipgloss.NormalBorder(), false, false, false, true).BorderForeground(lipgloss.Color("#7C65C1")).PaddingLeft(1)
+	itemStyle     = lipgloss.NewStyle().PaddingLeft(2)
+)
+
+type castItem struct {
+	Cast
+}
+
+func (i castItem) FilterValue() string { return i.Text }
+
+// castDelegate renders a cast as a header with author and time, the reply
+// context, the text and its embeds, always itemHeight lines tall
+type castDelegate struct{}
+
+func (d castDelegate) Height() int                               { return itemHeight }
+func (d castDelegate) Spacing() int                              { return 1 }
+func (d castDelegate) Update(msg tea.Msg, m *list.Model) tea.Cmd { return nil }
+
+func (d castDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	c, ok := item.(castItem)
+	if !ok {
+		return
+	}
+	width := m.Width() - 4
+	if width < 20 {
+		width = 20
+	}
+
+	lines := []string{authorStyle.Render(c.AuthorName()) + mutedStyle.Render(" · "+RelativeTime(c.Timestamp, time.Now()))}
+	if context := c.ReplyContext(); context != "" {
+		lines = append(lines, mutedStyle.Render("↳ "+truncate(context, width-2)))
+	}
+
+	textLines := itemHeight - len(lines)
+	if len(c.Embeds) > 0 {
+		textLines--
+	}
+	lines = append(lines, wrap(c.Text, width, textLines)...)
+
+	if len(c.Embeds) > 0 {
+		lines = append(lines, mutedStyle.Render("🔗 "+truncate(strings.Join(c.Embeds, "  "), width-3)))
+	}
+	for len(lines) < itemHeight {
+		lines = append(lines, "")
+	}
+
+	style := itemStyle
+	if index == m.Index() {
+		style = selectedStyle
+	}
+	fmt.Fprint(w, style.Render(strings.Join(lines, "\n")))
+}
+
+// wrap word wraps text to width and keeps at most maxLines lines, ending the
+// last one with an ellipsis when text is longer
+func wrap(text string, width int, maxLines int) []string {
+	if maxLines <= 0 || strings.TrimSpace(text) == "" {
+		return nil
+	}
+	wrapped := strings.Split(lipgloss.NewStyle().Width(width).Render(text), "\n")
+	var lines []string
+	for _, line := range wrapped {
+		lines = append(lines, strings.TrimRight(line, " "))
+	}
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		lines[maxLines-1] = truncate(lines[maxLines-1], width-1) + "…"
+	}
+	return lines
+}
+
+type pageMsg struct {
+	casts []Cast
+	next  string
+	err   error
+}
+
+type likedMsg struct {
+	cast Cast
+	err  error
+}
+
+var (
+	replyKey = key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "reply"))
+	likeKey  = key.NewBinding(key.WithKeys("l"), key.WithHelp("l", "like"))
+)
+
+type model struct {
+	list      list.Model
+	src       Source
+	usernames *Usernames
+	network   protobufs.FarcasterNetwork
+	next      string
+	loading   bool
+	// replyTo is set when the list quits so the reply can be composed
+	replyTo *Cast
+	initCmd tea.Cmd
+}
+
+func newModel(src Source, casts []Cast, next string, usernames *Usernames, network protobufs.FarcasterNetwork) model {
+	items := make([]list.Item, len(casts))
+	for i, c := range casts {
+		items[i] = castItem{c}
+	}
+
+	l := list.New(items, castDelegate{}, 80, 24)
+	l.Title = src.Title
+	l.Styles.Title = titleStyle
+	l.SetFilteringEnabled(false)
+	l.SetStatusBarItemName("cast", "casts")
+	// l likes the selected cast instead of paging
+	l.KeyMap.NextPage = key.NewBinding(key.WithKeys("right", "pgdown", "f", "d"), key.WithHelp("→/pgdn", "next page"))
+	l.AdditionalShortHelpKeys = func() []key.Binding { return []key.Binding{replyKey, likeKey} }
+	l.AdditionalFullHelpKeys = l.AdditionalShortHelpKeys
+
+	return model{list: l, src: src, usernames: usernames, network: network, next: next}
+}
+
+func (m model) Init() tea.Cmd {
+	return m.initCmd
+}
+
+func (m model) like(c Cast) tea.Cmd {
+	network := m.network
+	return func() tea.Msg {
+		_, err := compose.Like(c.ID(), network)
+		return likedMsg{cast: c, err: err}
+	}
+}
+
+func (m model) loadMore() tea.Cmd {
+	src, token, usernames := m.src, m.next, m.usernames
+	return func() tea.Msg {
+		casts, next, err := Fetch(src, token, usernames)
+		return pageMsg{casts: casts, next: next, err: err}
+	}
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.list.SetSize(msg.Width, msg.Height)
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return m, tea.Quit
+		}
+		if selected, ok := m.list.SelectedItem().(castItem); ok {
+			switch {
+			case key.Matches(msg, replyKey):
+				m.replyTo = &selected.Cast
+				return m, tea.Quit
+			case key.Matches(msg, likeKey):
+				return m, tea.Batch(m.like(selected.Cast), m.list.NewStatusMessage(mutedStyle.Render("Liking…")))
+			}
+		}
+	case likedMsg:
+		if msg.err != nil {
+			return m, m.list.NewStatusMessage(errorStyle.Render(msg.err.Error()))
+		}
+		return m, m.list.NewStatusMessage(authorStyle.Render(fmt.Sprintf("♥ Liked %s's cast", msg.cast.AuthorName())))
+	case pageMsg:
+		m.loading = false
+		if msg.err != nil {
+			return m, m.list.NewStatusMessage(errorStyle.Render(msg.err.Error()))
+		}
+		m.next = msg.next
+		items := m.list.Items()
+		for _, c := range msg.casts {
+			items = append(items, castItem{c})
+		}
+		cmds = append(cmds, m.list.SetItems(items))
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	cmds = append(cmds, cmd)
+
+	// Fetch the next page before the cursor reaches the end of the list
+	if !m.loading && m.next != "" && m.list.Index() >= len(m.list.Items())-3 {
+		m.loading = true
+		cmds = append(cmds, m.loadMore(), m.list.NewStatusMessage(mutedStyle.Render("Loading more casts…")))
+	}
+
+	return m, tea.Batch(cmds...)
+}
+
+func (m model) View() string {
+	return m.list.View()
+}
+
+// Show loads the first page of src and opens it as a scrollable list. Replies
+// are composed outside the list, which reopens where it was afterwards.
+func Show(src Source, usernames *Usernames, network protobufs.FarcasterNetwork) error {
+	casts, next, err := Fetch(src, "", usernames)
+	if err != nil {
+		return err
+	}
+	if len(casts) == 0 {
+		fmt.Println("No casts found")
+		return nil
+	}
+
+	m := newModel(src, casts, next, usernames, network)
+	for {
+		result, err := tea.NewProgram(m, tea.WithAltScreen()).Run()
+		if err != nil {
+			return err
+		}
+		m = result.(model)
+		if m.replyTo == nil {
+			return nil
+		}
+
+		parent := *m.replyTo
+		m.replyTo = nil
+		m.initCmd = m.list.NewStatusMessage(reply(parent, network))
+	}
+}
+
+// reply composes and sends a reply to parent and returns the status line to
+// show when the list reopens
+func reply(parent Cast, network protobufs.FarcasterNetwork) string {
+	heading := fmt.Sprintf("Replying to %s: %s", parent.AuthorName(), truncate(parent.Text, 50))
+	castData, err := compose.ComposeReply(parent.ID(), heading)
+	if err != nil {
+		return mutedStyle.Render("Reply canceled")
+	}
+	if err := compose.SendCast(castData, compose.SendOptions{Network: network}); err != nil {
+		return errorStyle.Render(err.Error())
+	}
+	return authorStyle.Render(fmt.Sprintf("Replied to %s", parent.AuthorName()))
+}
+
+// ShowUser opens the feed of casts published by user, given as a fid or a
+// username
+func ShowUser(user string, network protobufs.FarcasterNetwork) error {
+	fid, err := hub.ParseUser(user)
+	if err != nil {
+		return err
+	}
+	usernames := NewUsernames()
+	title := fmt.Sprintf("Casts by %s", displayName(usernames.Get(fid), fid))
+	return Show(UserSource(fid, title), usernames, network)
+}
+
+// ShowChannel opens the feed of casts in a channel, given as an id or a
+// parent URL
+func ShowChannel(channelID string, network protobufs.FarcasterNetwork) error {
+	parentURL, err := channels.ParentURL(channelID, false)
+	if err != nil {
+		return err
+	}
+	title := parentURL
+	if !strings.Contains(channelID, "://") {
+		title = "/" + strings.TrimPrefix(channelID, "/")
+	}
+	return Show(ChannelSource(parentURL, title), NewUsernames(), network)
+}
@@ -0,0 +1,242 @@
+package feed
+
+import (
+	"fmt"
+	"mast/hub"
+	"mast/message"
+	"mast/protobufs"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const pageSize = 25
+
+// Source is a hub list endpoint that returns casts newest first
+type Source struct {
+	Title  string
+	Path   string
+	Params url.Values
+}
+
+// UserSource pages through the casts fid has published
+func UserSource(fid uint64, title string) Source {
+	return Source{
+		Title:  title,
+		Path:   "/v1/castsByFid",
+		Params: url.Values{"fid": {strconv.FormatUint(fid, 10)}},
+	}
+}
+
+// ChannelSource pages through the top level casts in the channel with the
+// given parent URL
+func ChannelSource(parentURL string, title string) Source {
+	return Source{
+		Title:  title,
+		Path:   "/v1/castsByParent",
+		Params: url.Values{"url": {parentURL}},
+	}
+}
+
+// Cast is a CAST_ADD message flattened for display
+type Cast struct {
+	Hash         []byte
+	Fid          uint64
+	Author       string
+	Timestamp    time.Time
+	Text         string
+	Embeds       []string
+	ParentFid    uint64
+	ParentHash   []byte
+	ParentURL    string
+	ParentAuthor string
+	Message      *protobufs.Message
+}
+
+// FromMessage converts a hub message into a Cast, returning false for
+// anything that is not a CAST_ADD
+func FromMessage(msg *protobufs.Message) (Cast, bool) {
+	msgData := msg.GetData()
+	body := msgData.GetCastAddBody()
+	if msgData.GetType() != protobufs.MessageType_MESSAGE_TYPE_CAST_ADD || body == nil {
+		return Cast{}, false
+	}
+
+	c := Cast{
+		Hash:      msg.Hash,
+		Fid:       msgData.Fid,
+		Timestamp: message.FromTimestamp(msgData.Timestamp),
+		Text:      body.Text,
+		ParentURL: body.GetParentUrl(),
+		Message:   msg,
+	}
+	if parent := body.GetParentCastId(); parent != nil {
+		c.ParentFid = parent.Fid
+		c.ParentHash = parent.Hash
+	}
+	for _, embed := range body.Embeds {
+		switch {
+		case embed.GetUrl() != "":
+			c.Embeds = append(c.Embeds, embed.GetUrl())
+		case embed.GetCastId() != nil:
+			c.Embeds = append(c.Embeds, fmt.Sprintf("cast 0x%x by fid %d", embed.GetCastId().Hash, embed.GetCastId().Fid))
+		}
+	}
+	return c, true
+}
+
+// ID returns the cast id replies and reactions point at
+func (c Cast) ID() *protobufs.CastId {
+	return &protobufs.CastId{Fid: c.Fid, Hash: c.Hash}
+}
+
+// AuthorName is @username, or fid:N when the username is unknown
+func (c Cast) AuthorName() string {
+	return displayName(c.Author, c.Fid)
+}
+
+// ReplyContext describes what the cast replies to, empty for top level casts
+func (c Cast) ReplyContext() string {
+	switch {
+	case c.ParentFid != 0:
+		return fmt.Sprintf("replying to %s", displayName(c.ParentAuthor, c.ParentFid))
+	case c.ParentURL != "":
+		return fmt.Sprintf("in %s", c.ParentURL)
+	}
+	return ""
+}
+
+func displayName(username string, fid uint64) string {
+	if username != "" {
+		return "@" + username
+	}
+	return fmt.Sprintf("fid:%d", fid)
+}
+
+// Usernames caches fid to username lookups made through user data
+type Usernames struct {
+	mu    sync.Mutex
+	names map[uint64]string
+}
+
+func NewUsernames() *Usernames {
+	return &Usernames{names: map[uint64]string{}}
+}
+
+// Get returns the username for fid, looking it up on the hub the first time.
+// Failed lookups are cached as empty so the fid is shown instead.
+func (u *Usernames) Get(fid uint64) string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if name, ok := u.names[fid]; ok {
+		return name
+	}
+	name, _ := hub.Username(fid)
+	u.names[fid] = name
+	return name
+}
+
+// Fill sets the usernames of the author and parent of c, and puts mentions
+// back into its text
+func (u *Usernames) Fill(c *Cast) {
+	c.Author = u.Get(c.Fid)
+	if c.ParentFid != 0 {
+		c.ParentAuthor = u.Get(c.ParentFid)
+	}
+	if c.Message != nil {
+		c.Text = insertMentions(c.Message.GetData().GetCastAddBody(), u)
+	}
+}
+
+// Fetch loads the page of src starting at pageToken and resolves the
+// usernames of authors, parents and mentions
+func Fetch(src Source, pageToken string, usernames *Usernames) ([]Cast, string, error) {
+	params := url.Values{}
+	for key, values := range src.Params {
+		params[key] = values
+	}
+	params.Set("reverse", "true")
+	params.Set("pageSize", strconv.Itoa(pageSize))
+	if pageToken != "" {
+		params.Set("pageToken", pageToken)
+	}
+
+	page, err := hub.GetMessages(src.Path, params)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var casts []Cast
+	for _, msg := range page.Messages {
+		c, ok := FromMessage(msg)
+		if !ok {
+			continue
+		}
+		usernames.Fill(&c)
+		casts = append(casts, c)
+	}
+	return casts, page.NextPageToken, nil
+}
+
+// insertMentions puts @username back into the cast text at the byte offsets
+// given by MentionsPositions, hubs store the text without them
+func insertMentions(body *protobufs.CastAddBody, usernames *Usernames) string {
+	text := body.GetText()
+	if len(body.GetMentions()) == 0 || len(body.GetMentions()) != len(body.GetMentionsPositions()) {
+		return text
+	}
+
+	type mention struct {
+		position int
+		fid      uint64
+	}
+	mentions := make([]mention, len(body.Mentions))
+	for i, fid := range body.Mentions {
+		mentions[i] = mention{position: int(body.MentionsPositions[i]), fid: fid}
+	}
+	sort.SliceStable(mentions, func(i, j int) bool { return mentions[i].position < mentions[j].position })
+
+	var b strings.Builder
+	last := 0
+	for _, m := range mentions {
+		if m.position < last || m.position > len(text) {
+			return text
+		}
+		b.WriteString(text[last:m.position])
+		b.WriteString(displayName(usernames.Get(m.fid), m.fid))
+		last = m.position
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// RelativeTime renders t the way clients do: now, 5m, 3h, 2d, then a date
+func RelativeTime(t time.Time, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	case d < 7*24*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	case t.Year() == now.Year():
+		return t.Format("Jan 2")
+	}
+	return t.Format("Jan 2, 2006")
+}
+
+// truncate collapses whitespace in text and cuts it to width runes
+func truncate(text string, width int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if width > 0 && len(runes) > width {
+		return string(runes[:width-1]) + "…"
+	}
+	return text
+}
@@ -0,0 +1,171 @@
+package feed
+
+import (
+	"bytes"
+	"fmt"
+	"mast/devhub"
+	"mast/hub"
+	"mast/message"
+	"mast/protobufs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+const testPrivateKey = "0707070707070707070707070707070707070707070707070707070707070707"
+
+func startHub(t *testing.T) {
+	t.Helper()
+	handler, err := devhub.NewHandler(devhub.Options{Network: protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	t.Setenv("HOME", t.TempDir())
+	if err := hub.SaveHubPreference(server.URL); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func submit(t *testing.T, msgData *protobufs.MessageData) *protobufs.Message {
+	t.Helper()
+	msgData.Network = protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET
+	msg, err := message.Sign(msgData, testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgBytes, _ := proto.Marshal(msg)
+	resp, err := http.Post(mustHubURL(t)+"/v1/submitMessage", "application/octet-stream", bytes.NewReader(msgBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("submit returned %d", resp.StatusCode)
+	}
+	return msg
+}
+
+func mustHubURL(t *testing.T) string {
+	hubURL, _, err := hub.RetrieveHubPreference()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hubURL
+}
+
+func castData(fid uint64, ts uint32, body *protobufs.CastAddBody) *protobufs.MessageData {
+	return &protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_CAST_ADD,
+		Fid:       fid,
+		Timestamp: ts,
+		Body:      &protobufs.MessageData_CastAddBody{CastAddBody: body},
+	}
+}
+
+func TestFetchPagesNewestFirstWithUsernames(t *testing.T) {
+	startHub(t)
+	now := message.Timestamp(time.Now())
+
+	submit(t, &protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_USER_DATA_ADD,
+		Fid:       6596,
+		Timestamp: now - 100,
+		Body: &protobufs.MessageData_UserDataBody{UserDataBody: &protobufs.UserDataBody{
+			Type:  protobufs.UserDataType_USER_DATA_TYPE_USERNAME,
+			Value: "steve",
+		}},
+	})
+
+	var first *protobufs.Message
+	for i := 0; i < pageSize+2; i++ {
+		msg := submit(t, castData(6596, now-uint32(60-i), &protobufs.CastAddBody{Text: fmt.Sprintf("cast %d", i)}))
+		if i == 0 {
+			first = msg
+		}
+	}
+	submit(t, castData(6596, now, &protobufs.CastAddBody{
+		Text:              "gm  and ",
+		Mentions:          []uint64{6596, 3},
+		MentionsPositions: []uint32{3, 8},
+		Parent:            &protobufs.CastAddBody_ParentCastId{ParentCastId: &protobufs.CastId{Fid: 6596, Hash: first.Hash}},
+		Embeds:            []*protobufs.Embed{{Embed: &protobufs.Embed_Url{Url: "https://example.com"}}},
+	}))
+
+	usernames := NewUsernames()
+	casts, next, err := Fetch(UserSource(6596, ""), "", usernames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(casts) != pageSize || next == "" {
+		t.Fatalf("first page has %d casts, next %q", len(casts), next)
+	}
+
+	newest := casts[0]
+	if newest.AuthorName() != "@steve" || newest.ReplyContext() != "replying to @steve" {
+		t.Errorf("author %q, context %q", newest.AuthorName(), newest.ReplyContext())
+	}
+	if newest.Text != "gm @steve and fid:3" {
+		t.Errorf("text with mentions = %q", newest.Text)
+	}
+	if len(newest.Embeds) != 1 || newest.Embeds[0] != "https://example.com" {
+		t.Errorf("embeds = %v", newest.Embeds)
+	}
+
+	rest, next, err := Fetch(UserSource(6596, ""), next, usernames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 3 || next != "" || rest[len(rest)-1].Text != "cast 0" {
+		t.Errorf("second page has %d casts, next %q", len(rest), next)
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	cases := map[time.Duration]string{
+		10 * time.Second:     "now",
+		5 * time.Minute:      "5m",
+		3 * time.Hour:        "3h",
+		50 * time.Hour:       "2d",
+		30 * 24 * time.Hour:  "May 16",
+		400 * 24 * time.Hour: "May 12, 2023",
+	}
+	for ago, want := range cases {
+		if got := RelativeTime(now.Add(-ago), now); got != want {
+			t.Errorf("RelativeTime(-%v) = %q, want %q", ago, got, want)
+		}
+	}
+}
+
+func TestChannelSourceListsTopLevelCasts(t *testing.T) {
+	startHub(t)
+	now := message.Timestamp(time.Now())
+	channelURL := "https://warpcast.com/~/channel/dev"
+
+	top := submit(t, castData(6596, now-10, &protobufs.CastAddBody{
+		Text:   "in the channel",
+		Parent: &protobufs.CastAddBody_ParentUrl{ParentUrl: channelURL},
+	}))
+	submit(t, castData(3, now-5, &protobufs.CastAddBody{
+		Text:   "a reply",
+		Parent: &protobufs.CastAddBody_ParentCastId{ParentCastId: &protobufs.CastId{Fid: 6596, Hash: top.Hash}},
+	}))
+	submit(t, castData(3, now, &protobufs.CastAddBody{Text: "not in the channel"}))
+
+	casts, _, err := Fetch(ChannelSource(channelURL, "/dev"), "", NewUsernames())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(casts) != 1 || casts[0].Text != "in the channel" || casts[0].AuthorName() != "fid:6596" {
+		t.Errorf("channel casts = %v", casts)
+	}
+	if id := casts[0].ID(); id.Fid != 6596 || !bytes.Equal(id.Hash, top.Hash) {
+		t.Errorf("ID() = %v", id)
+	}
+}
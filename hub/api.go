@@ -0,0 +1,150 @@
+package hub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SubmitResponse struct {
+	Hash string `json:"hash"`
+}
+
+// SubmitMessage posts an encoded protobufs.Message to the preferred hub and
+// returns the hash reported back by the hub
+func SubmitMessage(msgBytes []byte) (string, error) {
+	hubURL, apiKey, err := RetrieveHubPreference()
+	if err != nil {
+		return "", err
+	}
+
+	url := hubURL + "/v1/submitMessage"
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(msgBytes))
+	if err != nil {
+		return "", fmt.Errorf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	// Add API key header if available (for Neynar)
+	if apiKey != "" {
+		req.Header.Set("x-api-key", apiKey)
+	}
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Failed to send POST request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read hub response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		hubErr := statusError(resp.StatusCode, string(bodyBytes)).(*HubError)
+		hubErr.RetryAfter = retryAfter(resp.Header.Get("Retry-After"))
+		return "", hubErr
+	}
+
+	var response SubmitResponse
+	err = json.Unmarshal(bodyBytes, &response)
+	if err != nil {
+		return "", fmt.Errorf("Failed to decode json")
+	}
+
+	return response.Hash, nil
+}
+
+// HubError is returned when the hub answers with a non-200 status. ErrCode
+// and Reason come from the hub's error body when it sends one.
+type HubError struct {
+	StatusCode int
+	ErrCode    string
+	Reason     string
+	Body       string
+	// Request is the endpoint of a failed read, empty for submits
+	Request string
+	// RetryAfter is how long the hub asked to wait before trying again, zero
+	// when it didn't say
+	RetryAfter time.Duration
+}
+
+type hubErrorBody struct {
+	ErrCode string `json:"errCode"`
+	Details string `json:"details"`
+	Message string `json:"message"`
+}
+
+func statusError(statusCode int, body string) error {
+	hubErr := &HubError{StatusCode: statusCode, Body: body}
+
+	var parsed hubErrorBody
+	if json.Unmarshal([]byte(body), &parsed) == nil {
+		hubErr.ErrCode = parsed.ErrCode
+		hubErr.Reason = parsed.Details
+		if hubErr.Reason == "" {
+			hubErr.Reason = parsed.Message
+		}
+	}
+
+	return hubErr
+}
+
+func (e *HubError) Error() string {
+	switch e.StatusCode {
+	case 401:
+		return "Authentication failed (401). Please check your API key."
+	case 402:
+		return "Payment required (402). Please check your Neynar account status and billing."
+	case 403:
+		return "Forbidden (403). You may not have permission to use this endpoint."
+	case 429:
+		return "Rate limited (429). Please try again later."
+	default:
+		if e.Request != "" {
+			return fmt.Sprintf("Hub request %s failed. HTTP status: %d. Response: %s", e.Request, e.StatusCode, e.Body)
+		}
+		return fmt.Sprintf("Failed to send the message. HTTP status: %d. Response: %s", e.StatusCode, e.Body)
+	}
+}
+
+// Duplicate reports whether the hub rejected the message because it has
+// already been merged
+func (e *HubError) Duplicate() bool {
+	if e.ErrCode == "bad_request.duplicate" {
+		return true
+	}
+	reason := strings.ToLower(e.Reason + " " + e.Body)
+	return strings.Contains(reason, "duplicate") || strings.Contains(reason, "already merged")
+}
+
+// RateLimited reports whether the hub turned the request away because too
+// many were sent
+func (e *HubError) RateLimited() bool {
+	if e.StatusCode == http.StatusTooManyRequests || strings.HasPrefix(e.ErrCode, "unavailable.rate_limit") {
+		return true
+	}
+	reason := strings.ToLower(e.Reason)
+	return strings.Contains(reason, "rate limit") || strings.Contains(reason, "too many requests")
+}
+
+// retryAfter parses a Retry-After header given in seconds or as a date
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && time.Until(at) > 0 {
+		return time.Until(at)
+	}
+	return 0
+}
@@ -0,0 +1,207 @@
+package hub
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mast/protobufs"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Hubs serve messages over HTTP as JSON where hashes, signers and addresses
+// are 0x prefixed hex, signatures stay base64, 64 bit ids are plain numbers
+// and username proof names are strings. protojson does none of that, so
+// messages are converted field by field using their descriptors.
+
+var base64Fields = map[protoreflect.Name]bool{
+	"signature":      true,
+	"ethSignature":   true,
+	"eth_signature":  true,
+	"claimSignature": true,
+	"dataBytes":      true,
+	"data_bytes":     true,
+}
+
+var stringBytesFields = map[protoreflect.FullName]bool{
+	"UserNameProof.name": true,
+}
+
+// EncodeMessageJSON renders msg the way hubs return it from their HTTP API,
+// including the decoded data when only DataBytes is set
+func EncodeMessageJSON(msg *protobufs.Message) (map[string]interface{}, error) {
+	if msg.Data == nil && len(msg.DataBytes) > 0 {
+		msgData := &protobufs.MessageData{}
+		if err := proto.Unmarshal(msg.DataBytes, msgData); err != nil {
+			return nil, fmt.Errorf("Failed to decode message data: %v", err)
+		}
+		msg = proto.Clone(msg).(*protobufs.Message)
+		msg.Data = msgData
+	}
+	return EncodeJSON(msg), nil
+}
+
+// EncodeJSON converts any protobuf message into the hub's JSON conventions
+func EncodeJSON(m proto.Message) map[string]interface{} {
+	return encodeMessage(proto.MessageReflect(m))
+}
+
+func encodeMessage(m protoreflect.Message) map[string]interface{} {
+	out := map[string]interface{}{}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		name := fd.JSONName()
+		switch {
+		case fd.IsList():
+			list := v.List()
+			values := make([]interface{}, list.Len())
+			for i := 0; i < list.Len(); i++ {
+				values[i] = encodeValue(fd, list.Get(i))
+			}
+			out[name] = values
+		default:
+			out[name] = encodeValue(fd, v)
+		}
+		return true
+	})
+	return out
+}
+
+func encodeValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return encodeMessage(v.Message())
+	case protoreflect.BytesKind:
+		b := v.Bytes()
+		switch {
+		case stringBytesFields[fd.FullName()]:
+			return string(b)
+		case base64Fields[fd.Name()] || base64Fields[protoreflect.Name(fd.JSONName())]:
+			return base64.StdEncoding.EncodeToString(b)
+		default:
+			return "0x" + hex.EncodeToString(b)
+		}
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return int32(v.Enum())
+	default:
+		return v.Interface()
+	}
+}
+
+// DecodeMessageJSON parses a message in the hub's JSON format
+func DecodeMessageJSON(data []byte) (*protobufs.Message, error) {
+	msg := &protobufs.Message{}
+	if err := DecodeJSON(data, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// DecodeJSON parses hub JSON into m, converting hex and string encoded byte
+// fields into the base64 protojson expects
+func DecodeJSON(data []byte, m proto.Message) error {
+	var raw interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return fmt.Errorf("Failed to decode hub json: %v", err)
+	}
+
+	obj, ok := raw.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("Failed to decode hub json: expected an object")
+	}
+	if err := normalizeMessage(proto.MessageReflect(m).Descriptor(), obj); err != nil {
+		return err
+	}
+
+	normalized, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(normalized, proto.MessageV2(m))
+}
+
+func normalizeMessage(md protoreflect.MessageDescriptor, obj map[string]interface{}) error {
+	for key, value := range obj {
+		fd := md.Fields().ByJSONName(key)
+		if fd == nil {
+			fd = md.Fields().ByName(protoreflect.Name(key))
+		}
+		if fd == nil || value == nil {
+			continue
+		}
+
+		if fd.IsList() {
+			items, ok := value.([]interface{})
+			if !ok {
+				continue
+			}
+			for i, item := range items {
+				normalized, err := normalizeValue(fd, item)
+				if err != nil {
+					return err
+				}
+				items[i] = normalized
+			}
+			continue
+		}
+
+		normalized, err := normalizeValue(fd, value)
+		if err != nil {
+			return err
+		}
+		obj[key] = normalized
+	}
+	return nil
+}
+
+func normalizeValue(fd protoreflect.FieldDescriptor, value interface{}) (interface{}, error) {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if obj, ok := value.(map[string]interface{}); ok {
+			return obj, normalizeMessage(fd.Message(), obj)
+		}
+	case protoreflect.BytesKind:
+		s, ok := value.(string)
+		if !ok {
+			return value, nil
+		}
+		b, err := decodeBytesField(fd, s)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode %s: %v", fd.JSONName(), err)
+		}
+		return base64.StdEncoding.EncodeToString(b), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind, protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		// protojson wants 64 bit numbers as strings or exact integers
+		if n, ok := value.(json.Number); ok {
+			if _, err := strconv.ParseInt(n.String(), 10, 64); err == nil {
+				return n.String(), nil
+			}
+			if _, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+				return n.String(), nil
+			}
+		}
+	}
+	return value, nil
+}
+
+func decodeBytesField(fd protoreflect.FieldDescriptor, s string) ([]byte, error) {
+	if strings.HasPrefix(s, "0x") {
+		return hex.DecodeString(s[2:])
+	}
+	if stringBytesFields[fd.FullName()] {
+		return []byte(s), nil
+	}
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
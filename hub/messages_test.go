@@ -0,0 +1,108 @@
+package hub
+
+import (
+	"encoding/json"
+	"mast/protobufs"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+func TestDecodeHubMessageJSON(t *testing.T) {
+	// Shape of a cast returned by a hub's /v1/castsByFid
+	body := `{
+		"data": {
+			"type": "MESSAGE_TYPE_CAST_ADD",
+			"fid": 2,
+			"timestamp": 48994466,
+			"network": "FARCASTER_NETWORK_MAINNET",
+			"castAddBody": {
+				"embedsDeprecated": [],
+				"mentions": [3],
+				"parentCastId": {"fid": 226, "hash": "0xa48dd46161d8e57725f5e26e34ec19c13ff7f3b9"},
+				"text": "Cast Text",
+				"mentionsPositions": [0],
+				"embeds": [{"url": "https://example.com"}]
+			}
+		},
+		"hash": "0xd2b1ddc6c88e865a33cb1a565e0058d757042974",
+		"hashScheme": "HASH_SCHEME_BLAKE3",
+		"signature": "3msLXzxB4eEYeF0Le6jgmhJLHFeSXS5c9Ov3a4G4UKhpOKTQKzwZ3ZgFTvBwzYMvGkTZ7FfI3w5A9xS5ebm4Cw==",
+		"signatureScheme": "SIGNATURE_SCHEME_ED25519",
+		"signer": "0x78ff9a768cf1ff1a72f7b4ed53e3ce56b546d84566d2a91c59e0ab3d4e9df6a8"
+	}`
+
+	msg, err := DecodeMessageJSON([]byte(body))
+	if err != nil {
+		t.Fatalf("DecodeMessageJSON: %v", err)
+	}
+
+	cast := msg.GetData().GetCastAddBody()
+	if cast.GetText() != "Cast Text" || msg.GetData().GetFid() != 2 {
+		t.Fatalf("unexpected data %v", msg.GetData())
+	}
+	if got := cast.GetParentCastId().GetHash(); len(got) != 20 || got[0] != 0xa4 {
+		t.Errorf("parent hash = %x", got)
+	}
+	if len(msg.Hash) != 20 || len(msg.Signer) != 32 || len(msg.Signature) != 64 {
+		t.Errorf("hash %d, signer %d, signature %d bytes", len(msg.Hash), len(msg.Signer), len(msg.Signature))
+	}
+	if cast.GetEmbeds()[0].GetUrl() != "https://example.com" {
+		t.Errorf("embeds = %v", cast.GetEmbeds())
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	msgData := &protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_USERNAME_PROOF,
+		Fid:       6596,
+		Timestamp: 100,
+		Network:   protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET,
+		Body: &protobufs.MessageData_UsernameProofBody{UsernameProofBody: &protobufs.UserNameProof{
+			Timestamp: 1700000000,
+			Name:      []byte("stevedylandev"),
+			Owner:     make([]byte, 20),
+			Signature: make([]byte, 65),
+			Fid:       6596,
+			Type:      protobufs.UserNameType_USERNAME_TYPE_FNAME,
+		}},
+	}
+	dataBytes, err := proto.Marshal(msgData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &protobufs.Message{
+		Hash:      make([]byte, 20),
+		Signer:    make([]byte, 32),
+		Signature: make([]byte, 64),
+		DataBytes: dataBytes,
+	}
+
+	encoded, err := EncodeMessageJSON(msg)
+	if err != nil {
+		t.Fatalf("EncodeMessageJSON: %v", err)
+	}
+	data := encoded["data"].(map[string]interface{})
+	proof := data["usernameProofBody"].(map[string]interface{})
+	if proof["name"] != "stevedylandev" {
+		t.Errorf("name = %v, want a plain string", proof["name"])
+	}
+	if encoded["hash"] != "0x0000000000000000000000000000000000000000" {
+		t.Errorf("hash = %v, want hex", encoded["hash"])
+	}
+
+	raw, err := json.Marshal(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeMessageJSON(raw)
+	if err != nil {
+		t.Fatalf("DecodeMessageJSON: %v", err)
+	}
+	if !proto.Equal(decoded.Data, msgData) {
+		t.Errorf("data differs after round trip: %v", decoded.Data)
+	}
+	if string(decoded.DataBytes) != string(dataBytes) {
+		t.Error("dataBytes differs after round trip")
+	}
+}
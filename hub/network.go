@@ -0,0 +1,180 @@
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mast/protobufs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var networks = map[string]protobufs.FarcasterNetwork{
+	"mainnet": protobufs.FarcasterNetwork_FARCASTER_NETWORK_MAINNET,
+	"testnet": protobufs.FarcasterNetwork_FARCASTER_NETWORK_TESTNET,
+	"devnet":  protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET,
+}
+
+// ParseNetwork accepts mainnet, testnet or devnet, or the full protobuf enum
+// name such as FARCASTER_NETWORK_DEVNET
+func ParseNetwork(name string) (protobufs.FarcasterNetwork, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.TrimPrefix(name, "farcaster_network_")
+	if network, ok := networks[name]; ok {
+		return network, nil
+	}
+	return protobufs.FarcasterNetwork_FARCASTER_NETWORK_NONE, fmt.Errorf("Unknown network %q, expected mainnet, testnet or devnet", name)
+}
+
+// NetworkName returns the short name used by --network for network
+func NetworkName(network protobufs.FarcasterNetwork) string {
+	for name, n := range networks {
+		if n == network {
+			return name
+		}
+	}
+	return network.String()
+}
+
+func SaveNetworkPreference(network protobufs.FarcasterNetwork) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+
+	networkPath := filepath.Join(home, ".fc-cast-network")
+	return os.WriteFile(networkPath, []byte(NetworkName(network)), 0600)
+}
+
+// RetrieveNetworkPreference returns the saved network, defaulting to mainnet
+func RetrieveNetworkPreference() (protobufs.FarcasterNetwork, error) {
+	const defaultNetwork = protobufs.FarcasterNetwork_FARCASTER_NETWORK_MAINNET
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return defaultNetwork, nil
+	}
+
+	network, err := os.ReadFile(filepath.Join(homeDir, ".fc-cast-network"))
+	if err != nil || len(network) == 0 {
+		return defaultNetwork, nil
+	}
+
+	return ParseNetwork(string(network))
+}
+
+// ResolveNetwork returns the network named by a --network flag, or the saved
+// preference when the flag is empty
+func ResolveNetwork(name string) (protobufs.FarcasterNetwork, error) {
+	if name != "" {
+		return ParseNetwork(name)
+	}
+	return RetrieveNetworkPreference()
+}
+
+type InfoResponse struct {
+	Version        string          `json:"version"`
+	IsSyncing      bool            `json:"isSyncing"`
+	Nickname       string          `json:"nickname"`
+	PeerID         string          `json:"peerId"`
+	HubOperatorFid uint64          `json:"hubOperatorFid"`
+	Network        json.RawMessage `json:"network"`
+}
+
+// Info fetches /v1/info from the preferred hub
+func Info() (InfoResponse, error) {
+	var info InfoResponse
+
+	hubURL, apiKey, err := RetrieveHubPreference()
+	if err != nil {
+		return info, err
+	}
+
+	req, err := http.NewRequest("GET", hubURL+"/v1/info", nil)
+	if err != nil {
+		return info, fmt.Errorf("Failed to create request: %v", err)
+	}
+	if apiKey != "" {
+		req.Header.Set("x-api-key", apiKey)
+	}
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return info, fmt.Errorf("Failed to connect to hub: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return info, fmt.Errorf("Failed to read hub response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return info, statusError(resp.StatusCode, string(body))
+	}
+
+	if err := json.Unmarshal(body, &info); err != nil {
+		return info, fmt.Errorf("Failed to decode hub info: %v", err)
+	}
+	return info, nil
+}
+
+// ReportedNetwork returns the network the hub says it runs on. Hubs that do
+// not report one return ok as false.
+func (i InfoResponse) ReportedNetwork() (protobufs.FarcasterNetwork, bool) {
+	if len(i.Network) == 0 || string(i.Network) == "null" {
+		return protobufs.FarcasterNetwork_FARCASTER_NETWORK_NONE, false
+	}
+
+	var name string
+	if json.Unmarshal(i.Network, &name) == nil {
+		if n, err := strconv.Atoi(name); err == nil {
+			return protobufs.FarcasterNetwork(n), true
+		}
+		network, err := ParseNetwork(name)
+		return network, err == nil
+	}
+
+	var number int32
+	if json.Unmarshal(i.Network, &number) == nil {
+		return protobufs.FarcasterNetwork(number), true
+	}
+	return protobufs.FarcasterNetwork_FARCASTER_NETWORK_NONE, false
+}
+
+// CheckNetwork compares network with what the preferred hub reports and
+// returns a descriptive error when they differ. Hubs whose /v1/info can't
+// be read are only warned about, since many hosted APIs don't serve it.
+func CheckNetwork(network protobufs.FarcasterNetwork) error {
+	info, err := Info()
+	if err != nil {
+		slog.Warn("Could not check the hub's network, sending anyway", "error", err.Error())
+		return nil
+	}
+
+	reported, ok := info.ReportedNetwork()
+	if !ok || reported == network {
+		return nil
+	}
+
+	hubURL, _, _ := RetrieveHubPreference()
+	return &NetworkMismatchError{Network: network, Reported: reported, HubURL: hubURL}
+}
+
+// NetworkMismatchError is returned by CheckNetwork when the hub reports a
+// different network than messages are signed for
+type NetworkMismatchError struct {
+	Network  protobufs.FarcasterNetwork
+	Reported protobufs.FarcasterNetwork
+	HubURL   string
+}
+
+func (e *NetworkMismatchError) Error() string {
+	reported := NetworkName(e.Reported)
+	return fmt.Sprintf("Network mismatch: mast is configured for %s but the hub at %s reports %s. Pass --network %s or run mast network %s",
+		NetworkName(e.Network), e.HubURL, reported, reported, reported)
+}
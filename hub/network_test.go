@@ -0,0 +1,75 @@
+package hub
+
+import (
+	"errors"
+	"io"
+	"mast/protobufs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseNetwork(t *testing.T) {
+	cases := map[string]protobufs.FarcasterNetwork{
+		"mainnet":                  protobufs.FarcasterNetwork_FARCASTER_NETWORK_MAINNET,
+		" Testnet\n":               protobufs.FarcasterNetwork_FARCASTER_NETWORK_TESTNET,
+		"FARCASTER_NETWORK_DEVNET": protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET,
+	}
+	for input, want := range cases {
+		got, err := ParseNetwork(input)
+		if err != nil || got != want {
+			t.Errorf("ParseNetwork(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+
+	if _, err := ParseNetwork("localnet"); err == nil {
+		t.Error("expected an unknown network to be rejected")
+	}
+}
+
+func TestReportedNetwork(t *testing.T) {
+	cases := map[string]struct {
+		raw  string
+		want protobufs.FarcasterNetwork
+		ok   bool
+	}{
+		"missing":   {raw: "", ok: false},
+		"null":      {raw: "null", ok: false},
+		"enum name": {raw: `"FARCASTER_NETWORK_DEVNET"`, want: protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET, ok: true},
+		"short":     {raw: `"testnet"`, want: protobufs.FarcasterNetwork_FARCASTER_NETWORK_TESTNET, ok: true},
+		"number":    {raw: `1`, want: protobufs.FarcasterNetwork_FARCASTER_NETWORK_MAINNET, ok: true},
+	}
+	for name, c := range cases {
+		info := InfoResponse{Network: []byte(c.raw)}
+		got, ok := info.ReportedNetwork()
+		if ok != c.ok || (ok && got != c.want) {
+			t.Errorf("%s: ReportedNetwork() = %v, %v; want %v, %v", name, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestCheckNetwork(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	devnet := protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	if err := SaveHubPreference(missing.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckNetwork(devnet); err != nil {
+		t.Errorf("CheckNetwork with a 404 info endpoint = %v, want nil", err)
+	}
+
+	mainnet := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"network":"FARCASTER_NETWORK_MAINNET"}`)
+	}))
+	defer mainnet.Close()
+	if err := SaveHubPreference(mainnet.URL); err != nil {
+		t.Fatal(err)
+	}
+	var mismatch *NetworkMismatchError
+	if err := CheckNetwork(devnet); !errors.As(err, &mismatch) {
+		t.Errorf("CheckNetwork against a mainnet hub = %v, want a NetworkMismatchError", err)
+	}
+}
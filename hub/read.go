@@ -0,0 +1,153 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mast/protobufs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// MessagePage is one page of a list endpoint such as /v1/castsByFid. An empty
+// NextPageToken means there are no more pages.
+type MessagePage struct {
+	Messages      []*protobufs.Message
+	NextPageToken string
+}
+
+type messagesResponse struct {
+	Messages      []json.RawMessage `json:"messages"`
+	NextPageToken string            `json:"nextPageToken"`
+}
+
+// Get fetches a read endpoint such as /v1/castsByFid from the preferred hub
+// and returns the raw JSON body
+func Get(path string, params url.Values) ([]byte, error) {
+	hubURL, apiKey, err := RetrieveHubPreference()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := hubURL + path
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create request: %v", err)
+	}
+	if apiKey != "" {
+		req.Header.Set("x-api-key", apiKey)
+	}
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to hub: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read hub response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		hubErr := statusError(resp.StatusCode, string(body)).(*HubError)
+		hubErr.Request = path
+		return nil, hubErr
+	}
+	return body, nil
+}
+
+// GetMessages fetches one page of messages from a list endpoint
+func GetMessages(path string, params url.Values) (MessagePage, error) {
+	var page MessagePage
+
+	body, err := Get(path, params)
+	if err != nil {
+		return page, err
+	}
+
+	var response messagesResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return page, fmt.Errorf("Failed to decode hub response: %v", err)
+	}
+
+	for _, raw := range response.Messages {
+		msg, err := DecodeMessageJSON(raw)
+		if err != nil {
+			return page, err
+		}
+		page.Messages = append(page.Messages, msg)
+	}
+	page.NextPageToken = response.NextPageToken
+	return page, nil
+}
+
+// GetMessage fetches a single message endpoint such as /v1/castById
+func GetMessage(path string, params url.Values) (*protobufs.Message, error) {
+	body, err := Get(path, params)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeMessageJSON(body)
+}
+
+// IsNotFound reports whether err is the hub saying the requested data does
+// not exist
+func IsNotFound(err error) bool {
+	var hubErr *HubError
+	if !errors.As(err, &hubErr) {
+		return false
+	}
+	return hubErr.StatusCode == http.StatusNotFound || strings.HasPrefix(hubErr.ErrCode, "not_found")
+}
+
+// FidByUsername looks up the fid that owns an fname or ENS name
+func FidByUsername(username string) (uint64, error) {
+	username = strings.TrimPrefix(strings.TrimSpace(username), "@")
+	body, err := Get("/v1/userNameProofByName", url.Values{"name": {username}})
+	if IsNotFound(err) {
+		return 0, fmt.Errorf("No Farcaster account found for %q", username)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	proof := &protobufs.UserNameProof{}
+	if err := DecodeJSON(body, proof); err != nil {
+		return 0, err
+	}
+	return proof.Fid, nil
+}
+
+// ParseUser accepts a fid or a username, with or without a leading @
+func ParseUser(user string) (uint64, error) {
+	if fid, err := strconv.ParseUint(user, 10, 64); err == nil {
+		return fid, nil
+	}
+	if user == "" {
+		return 0, fmt.Errorf("a fid or username is required")
+	}
+	return FidByUsername(user)
+}
+
+// Username returns the username fid has set in its user data, or an empty
+// string when it has none
+func Username(fid uint64) (string, error) {
+	msg, err := GetMessage("/v1/userDataByFid", url.Values{
+		"fid":            {strconv.FormatUint(fid, 10)},
+		"user_data_type": {strconv.Itoa(int(protobufs.UserDataType_USER_DATA_TYPE_USERNAME))},
+	})
+	if IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return msg.GetData().GetUserDataBody().GetValue(), nil
+}
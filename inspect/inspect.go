@@ -0,0 +1,109 @@
+package inspect
+
+import (
+	"fmt"
+	"io"
+	"mast/output"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	titleStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#7C65C1")).Bold(true)
+	nameStyle    = lipgloss.NewStyle().Width(20).Foreground(lipgloss.Color("#767676"))
+	validStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#7C65C1"))
+	invalidStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6B6B"))
+	infoStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#767676"))
+)
+
+// Inspect decodes the message given as an argument, or read from file ("-"
+// for stdin), verifies it and prints every field. It returns an error when
+// any message fails verification.
+func Inspect(input string, file string, encoding string) error {
+	var raw []byte
+	switch {
+	case file == "-":
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		raw = data
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		raw = data
+	case input != "":
+		raw = []byte(input)
+	default:
+		return fmt.Errorf("a hex or base64 message, or --file, is required")
+	}
+
+	msgs, err := Decode(raw, encoding)
+	if err != nil {
+		return err
+	}
+	if len(msgs) == 0 {
+		return fmt.Errorf("No messages found")
+	}
+
+	invalid := 0
+	now := time.Now()
+	for i, msg := range msgs {
+		if output.IsJSON() {
+			r := inspectMessage(msg, now)
+			if !r.valid() {
+				invalid++
+			}
+			if err := output.Print(r.result()); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(msgs) > 1 {
+			fmt.Println(titleStyle.Render(fmt.Sprintf("Message %d of %d", i+1, len(msgs))))
+		}
+		r := inspectMessage(msg, now)
+		fmt.Print(render(r))
+		if !r.valid() {
+			invalid++
+		}
+	}
+
+	if invalid > 0 {
+		return output.Errorf(output.CodeInvalidInput, "%d of %d messages failed verification", invalid, len(msgs))
+	}
+	return nil
+}
+
+func render(r report) string {
+	var b strings.Builder
+	for _, s := range r.sections {
+		b.WriteString("\n" + titleStyle.Render(s.title) + "\n")
+		for _, f := range s.fields {
+			var mark string
+			switch f.status {
+			case statusValid:
+				mark = validStyle.Render("✓ valid")
+			case statusInvalid:
+				mark = invalidStyle.Render("✗ invalid")
+				if f.note != "" {
+					mark += invalidStyle.Render(" (" + f.note + ")")
+				}
+			default:
+				mark = infoStyle.Render("·")
+			}
+			fmt.Fprintf(&b, "  %s %s  %s\n", nameStyle.Render(f.name), f.value, mark)
+		}
+	}
+	if r.valid() {
+		b.WriteString("\n" + validStyle.Render("Message is valid") + "\n\n")
+	} else {
+		b.WriteString("\n" + invalidStyle.Render("Message is invalid") + "\n\n")
+	}
+	return b.String()
+}
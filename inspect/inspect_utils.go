@@ -0,0 +1,439 @@
+package inspect
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mast/message"
+	"mast/protobufs"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/golang/protobuf/proto"
+)
+
+type status int
+
+const (
+	statusInfo status = iota
+	statusValid
+	statusInvalid
+)
+
+type field struct {
+	name   string
+	value  string
+	status status
+	note   string
+}
+
+type section struct {
+	title  string
+	fields []field
+}
+
+type report struct {
+	sections []section
+}
+
+func (r report) valid() bool {
+	for _, s := range r.sections {
+		for _, f := range s.fields {
+			if f.status == statusInvalid {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Result is what an inspected message prints as under --output json
+type Result struct {
+	Valid    bool            `json:"valid"`
+	Sections []ResultSection `json:"sections"`
+}
+
+type ResultSection struct {
+	Title  string        `json:"title"`
+	Fields []ResultField `json:"fields"`
+}
+
+// ResultField has a status of info, valid or invalid, with note saying why
+// an invalid field failed
+type ResultField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Status string `json:"status"`
+	Note   string `json:"note,omitempty"`
+}
+
+var statusNames = map[status]string{statusInfo: "info", statusValid: "valid", statusInvalid: "invalid"}
+
+func (r report) result() Result {
+	result := Result{Valid: r.valid()}
+	for _, s := range r.sections {
+		section := ResultSection{Title: s.title, Fields: []ResultField{}}
+		for _, f := range s.fields {
+			section.Fields = append(section.Fields, ResultField{Name: f.name, Value: f.value, Status: statusNames[f.status], Note: f.note})
+		}
+		result.Sections = append(result.Sections, section)
+	}
+	return result
+}
+
+func (s *section) info(name string, value string) {
+	s.fields = append(s.fields, field{name: name, value: value, status: statusInfo})
+}
+
+// check records a field that is valid when ok is true, with note explaining
+// why it is not
+func (s *section) check(name string, value string, ok bool, note string) {
+	f := field{name: name, value: value, status: statusValid}
+	if !ok {
+		f.status = statusInvalid
+		f.note = note
+	}
+	s.fields = append(s.fields, f)
+}
+
+const (
+	castTextLimit    = 320
+	embedsLimit      = 2
+	mentionsLimit    = 10
+	embedURLLimit    = 256
+	linkTypeLimit    = 8
+	maxClockDrift    = 10 * time.Minute
+	castHashLength   = 20
+	ethAddressLength = 20
+)
+
+var userDataLimits = map[protobufs.UserDataType]int{
+	protobufs.UserDataType_USER_DATA_TYPE_PFP:     256,
+	protobufs.UserDataType_USER_DATA_TYPE_DISPLAY: 32,
+	protobufs.UserDataType_USER_DATA_TYPE_BIO:     256,
+	protobufs.UserDataType_USER_DATA_TYPE_URL:     256,
+}
+
+// Decode turns input into messages. The encoding is one of hex, base64,
+// binary (a single serialized message), delimited or json (files written by
+// mast sign), or auto to pick between hex, base64 and binary.
+func Decode(input []byte, encoding string) ([]*protobufs.Message, error) {
+	switch encoding {
+	case "delimited":
+		return message.Read(bytes.NewReader(input), message.FormatBinary)
+	case "json":
+		return message.Read(bytes.NewReader(input), message.FormatJSON)
+	}
+
+	raw, err := decodeBytes(input, encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &protobufs.Message{}
+	if err := proto.Unmarshal(raw, msg); err != nil {
+		return nil, fmt.Errorf("Failed to decode message: %v", err)
+	}
+	return []*protobufs.Message{msg}, nil
+}
+
+func decodeBytes(input []byte, encoding string) ([]byte, error) {
+	text := strings.Join(strings.FieldsFunc(string(input), unicode.IsSpace), "")
+
+	switch encoding {
+	case "hex":
+		return hex.DecodeString(strings.TrimPrefix(text, "0x"))
+	case "base64":
+		return decodeBase64(text)
+	case "binary":
+		return input, nil
+	case "", "auto":
+		if isHex(text) {
+			return hex.DecodeString(strings.TrimPrefix(text, "0x"))
+		}
+		if raw, err := decodeBase64(text); err == nil && text != "" {
+			return raw, nil
+		}
+		return input, nil
+	default:
+		return nil, fmt.Errorf("Unknown encoding %q, expected auto, hex, base64, binary, delimited or json", encoding)
+	}
+}
+
+func isHex(text string) bool {
+	text = strings.TrimPrefix(text, "0x")
+	if text == "" || len(text)%2 != 0 {
+		return false
+	}
+	_, err := hex.DecodeString(text)
+	return err == nil
+}
+
+func decodeBase64(text string) ([]byte, error) {
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if raw, err := encoding.DecodeString(text); err == nil {
+			return raw, nil
+		}
+	}
+	return nil, fmt.Errorf("Invalid base64 input")
+}
+
+func hexString(b []byte) string {
+	if len(b) == 0 {
+		return "(empty)"
+	}
+	return "0x" + hex.EncodeToString(b)
+}
+
+func castIDString(castID *protobufs.CastId) string {
+	return fmt.Sprintf("fid %d, hash %s", castID.GetFid(), hexString(castID.GetHash()))
+}
+
+// Validate runs the checks behind mast inspect and returns the decoded
+// MessageData, or an error naming the first invalid field
+func Validate(msg *protobufs.Message, now time.Time) (*protobufs.MessageData, error) {
+	r := inspectMessage(msg, now)
+	for _, s := range r.sections {
+		for _, f := range s.fields {
+			if f.status == statusInvalid {
+				return nil, fmt.Errorf("%s.%s %s", s.title, f.name, f.note)
+			}
+		}
+	}
+
+	dataBytes, err := message.MessageDataBytes(msg)
+	if err != nil {
+		return nil, err
+	}
+	msgData := &protobufs.MessageData{}
+	if err := proto.Unmarshal(dataBytes, msgData); err != nil {
+		return nil, fmt.Errorf("Failed to decode message data: %v", err)
+	}
+	return msgData, nil
+}
+
+// inspectMessage decodes the data in msg and checks every field the way a
+// hub would before merging it
+func inspectMessage(msg *protobufs.Message, now time.Time) report {
+	var r report
+
+	envelope := section{title: "Message"}
+	envelope.check("hashScheme", msg.HashScheme.String(),
+		msg.HashScheme == protobufs.HashScheme_HASH_SCHEME_BLAKE3, "only BLAKE3 is accepted")
+
+	dataBytes, dataErr := message.MessageDataBytes(msg)
+	if dataErr != nil {
+		envelope.check("hash", hexString(msg.Hash), false, dataErr.Error())
+	} else {
+		expected := message.Hash(dataBytes)
+		envelope.check("hash", hexString(msg.Hash), bytes.Equal(expected, msg.Hash),
+			fmt.Sprintf("blake3-160 of the data is %s", hexString(expected)))
+	}
+
+	envelope.check("signatureScheme", msg.SignatureScheme.String(),
+		msg.SignatureScheme == protobufs.SignatureScheme_SIGNATURE_SCHEME_ED25519, "only ED25519 is accepted")
+	envelope.check("signer", hexString(msg.Signer), len(msg.Signer) == ed25519.PublicKeySize,
+		fmt.Sprintf("expected a %d byte ed25519 public key", ed25519.PublicKeySize))
+
+	signatureOK := len(msg.Signer) == ed25519.PublicKeySize &&
+		ed25519.Verify(ed25519.PublicKey(msg.Signer), msg.Hash, msg.Signature)
+	envelope.check("signature", hexString(msg.Signature), signatureOK, "does not verify against signer and hash")
+	r.sections = append(r.sections, envelope)
+
+	if dataErr != nil {
+		return r
+	}
+
+	msgData := &protobufs.MessageData{}
+	data := section{title: "MessageData"}
+	if err := proto.Unmarshal(dataBytes, msgData); err != nil {
+		data.check("dataBytes", hexString(dataBytes), false, fmt.Sprintf("could not decode: %v", err))
+		r.sections = append(r.sections, data)
+		return r
+	}
+	if len(msg.DataBytes) > 0 && msg.Data != nil {
+		data.check("data", "set alongside dataBytes", proto.Equal(msg.Data, msgData), "does not match dataBytes")
+	}
+
+	data.check("type", msgData.Type.String(), bodyMatchesType(msgData), "does not match the body")
+	data.check("fid", fmt.Sprintf("%d", msgData.Fid), msgData.Fid > 0, "must be greater than zero")
+
+	ts := message.FromTimestamp(msgData.Timestamp)
+	data.check("timestamp", fmt.Sprintf("%d (%s)", msgData.Timestamp, ts.UTC().Format(time.RFC3339)),
+		!ts.After(now.Add(maxClockDrift)), "is more than 10 minutes in the future")
+	data.check("network", msgData.Network.String(),
+		msgData.Network != protobufs.FarcasterNetwork_FARCASTER_NETWORK_NONE, "must be set")
+	r.sections = append(r.sections, data)
+
+	r.sections = append(r.sections, inspectBody(msgData))
+	return r
+}
+
+func bodyMatchesType(msgData *protobufs.MessageData) bool {
+	switch msgData.Type {
+	case protobufs.MessageType_MESSAGE_TYPE_CAST_ADD:
+		return msgData.GetCastAddBody() != nil
+	case protobufs.MessageType_MESSAGE_TYPE_CAST_REMOVE:
+		return msgData.GetCastRemoveBody() != nil
+	case protobufs.MessageType_MESSAGE_TYPE_REACTION_ADD, protobufs.MessageType_MESSAGE_TYPE_REACTION_REMOVE:
+		return msgData.GetReactionBody() != nil
+	case protobufs.MessageType_MESSAGE_TYPE_LINK_ADD, protobufs.MessageType_MESSAGE_TYPE_LINK_REMOVE:
+		return msgData.GetLinkBody() != nil
+	case protobufs.MessageType_MESSAGE_TYPE_VERIFICATION_ADD_ETH_ADDRESS:
+		return msgData.GetVerificationAddEthAddressBody() != nil
+	case protobufs.MessageType_MESSAGE_TYPE_VERIFICATION_REMOVE:
+		return msgData.GetVerificationRemoveBody() != nil
+	case protobufs.MessageType_MESSAGE_TYPE_USER_DATA_ADD:
+		return msgData.GetUserDataBody() != nil
+	case protobufs.MessageType_MESSAGE_TYPE_USERNAME_PROOF:
+		return msgData.GetUsernameProofBody() != nil
+	default:
+		return false
+	}
+}
+
+func inspectBody(msgData *protobufs.MessageData) section {
+	switch body := msgData.Body.(type) {
+	case *protobufs.MessageData_CastAddBody:
+		return inspectCastAdd(body.CastAddBody)
+	case *protobufs.MessageData_CastRemoveBody:
+		s := section{title: "CastRemoveBody"}
+		s.check("targetHash", hexString(body.CastRemoveBody.TargetHash),
+			len(body.CastRemoveBody.TargetHash) == castHashLength, "must be a 20 byte cast hash")
+		return s
+	case *protobufs.MessageData_ReactionBody:
+		return inspectReaction(body.ReactionBody)
+	case *protobufs.MessageData_LinkBody:
+		return inspectLink(body.LinkBody)
+	case *protobufs.MessageData_UserDataBody:
+		return inspectUserData(body.UserDataBody)
+	case *protobufs.MessageData_VerificationAddEthAddressBody:
+		s := section{title: "VerificationAddEthAddressBody"}
+		v := body.VerificationAddEthAddressBody
+		s.check("address", hexString(v.Address), len(v.Address) == ethAddressLength, "must be a 20 byte address")
+		s.check("ethSignature", hexString(v.EthSignature), len(v.EthSignature) > 0, "must be set")
+		s.check("blockHash", hexString(v.BlockHash), len(v.BlockHash) == 32, "must be a 32 byte block hash")
+		s.info("verificationType", fmt.Sprintf("%d", v.VerificationType))
+		s.info("chainId", fmt.Sprintf("%d", v.ChainId))
+		return s
+	case *protobufs.MessageData_VerificationRemoveBody:
+		s := section{title: "VerificationRemoveBody"}
+		s.check("address", hexString(body.VerificationRemoveBody.Address),
+			len(body.VerificationRemoveBody.Address) == ethAddressLength, "must be a 20 byte address")
+		return s
+	case *protobufs.MessageData_UsernameProofBody:
+		return inspectUsernameProof(body.UsernameProofBody, msgData.Fid)
+	default:
+		s := section{title: "Body"}
+		s.check("body", "(none)", false, "message has no body")
+		return s
+	}
+}
+
+func inspectCastAdd(body *protobufs.CastAddBody) section {
+	s := section{title: "CastAddBody"}
+
+	s.check("text", fmt.Sprintf("%q", body.Text), len(body.Text) <= castTextLimit,
+		fmt.Sprintf("is %d bytes, the limit is %d", len(body.Text), castTextLimit))
+
+	s.check("mentions", fmt.Sprintf("%v", body.Mentions), len(body.Mentions) <= mentionsLimit,
+		fmt.Sprintf("at most %d mentions are allowed", mentionsLimit))
+	positionsOK := len(body.MentionsPositions) == len(body.Mentions)
+	for i, position := range body.MentionsPositions {
+		if int(position) > len(body.Text) || (i > 0 && position < body.MentionsPositions[i-1]) {
+			positionsOK = false
+		}
+	}
+	s.check("mentionsPositions", fmt.Sprintf("%v", body.MentionsPositions), positionsOK,
+		"must match mentions and be ascending offsets within the text")
+
+	s.check("embeds", fmt.Sprintf("%d", len(body.Embeds)), len(body.Embeds) <= embedsLimit,
+		fmt.Sprintf("at most %d embeds are allowed", embedsLimit))
+	for i, embed := range body.Embeds {
+		name := fmt.Sprintf("embeds[%d]", i)
+		switch e := embed.Embed.(type) {
+		case *protobufs.Embed_Url:
+			s.check(name, e.Url, e.Url != "" && len(e.Url) <= embedURLLimit,
+				fmt.Sprintf("url must be 1 to %d bytes", embedURLLimit))
+		case *protobufs.Embed_CastId:
+			s.check(name, castIDString(e.CastId), e.CastId.GetFid() > 0 && len(e.CastId.GetHash()) == castHashLength,
+				"cast id needs a fid and a 20 byte hash")
+		default:
+			s.check(name, "(empty)", false, "embed must be a url or cast id")
+		}
+	}
+
+	switch parent := body.Parent.(type) {
+	case *protobufs.CastAddBody_ParentUrl:
+		s.check("parentUrl", parent.ParentUrl, parent.ParentUrl != "" && len(parent.ParentUrl) <= embedURLLimit,
+			fmt.Sprintf("url must be 1 to %d bytes", embedURLLimit))
+	case *protobufs.CastAddBody_ParentCastId:
+		s.check("parentCastId", castIDString(parent.ParentCastId),
+			parent.ParentCastId.GetFid() > 0 && len(parent.ParentCastId.GetHash()) == castHashLength,
+			"cast id needs a fid and a 20 byte hash")
+	}
+
+	if len(body.Text) == 0 && len(body.Embeds) == 0 {
+		s.check("content", "(empty)", false, "cast needs text or an embed")
+	}
+	return s
+}
+
+func inspectReaction(body *protobufs.ReactionBody) section {
+	s := section{title: "ReactionBody"}
+	s.check("type", body.Type.String(), body.Type == protobufs.ReactionType_REACTION_TYPE_LIKE ||
+		body.Type == protobufs.ReactionType_REACTION_TYPE_RECAST, "must be LIKE or RECAST")
+
+	switch target := body.Target.(type) {
+	case *protobufs.ReactionBody_TargetCastId:
+		s.check("targetCastId", castIDString(target.TargetCastId),
+			target.TargetCastId.GetFid() > 0 && len(target.TargetCastId.GetHash()) == castHashLength,
+			"cast id needs a fid and a 20 byte hash")
+	case *protobufs.ReactionBody_TargetUrl:
+		s.check("targetUrl", target.TargetUrl, target.TargetUrl != "" && len(target.TargetUrl) <= embedURLLimit,
+			fmt.Sprintf("url must be 1 to %d bytes", embedURLLimit))
+	default:
+		s.check("target", "(none)", false, "reaction needs a target cast or url")
+	}
+	return s
+}
+
+func inspectLink(body *protobufs.LinkBody) section {
+	s := section{title: "LinkBody"}
+	s.check("type", body.Type, body.Type != "" && len(body.Type) <= linkTypeLimit,
+		fmt.Sprintf("must be 1 to %d bytes", linkTypeLimit))
+	s.check("targetFid", fmt.Sprintf("%d", body.GetTargetFid()), body.GetTargetFid() > 0, "must be greater than zero")
+	if body.DisplayTimestamp != nil {
+		s.info("displayTimestamp", fmt.Sprintf("%d (%s)", body.GetDisplayTimestamp(),
+			message.FromTimestamp(body.GetDisplayTimestamp()).UTC().Format(time.RFC3339)))
+	}
+	return s
+}
+
+func inspectUserData(body *protobufs.UserDataBody) section {
+	s := section{title: "UserDataBody"}
+	s.check("type", body.Type.String(), body.Type != protobufs.UserDataType_USER_DATA_TYPE_NONE, "must be set")
+
+	limit, ok := userDataLimits[body.Type]
+	if !ok {
+		s.info("value", fmt.Sprintf("%q", body.Value))
+		return s
+	}
+	s.check("value", fmt.Sprintf("%q", body.Value), len(body.Value) <= limit,
+		fmt.Sprintf("is %d bytes, the limit is %d", len(body.Value), limit))
+	return s
+}
+
+func inspectUsernameProof(body *protobufs.UserNameProof, fid uint64) section {
+	s := section{title: "UserNameProof"}
+	s.check("name", fmt.Sprintf("%q", string(body.Name)), len(body.Name) > 0, "must be set")
+	s.check("type", body.Type.String(), body.Type != protobufs.UserNameType_USERNAME_TYPE_NONE, "must be set")
+	s.check("fid", fmt.Sprintf("%d", body.Fid), body.Fid == fid, "must match the message fid")
+	s.check("owner", hexString(body.Owner), len(body.Owner) == ethAddressLength, "must be a 20 byte address")
+	s.check("signature", hexString(body.Signature), len(body.Signature) == 65, "must be a 65 byte eip-712 signature")
+	s.info("timestamp", fmt.Sprintf("%d (%s)", body.Timestamp, time.Unix(int64(body.Timestamp), 0).UTC().Format(time.RFC3339)))
+	return s
+}
@@ -0,0 +1,189 @@
+package inspect
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mast/message"
+	"mast/output"
+	"mast/protobufs"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+const testPrivateKey = "0707070707070707070707070707070707070707070707070707070707070707"
+
+func signed(t *testing.T, msgData *protobufs.MessageData) *protobufs.Message {
+	t.Helper()
+	msg, err := message.Sign(msgData, testPrivateKey)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	return msg
+}
+
+func testData(msgType protobufs.MessageType) *protobufs.MessageData {
+	return &protobufs.MessageData{
+		Type:      msgType,
+		Fid:       6596,
+		Timestamp: message.Timestamp(time.Now()),
+		Network:   protobufs.FarcasterNetwork_FARCASTER_NETWORK_MAINNET,
+	}
+}
+
+func castAdd(text string) *protobufs.MessageData {
+	msgData := testData(protobufs.MessageType_MESSAGE_TYPE_CAST_ADD)
+	msgData.Body = &protobufs.MessageData_CastAddBody{CastAddBody: &protobufs.CastAddBody{Text: text}}
+	return msgData
+}
+
+func invalidFields(r report) []string {
+	var names []string
+	for _, s := range r.sections {
+		for _, f := range s.fields {
+			if f.status == statusInvalid {
+				names = append(names, s.title+"."+f.name)
+			}
+		}
+	}
+	return names
+}
+
+func TestDecodeEncodings(t *testing.T) {
+	msg := signed(t, castAdd("gm"))
+	raw, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := map[string][]byte{
+		"hex":        []byte(hex.EncodeToString(raw)),
+		"0x hex":     []byte("0x" + hex.EncodeToString(raw) + "\n"),
+		"base64":     []byte(base64.StdEncoding.EncodeToString(raw)),
+		"raw base64": []byte(base64.RawURLEncoding.EncodeToString(raw)),
+		"binary":     raw,
+	}
+	for name, input := range inputs {
+		msgs, err := Decode(input, "auto")
+		if err != nil {
+			t.Errorf("%s: Decode: %v", name, err)
+			continue
+		}
+		if len(msgs) != 1 || !proto.Equal(msgs[0], msg) {
+			t.Errorf("%s: decoded message differs", name)
+		}
+	}
+
+	if _, err := Decode([]byte("0x00zz"), "hex"); err == nil {
+		t.Error("expected invalid hex to fail")
+	}
+}
+
+func TestInspectValidMessages(t *testing.T) {
+	target := &protobufs.CastId{Fid: 3, Hash: make([]byte, 20)}
+	reaction := testData(protobufs.MessageType_MESSAGE_TYPE_REACTION_ADD)
+	reaction.Body = &protobufs.MessageData_ReactionBody{ReactionBody: &protobufs.ReactionBody{
+		Type:   protobufs.ReactionType_REACTION_TYPE_LIKE,
+		Target: &protobufs.ReactionBody_TargetCastId{TargetCastId: target},
+	}}
+
+	link := testData(protobufs.MessageType_MESSAGE_TYPE_LINK_ADD)
+	link.Body = &protobufs.MessageData_LinkBody{LinkBody: &protobufs.LinkBody{
+		Type:   "follow",
+		Target: &protobufs.LinkBody_TargetFid{TargetFid: 3},
+	}}
+
+	userData := testData(protobufs.MessageType_MESSAGE_TYPE_USER_DATA_ADD)
+	userData.Body = &protobufs.MessageData_UserDataBody{UserDataBody: &protobufs.UserDataBody{
+		Type:  protobufs.UserDataType_USER_DATA_TYPE_BIO,
+		Value: "building mast",
+	}}
+
+	proof := testData(protobufs.MessageType_MESSAGE_TYPE_USERNAME_PROOF)
+	proof.Body = &protobufs.MessageData_UsernameProofBody{UsernameProofBody: &protobufs.UserNameProof{
+		Name:      []byte("stevedylandev"),
+		Type:      protobufs.UserNameType_USERNAME_TYPE_FNAME,
+		Fid:       6596,
+		Owner:     make([]byte, 20),
+		Signature: make([]byte, 65),
+	}}
+
+	messages := map[string]*protobufs.MessageData{
+		"cast":           castAdd("gm"),
+		"reaction":       reaction,
+		"link":           link,
+		"user data":      userData,
+		"username proof": proof,
+	}
+
+	for name, msgData := range messages {
+		r := inspectMessage(signed(t, msgData), time.Now())
+		if !r.valid() {
+			t.Errorf("%s: unexpected invalid fields %v", name, invalidFields(r))
+		}
+	}
+}
+
+func TestInspectFlagsInvalidFields(t *testing.T) {
+	tampered := signed(t, castAdd("gm"))
+	tampered.Signature[0] ^= 0xff
+	assertInvalid(t, "tampered signature", tampered, "Message.signature")
+
+	wrongHash := signed(t, castAdd("gm"))
+	wrongHash.Hash = message.Hash([]byte("something else"))
+	assertInvalid(t, "wrong hash", wrongHash, "Message.hash")
+
+	mismatched := castAdd("gm")
+	mismatched.Type = protobufs.MessageType_MESSAGE_TYPE_LINK_ADD
+	assertInvalid(t, "type mismatch", signed(t, mismatched), "MessageData.type")
+
+	long := castAdd(string(make([]byte, castTextLimit+1)))
+	assertInvalid(t, "long text", signed(t, long), "CastAddBody.text")
+}
+
+func assertInvalid(t *testing.T, name string, msg *protobufs.Message, want string) {
+	t.Helper()
+	for _, got := range invalidFields(inspectMessage(msg, time.Now())) {
+		if got == want {
+			return
+		}
+	}
+	t.Errorf("%s: expected %s to be invalid", name, want)
+}
+
+func TestInspectJSON(t *testing.T) {
+	output.SetFormat(output.JSON)
+	defer output.SetFormat(output.Text)
+
+	tampered := signed(t, castAdd("gm"))
+	tampered.Signature[0] ^= 0xff
+	for _, c := range []struct {
+		msg   *protobufs.Message
+		valid bool
+	}{{signed(t, castAdd("gm")), true}, {tampered, false}} {
+		msgBytes, _ := proto.Marshal(c.msg)
+
+		stdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		err := Inspect(hex.EncodeToString(msgBytes), "", "hex")
+		w.Close()
+		os.Stdout = stdout
+		printed, _ := io.ReadAll(r)
+
+		if c.valid != (err == nil) || (err != nil && output.Code(err) != output.CodeInvalidInput) {
+			t.Errorf("Inspect = %v for a message with valid = %v", err, c.valid)
+		}
+		var result Result
+		if err := json.Unmarshal(printed, &result); err != nil {
+			t.Fatalf("printed %q, want one JSON object: %v", printed, err)
+		}
+		if result.Valid != c.valid || len(result.Sections) == 0 {
+			t.Errorf("valid = %v with %d sections, want %v", result.Valid, len(result.Sections), c.valid)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	// verboseBodyBytes is how much of a response --verbose logs, --debug
+	// logs up to debugBodyBytes
+	verboseBodyBytes = 512
+	debugBodyBytes   = 16 << 10
+)
+
+// Setup logs every outbound HTTP request to stderr, with its status,
+// latency and response body. debug adds request headers and bodies and the
+// MessageData of signed messages. Without verbose or debug nothing changes.
+func Setup(verbose bool, debug bool) {
+	if !verbose && !debug {
+		return
+	}
+	level := slog.LevelInfo
+	if debug {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	})))
+	// Every client mast creates without its own transport goes through
+	// DefaultTransport, which covers hub, auth, login and compose
+	http.DefaultTransport = &Transport{Base: http.DefaultTransport}
+}
+
+// redactAttr keeps secrets out of the log even when they are logged by name
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if IsSecret(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+// Transport logs each request it sends through Base
+type Transport struct {
+	Base http.RoundTripper
+	// Logger defaults to slog.Default()
+	Logger *slog.Logger
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	logger := t.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	debug := logger.Enabled(req.Context(), slog.LevelDebug)
+	target := RedactURL(req.URL)
+
+	if debug {
+		attrs := []any{"method", req.Method, "url", target, "headers", RedactHeaders(req.Header)}
+		if body := requestBody(req); body != "" {
+			attrs = append(attrs, "body", truncate(RedactBody(body), debugBodyBytes))
+		} else if req.ContentLength > 0 {
+			attrs = append(attrs, "bytes", req.ContentLength)
+		}
+		logger.Debug("HTTP request", attrs...)
+	}
+
+	start := time.Now()
+	resp, err := t.Base.RoundTrip(req)
+	latency := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		// url.Error repeats the URL, which may carry a token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		logger.Info("HTTP request failed", "method", req.Method, "url", target, "latency", latency, "error", err.Error())
+		return nil, err
+	}
+
+	attrs := []any{"method", req.Method, "url", target, "status", resp.StatusCode, "latency", latency}
+	if isText(resp.Header.Get("Content-Type")) {
+		data, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), errReader{readErr}))
+
+		max := verboseBodyBytes
+		if debug {
+			max = debugBodyBytes
+		}
+		attrs = append(attrs, "body", truncate(RedactBody(string(data)), max))
+	} else if resp.ContentLength >= 0 {
+		attrs = append(attrs, "bytes", resp.ContentLength)
+	}
+	if debug {
+		attrs = append(attrs, "headers", RedactHeaders(resp.Header))
+	}
+	logger.Log(context.Background(), slog.LevelInfo, "HTTP response", attrs...)
+	return resp, nil
+}
+
+// requestBody returns a copy of a text request body, leaving req untouched
+func requestBody(req *http.Request) string {
+	if req.GetBody == nil || !isText(req.Header.Get("Content-Type")) {
+		return ""
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+	data, _ := io.ReadAll(io.LimitReader(body, debugBodyBytes+1))
+	return string(data)
+}
+
+// errReader hands a read error back to the caller once the buffered body
+// has been read, nil meaning the body ended normally
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, io.EOF
+}
@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// redacted replaces every secret mast logs
+const redacted = "REDACTED"
+
+// secretWords mark header, query, JSON and log attribute names whose values
+// are never logged
+var secretWords = []string{"key", "token", "secret", "password", "signature", "signer", "credential", "authorization", "cookie"}
+
+// jsonStringField matches "name": "value" pairs in a JSON body
+var jsonStringField = regexp.MustCompile(`"([A-Za-z0-9_\-]+)"(\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// IsSecret reports whether a header, query parameter, JSON field or log
+// attribute called name holds a secret, such as x-api-key or privateKey
+func IsSecret(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range secretWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactURL returns u with its password and secret query parameters, such
+// as the login polling token, replaced
+func RedactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	clean := *u
+	if _, ok := clean.User.Password(); ok {
+		clean.User = url.UserPassword(clean.User.Username(), redacted)
+	}
+	query := clean.Query()
+	changed := false
+	for name := range query {
+		if IsSecret(name) {
+			query[name] = []string{redacted}
+			changed = true
+		}
+	}
+	if changed {
+		clean.RawQuery = query.Encode()
+	}
+	return clean.String()
+}
+
+// RedactHeaders flattens h for logging with secret headers replaced, in
+// name order
+func RedactHeaders(h http.Header) []string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		value := strings.Join(h[name], ", ")
+		if IsSecret(name) {
+			value = redacted
+		}
+		lines = append(lines, name+": "+value)
+	}
+	return lines
+}
+
+// RedactBody replaces the values of secret JSON fields in body, such as
+// privateKey and signer. Hashes and other public values are kept so
+// submits can be traced.
+func RedactBody(body string) string {
+	return jsonStringField.ReplaceAllStringFunc(body, func(field string) string {
+		m := jsonStringField.FindStringSubmatch(field)
+		if !IsSecret(m[1]) {
+			return field
+		}
+		return `"` + m[1] + `"` + m[2] + `"` + redacted + `"`
+	})
+}
+
+// truncate shortens s to max bytes for logging
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "…"
+}
+
+// isText reports whether a body of contentType is worth logging, binary
+// bodies such as protobufs and uploads are only logged by size
+func isText(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, kind := range []string{"json", "text/", "xml", "x-www-form-urlencoded"} {
+		if strings.Contains(contentType, kind) {
+			return true
+		}
+	}
+	return false
+}
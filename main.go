@@ -2,13 +2,34 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	auth "mast/auth"
+	channels "mast/channels"
 	compose "mast/compose"
+	devhub "mast/devhub"
+	export "mast/export"
+	feed "mast/feed"
 	hub "mast/hub"
+	inspect "mast/inspect"
+	logging "mast/logging"
 	login "mast/login"
+	message "mast/message"
+	notifications "mast/notifications"
+	output "mast/output"
+	release "mast/release"
+	restore "mast/restore"
+	rss "mast/rss"
+	serve "mast/serve"
+	submit "mast/submit"
+	templates "mast/templates"
+	thread "mast/thread"
+	upload "mast/upload"
+	whois "mast/whois"
 
 	"github.com/urfave/cli/v2"
 )
@@ -44,12 +65,37 @@ func main() {
      +++++++++++++++++++++++++
       +++++++++++++++++++++++
 		`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "output",
+				Value:   output.Text,
+				EnvVars: []string{"MAST_OUTPUT"},
+				Usage:   "Print results as text or json, one JSON object per line with errors as JSON too and the TUIs turned off",
+			},
+			&cli.BoolFlag{
+				Name:    "verbose",
+				EnvVars: []string{"MAST_VERBOSE"},
+				Usage:   "Log every HTTP request to stderr with its status, latency and response body, secrets redacted",
+			},
+			&cli.BoolFlag{
+				Name:    "debug",
+				EnvVars: []string{"MAST_DEBUG"},
+				Usage:   "Like --verbose, also logging headers, request bodies and the MessageData of signed messages",
+			},
+		},
+		Before: func(ctx *cli.Context) error {
+			logging.Setup(ctx.Bool("verbose"), ctx.Bool("debug"))
+			return output.SetFormat(ctx.String("output"))
+		},
 		Commands: []*cli.Command{
 			{
 				Name:    "auth",
 				Aliases: []string{"a"},
 				Usage:   "Authorize the CLI with your Signer Private Key and FID",
 				Action: func(ctx *cli.Context) error {
+					if output.IsJSON() {
+						return output.Interactive("mast auth")
+					}
 					return auth.SetFidAndPrivateKey()
 				},
 			},
@@ -58,6 +104,9 @@ func main() {
 				Aliases: []string{"l"},
 				Usage:   "Login with Farcaster mobile app via QR code",
 				Action: func(ctx *cli.Context) error {
+					if output.IsJSON() {
+						return output.Interactive("mast login")
+					}
 					return login.Login()
 				},
 			},
@@ -65,60 +114,770 @@ func main() {
 				Name:    "new",
 				Aliases: []string{"n"},
 				Usage:   "Send a new Cast",
+				Flags: append(castFlags(),
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Build and sign the cast, then print it instead of sending",
+					},
+					&cli.StringFlag{
+						Name:  "encoding",
+						Value: "hex",
+						Usage: "Encoding for the signed message bytes printed by --dry-run (hex or base64), requires --dry-run",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Send even if you are not a member of the channel",
+					},
+				),
+				Action: func(ctx *cli.Context) error {
+					opts := compose.SendOptions{
+						DryRun:   ctx.Bool("dry-run"),
+						Encoding: ctx.String("encoding"),
+						Force:    ctx.Bool("force"),
+					}
+					if ctx.IsSet("encoding") && !opts.DryRun {
+						return fmt.Errorf("--encoding only applies together with --dry-run")
+					}
+					if err := message.ValidateEncoding(opts.Encoding); err != nil {
+						return err
+					}
+					network, err := hub.ResolveNetwork(ctx.String("network"))
+					if err != nil {
+						return err
+					}
+					opts.Network = network
+
+					if ctx.String("template") != "" {
+						casts, err := templateCasts(ctx)
+						if err != nil {
+							return err
+						}
+						return compose.SendThread(casts, opts)
+					}
+					castData, err := castDataFromFlags(ctx)
+					if err != nil {
+						return err
+					}
+					return compose.SendCast(castData, opts)
+				},
+			},
+			{
+				Name:  "sign",
+				Usage: "Sign a Cast without sending it, for submitting later with mast submit",
+				Flags: append(castFlags(),
+					&cli.StringFlag{
+						Name:    "out",
+						Aliases: []string{"o"},
+						Usage:   "File to write the signed message to (default: stdout)",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Value: message.FormatBinary,
+						Usage: "Output format for the signed message (binary or json)",
+					},
+					&cli.BoolFlag{
+						Name:  "append",
+						Usage: "Append to --out instead of overwriting it",
+					},
+				),
+				Action: func(ctx *cli.Context) error {
+					network, err := hub.ResolveNetwork(ctx.String("network"))
+					if err != nil {
+						return err
+					}
+					castData, err := castDataFromFlags(ctx)
+					if err != nil {
+						return err
+					}
+					return compose.SignCast(castData, network, ctx.String("out"), ctx.String("format"), ctx.Bool("append"))
+				},
+			},
+			{
+				Name:      "submit",
+				Usage:     "Submit signed messages from a file created by mast sign",
+				ArgsUsage: "<file|->",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:    "message",
-						Aliases: []string{"m"},
-						Usage:   "Cast message text",
+						Name:  "format",
+						Value: message.FormatBinary,
+						Usage: "Format of the message file (binary or json)",
 					},
+				},
+				Action: func(ctx *cli.Context) error {
+					if ctx.NArg() != 1 {
+						return fmt.Errorf("a message file (or - for stdin) is required")
+					}
+					return submit.SubmitFile(ctx.Args().First(), ctx.String("format"))
+				},
+			},
+			{
+				Name:      "inspect",
+				Usage:     "Decode and verify a Farcaster message",
+				ArgsUsage: "<hex|base64>",
+				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:    "url",
-						Aliases: []string{"u"},
-						Usage:   "URL to embed in the cast",
+						Name:    "file",
+						Aliases: []string{"f"},
+						Usage:   "Read the message from a file instead of an argument (- for stdin)",
 					},
 					&cli.StringFlag{
-						Name:    "url2",
-						Aliases: []string{"u2"},
-						Usage:   "Second URL to embed in the cast",
+						Name:  "encoding",
+						Value: "auto",
+						Usage: "Input encoding: auto, hex, base64, binary, or delimited/json for files written by mast sign",
 					},
+				},
+				Action: func(ctx *cli.Context) error {
+					return inspect.Inspect(ctx.Args().First(), ctx.String("file"), ctx.String("encoding"))
+				},
+			},
+			{
+				Name:      "network",
+				Usage:     "Show or set the preferred Farcaster network",
+				ArgsUsage: "[mainnet|testnet|devnet]",
+				Action: func(ctx *cli.Context) error {
+					if ctx.NArg() == 0 {
+						network, err := hub.RetrieveNetworkPreference()
+						if err != nil {
+							return err
+						}
+						return output.PrintSetting("network", hub.NetworkName(network), false, hub.NetworkName(network))
+					}
+					network, err := hub.ParseNetwork(ctx.Args().First())
+					if err != nil {
+						return err
+					}
+					if err := hub.SaveNetworkPreference(network); err != nil {
+						return err
+					}
+					return output.PrintSetting("network", hub.NetworkName(network), true, "Network preference saved!")
+				},
+			},
+			{
+				Name:      "feed",
+				Usage:     "Browse the casts of a user or a channel",
+				ArgsUsage: "<fid|username>",
+				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:    "channel",
 						Aliases: []string{"c"},
-						Usage:   "Channel ID for the cast",
+						Usage:   "Show the casts in this channel instead of a user's",
+					},
+					&cli.StringFlag{
+						Name:  "network",
+						Usage: "Farcaster network to sign replies and likes for (default: saved preference or mainnet)",
 					},
 				},
 				Action: func(ctx *cli.Context) error {
-					message := ctx.String("message")
-					url1 := ctx.String("url")
-					url2 := ctx.String("url2")
-					channel := ctx.String("channel")
-
-					if message != "" || url1 != "" || url2 != "" || channel != "" {
-						castData := compose.CastData{
-							Message: message,
-							URL1:    url1,
-							URL2:    url2,
-							Channel: channel,
-						}
-
-						if message == "" && url1 == "" && url2 == "" {
-							return fmt.Errorf("at least a message or URL must be provided")
-						}
-
-						return compose.SendCast(castData)
+					if output.IsJSON() {
+						return output.Interactive("mast feed")
 					}
-
-					castData, err := compose.ComposeCast()
+					network, err := hub.ResolveNetwork(ctx.String("network"))
+					if err != nil {
+						return err
+					}
+					if ctx.String("channel") != "" {
+						return feed.ShowChannel(ctx.String("channel"), network)
+					}
+					if ctx.NArg() != 1 {
+						return fmt.Errorf("a fid or username, or --channel, is required")
+					}
+					return feed.ShowUser(ctx.Args().First(), network)
+				},
+			},
+			{
+				Name:      "show",
+				Usage:     "Show a cast with its whole thread",
+				ArgsUsage: "<hash>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "fid",
+						Usage: "Fid or username of the cast's author (required)",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print the thread as JSON instead of opening the viewer",
+					},
+					&cli.BoolFlag{
+						Name:  "markdown",
+						Usage: "Print the thread as Markdown instead of opening the viewer",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					if ctx.NArg() != 1 {
+						return fmt.Errorf("a cast hash is required")
+					}
+					format := ""
+					switch {
+					case (ctx.Bool("json") || output.IsJSON()) && ctx.Bool("markdown"):
+						return fmt.Errorf("--json and --markdown can't be used together")
+					case ctx.Bool("json") || output.IsJSON():
+						format = "json"
+					case ctx.Bool("markdown"):
+						format = "markdown"
+					}
+					return thread.ShowCast(ctx.Args().First(), ctx.String("fid"), format)
+				},
+			},
+			{
+				Name:      "whois",
+				Usage:     "Show the profile of a fid or username",
+				ArgsUsage: "<fid|username>",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print the profile as JSON instead of a card",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					if ctx.NArg() != 1 {
+						return fmt.Errorf("a fid or username is required")
+					}
+					return whois.Show(ctx.Args().First(), ctx.Bool("json") || output.IsJSON())
+				},
+			},
+			{
+				Name:  "notifications",
+				Usage: "Show mentions, replies and reactions to your casts",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "since",
+						Usage: "Show activity since a duration ago (24h, 7d) or a date, instead of since last seen",
+					},
+					&cli.IntFlag{
+						Name:  "casts",
+						Value: 25,
+						Usage: "How many of your latest casts to check for replies and reactions",
+					},
+					&cli.BoolFlag{
+						Name:  "jsonl",
+						Usage: "Print one JSON object per line instead of opening the list",
+					},
+					&cli.BoolFlag{
+						Name:  "keep-unread",
+						Usage: "Don't move the last seen marker",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					return notifications.Show(notifications.Options{
+						Since:       ctx.String("since"),
+						RecentCasts: ctx.Int("casts"),
+						JSONL:       ctx.Bool("jsonl") || output.IsJSON(),
+						KeepUnread:  ctx.Bool("keep-unread"),
+					})
+				},
+			},
+			{
+				Name:  "export",
+				Usage: "Archive every message of an account",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "fid",
+						Usage: "Fid or username to export (default: your account)",
+					},
+					&cli.StringFlag{
+						Name:    "out",
+						Aliases: []string{"o"},
+						Usage:   "Directory for the archive and its state (default: mast-export-<fid>)",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Value: "jsonl",
+						Usage: "Archive format: jsonl, csv or markdown",
+					},
+					&cli.BoolFlag{
+						Name:  "raw",
+						Usage: "Also save the signed messages as length-prefixed protobufs for mast submit",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					return export.Export(ctx.String("fid"), ctx.String("out"), ctx.String("format"), ctx.Bool("raw"))
+				},
+			},
+			{
+				Name:      "restore",
+				Usage:     "Replay archived signed messages to the configured hub",
+				ArgsUsage: "<file|->",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Value: message.FormatBinary,
+						Usage: "Format of the message file (binary or json)",
+					},
+					&cli.Float64Flag{
+						Name:  "rate",
+						Value: 5,
+						Usage: "Most messages to submit per second",
+					},
+					&cli.IntFlag{
+						Name:  "retries",
+						Value: 5,
+						Usage: "How many times to retry a rate limited message before stopping",
+					},
+					&cli.StringFlag{
+						Name:  "checkpoint",
+						Usage: "File to save progress to (default: <file>.restore.json)",
+					},
+					&cli.BoolFlag{
+						Name:  "restart",
+						Usage: "Ignore the checkpoint and replay from the first message",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					if ctx.NArg() != 1 {
+						return fmt.Errorf("a message file (or - for stdin) is required")
+					}
+					if ctx.Args().First() == "-" && ctx.String("checkpoint") == "" {
+						return fmt.Errorf("--checkpoint is required when reading from stdin")
+					}
+					return restore.Restore(ctx.Args().First(), ctx.String("format"), restore.Options{
+						Rate:       ctx.Float64("rate"),
+						Retries:    ctx.Int("retries"),
+						Checkpoint: ctx.String("checkpoint"),
+						Restart:    ctx.Bool("restart"),
+					})
+				},
+			},
+			{
+				Name:      "rss",
+				Usage:     "Cast new items from RSS or Atom feeds",
+				ArgsUsage: "<feed-url> [feed-url...]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "template",
+						Aliases: []string{"t"},
+						Value:   rss.DefaultTemplate,
+						Usage:   "Cast text as a Go template with .Title, .Link, .Summary, .FeedTitle and .Published",
+					},
+					&cli.StringFlag{
+						Name:    "channel",
+						Aliases: []string{"c"},
+						Usage:   "Channel ID or parent URL to cast into",
+					},
+					&cli.BoolFlag{
+						Name:  "once",
+						Usage: "Poll the feeds once and exit, for cron",
+					},
+					&cli.DurationFlag{
+						Name:  "interval",
+						Value: 15 * time.Minute,
+						Usage: "Time between polls when running continuously",
+					},
+					&cli.IntFlag{
+						Name:  "max",
+						Value: 5,
+						Usage: "Most casts per feed in one poll, 0 for no limit",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Print what would be cast without sending or marking anything as seen",
+					},
+					&cli.StringFlag{
+						Name:  "network",
+						Usage: "Farcaster network to sign for: mainnet, testnet or devnet (default: saved preference or mainnet)",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Cast even if you are not a member of the channel",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					network, err := hub.ResolveNetwork(ctx.String("network"))
+					if err != nil {
+						return err
+					}
+					if ctx.Duration("interval") < time.Minute {
+						return fmt.Errorf("--interval must be at least a minute")
+					}
+					return rss.Run(rss.Options{
+						Feeds:    ctx.Args().Slice(),
+						Template: ctx.String("template"),
+						Channel:  ctx.String("channel"),
+						Once:     ctx.Bool("once"),
+						Interval: ctx.Duration("interval"),
+						Max:      ctx.Int("max"),
+						DryRun:   ctx.Bool("dry-run"),
+						Network:  network,
+						Force:    ctx.Bool("force"),
+					})
+				},
+			},
+			{
+				Name:  "release",
+				Usage: "Announce the latest git tag from the changelog or commit messages",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "tag",
+						Usage: "Tag to announce (default: latest tag reachable from HEAD)",
+					},
+					&cli.StringFlag{
+						Name:    "channel",
+						Aliases: []string{"c"},
+						Usage:   "Channel ID or parent URL to cast into",
+					},
+					&cli.StringFlag{
+						Name:  "url",
+						Usage: "Repository URL to embed (default: the origin remote)",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Print the drafted casts instead of opening them for review",
+					},
+					&cli.StringFlag{
+						Name:  "network",
+						Usage: "Farcaster network to sign for: mainnet, testnet or devnet (default: saved preference or mainnet)",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Cast even if you are not a member of the channel",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					network, err := hub.ResolveNetwork(ctx.String("network"))
 					if err != nil {
 						return err
 					}
-					return compose.SendCast(castData)
+					return release.Announce(release.Options{
+						Dir:     ".",
+						Tag:     ctx.String("tag"),
+						Channel: ctx.String("channel"),
+						URL:     ctx.String("url"),
+						DryRun:  ctx.Bool("dry-run"),
+						Network: network,
+						Force:   ctx.Bool("force"),
+					})
+				},
+			},
+			{
+				Name:  "serve",
+				Usage: "Run a local REST API that casts for other tools",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "addr",
+						Value: serve.DefaultAddr,
+						Usage: "Loopback address to listen on",
+					},
+					&cli.StringFlag{
+						Name:  "socket",
+						Usage: "Listen on a unix socket at this path instead of --addr",
+					},
+					&cli.StringFlag{
+						Name:    "token",
+						EnvVars: []string{"MAST_SERVE_TOKEN"},
+						Usage:   "Bearer token clients must send (default: generated and saved in ~/.fc-cast-serve-token)",
+					},
+					&cli.StringFlag{
+						Name:  "network",
+						Usage: "Farcaster network to sign for: mainnet, testnet or devnet (default: saved preference or mainnet)",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Cast even if you are not a member of the channel",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					network, err := hub.ResolveNetwork(ctx.String("network"))
+					if err != nil {
+						return err
+					}
+					return serve.Serve(serve.Options{
+						Addr:    ctx.String("addr"),
+						Socket:  ctx.String("socket"),
+						Token:   ctx.String("token"),
+						Network: network,
+						Force:   ctx.Bool("force"),
+					})
+				},
+			},
+			{
+				Name:  "uploader",
+				Usage: "Set where attached files are uploaded, or show the current uploader",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "type",
+						Usage: "Uploader type: ipfs, s3 or http",
+					},
+					&cli.StringFlag{
+						Name:  "url",
+						Usage: "http: base URL files are PUT under",
+					},
+					&cli.StringSliceFlag{
+						Name:  "header",
+						Usage: "http: extra request header as \"Name: value\", can be repeated",
+					},
+					&cli.StringFlag{
+						Name:  "endpoint",
+						Usage: "ipfs: pinning API URL (default: Pinata), s3: S3 API URL such as https://s3.us-east-1.amazonaws.com",
+					},
+					&cli.StringFlag{
+						Name:  "token",
+						Usage: "ipfs: bearer token for the pinning API",
+					},
+					&cli.StringFlag{
+						Name:  "gateway",
+						Usage: "ipfs: gateway to link files from (default: https://ipfs.io)",
+					},
+					&cli.StringFlag{
+						Name:  "bucket",
+						Usage: "s3: bucket name",
+					},
+					&cli.StringFlag{
+						Name:  "region",
+						Usage: "s3: region to sign for (default: us-east-1)",
+					},
+					&cli.StringFlag{
+						Name:  "access-key",
+						Usage: "s3: access key ID",
+					},
+					&cli.StringFlag{
+						Name:  "secret-key",
+						Usage: "s3: secret access key",
+					},
+					&cli.BoolFlag{
+						Name:  "path-style",
+						Usage: "s3: put the bucket in the path instead of the host name, for MinIO and similar",
+					},
+					&cli.StringFlag{
+						Name:  "public-url",
+						Usage: "Base URL uploaded files are served from, when it differs from where they are uploaded",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					if ctx.NumFlags() == 0 {
+						return upload.ShowConfig()
+					}
+					headers := map[string]string{}
+					for _, header := range ctx.StringSlice("header") {
+						name, value, ok := strings.Cut(header, ":")
+						if !ok {
+							return fmt.Errorf("--header should look like \"Name: value\", got %q", header)
+						}
+						headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+					}
+					if len(headers) == 0 {
+						headers = nil
+					}
+					return upload.Configure(upload.Config{
+						Type:      ctx.String("type"),
+						URL:       ctx.String("url"),
+						Headers:   headers,
+						Endpoint:  ctx.String("endpoint"),
+						Token:     ctx.String("token"),
+						Gateway:   ctx.String("gateway"),
+						Bucket:    ctx.String("bucket"),
+						Region:    ctx.String("region"),
+						AccessKey: ctx.String("access-key"),
+						SecretKey: ctx.String("secret-key"),
+						PathStyle: ctx.Bool("path-style"),
+						PublicURL: ctx.String("public-url"),
+					})
+				},
+			},
+			{
+				Name:      "upload",
+				Usage:     "Upload files with the saved uploader and print their URLs",
+				ArgsUsage: "<file> [file...]",
+				Action: func(ctx *cli.Context) error {
+					return upload.Files(ctx.Args().Slice())
+				},
+			},
+			{
+				Name:  "templates",
+				Usage: "Manage cast templates used with mast new --template",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "list",
+						Usage: "List the saved templates and their variables",
+						Action: func(ctx *cli.Context) error {
+							return templates.ListTemplates()
+						},
+					},
+					{
+						Name:      "show",
+						Usage:     "Print a template",
+						ArgsUsage: "<name>",
+						Action: func(ctx *cli.Context) error {
+							if ctx.NArg() != 1 {
+								return fmt.Errorf("a template name is required")
+							}
+							return templates.Show(ctx.Args().First())
+						},
+					},
+					{
+						Name:      "edit",
+						Usage:     "Create or edit a template in $EDITOR",
+						ArgsUsage: "<name>",
+						Action: func(ctx *cli.Context) error {
+							if ctx.NArg() != 1 {
+								return fmt.Errorf("a template name is required")
+							}
+							return templates.Edit(ctx.Args().First())
+						},
+					},
+				},
+			},
+			{
+				Name:  "channels",
+				Usage: "Search the cached channel directory",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "search",
+						Usage:     "Fuzzy search channels by id or name",
+						ArgsUsage: "<query>",
+						Flags: []cli.Flag{
+							&cli.IntFlag{
+								Name:  "limit",
+								Value: 20,
+								Usage: "Maximum number of channels to list",
+							},
+							&cli.BoolFlag{
+								Name:  "refresh",
+								Usage: "Fetch the directory again even if the cache is fresh",
+							},
+						},
+						Action: func(ctx *cli.Context) error {
+							query := strings.Join(ctx.Args().Slice(), " ")
+							if !output.IsJSON() {
+								return channels.SearchChannels(query, ctx.Int("limit"), ctx.Bool("refresh"))
+							}
+							matches, err := channels.Matches(query, ctx.Int("limit"), ctx.Bool("refresh"))
+							if err != nil {
+								return err
+							}
+							for _, c := range matches {
+								if err := output.Print(c); err != nil {
+									return err
+								}
+							}
+							return nil
+						},
+					},
+					{
+						Name:  "refresh",
+						Usage: "Fetch the channel directory and update the cache",
+						Action: func(ctx *cli.Context) error {
+							if !output.IsJSON() {
+								return channels.Refresh()
+							}
+							directory, err := channels.Directory(true)
+							if err != nil {
+								return err
+							}
+							return output.Print(map[string]int{"cached": len(directory)})
+						},
+					},
+					{
+						Name:      "ttl",
+						Usage:     "Show or set how long the channel cache stays fresh",
+						ArgsUsage: "[duration, e.g. 6h]",
+						Action: func(ctx *cli.Context) error {
+							if ctx.NArg() == 0 {
+								ttl := channels.RetrieveTTLPreference()
+								return output.PrintSetting("ttl", ttl.String(), false, ttl.String())
+							}
+							ttl, err := time.ParseDuration(ctx.Args().First())
+							if err != nil || ttl < 0 {
+								return output.Errorf(output.CodeInvalidInput, "Invalid TTL %q, expected a duration such as 30m or 12h", ctx.Args().First())
+							}
+							if err := channels.SaveTTLPreference(ttl); err != nil {
+								return err
+							}
+							return output.PrintSetting("ttl", ttl.String(), true, "Channel cache TTL saved!")
+						},
+					},
+					{
+						Name:      "map",
+						Usage:     "Map a channel id to a parent URL in the local mapping file",
+						ArgsUsage: "<channel-id> <parent-url>",
+						Action: func(ctx *cli.Context) error {
+							if ctx.NArg() != 2 {
+								return fmt.Errorf("a channel id and a parent URL are required")
+							}
+							channelID, parentURL := ctx.Args().Get(0), ctx.Args().Get(1)
+							if err := channels.AddMapping(channelID, parentURL); err != nil {
+								return err
+							}
+							return output.PrintSetting("mapping", map[string]string{"channel": strings.ToLower(strings.TrimPrefix(channelID, "/")), "parent_url": parentURL}, true, "Channel mapping saved!")
+						},
+					},
+					{
+						Name:      "resolvers",
+						Usage:     "Show or set the order channel resolvers are tried in",
+						ArgsUsage: "[file] [warpcast] [neynar]",
+						Action: func(ctx *cli.Context) error {
+							if ctx.NArg() == 0 {
+								names, err := channels.RetrieveResolverPreference()
+								if err != nil {
+									return err
+								}
+								return output.PrintSetting("resolvers", names, false, strings.Join(names, " "))
+							}
+							if err := channels.SaveResolverPreference(ctx.Args().Slice()); err != nil {
+								return err
+							}
+							return output.PrintSetting("resolvers", ctx.Args().Slice(), true, "Channel resolver preference saved!")
+						},
+					},
+				},
+			},
+			{
+				Name:  "dev",
+				Usage: "Tools for testing mast without a real hub",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "hub",
+						Usage: "Run a local mock hub that validates and stores messages",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "addr",
+								Value: "127.0.0.1:2281",
+								Usage: "Address to listen on",
+							},
+							&cli.StringFlag{
+								Name:  "network",
+								Value: "devnet",
+								Usage: "Network the mock hub accepts messages for (mainnet, testnet or devnet)",
+							},
+							&cli.StringFlag{
+								Name:  "data",
+								Usage: "File to persist messages to, kept in memory only when empty",
+							},
+							&cli.StringSliceFlag{
+								Name:  "signer",
+								Usage: "Only accept this signer, as fid:0xpublickey (repeatable, default: accept any signer)",
+							},
+						},
+						Action: func(ctx *cli.Context) error {
+							network, err := hub.ParseNetwork(ctx.String("network"))
+							if err != nil {
+								return err
+							}
+
+							signers := map[uint64][][]byte{}
+							for _, value := range ctx.StringSlice("signer") {
+								fid, key, err := devhub.ParseSigner(value)
+								if err != nil {
+									return err
+								}
+								signers[fid] = append(signers[fid], key)
+							}
+
+							return devhub.Serve(devhub.Options{
+								Addr:     ctx.String("addr"),
+								Network:  network,
+								DataFile: ctx.String("data"),
+								Signers:  signers,
+							})
+						},
+					},
 				},
 			},
 			{
 				Name:  "hub",
 				Usage: "Set a preferred Hub",
 				Action: func(ctx *cli.Context) error {
+					if output.IsJSON() {
+						return output.Interactive("mast hub")
+					}
 					return hub.SetHub()
 				},
 			},
@@ -126,6 +885,238 @@ func main() {
 	}
 
 	if err := app.Run(os.Args); err != nil {
+		if output.IsJSON() {
+			output.PrintError(err)
+			os.Exit(1)
+		}
 		log.Fatal(err)
 	}
 }
+
+func castFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "message",
+			Aliases: []string{"m"},
+			Usage:   "Cast message text",
+		},
+		&cli.StringFlag{
+			Name:    "url",
+			Aliases: []string{"u"},
+			Usage:   "URL to embed in the cast",
+		},
+		&cli.StringFlag{
+			Name:    "url2",
+			Aliases: []string{"u2"},
+			Usage:   "Second URL to embed in the cast",
+		},
+		&cli.StringSliceFlag{
+			Name:    "embed",
+			Aliases: []string{"e"},
+			Usage:   "Embed a URL, a cast as cast:<fid|username>:<hash> or a file as file:<path>, can be repeated",
+		},
+		&cli.StringFlag{
+			Name:    "channel",
+			Aliases: []string{"c"},
+			Usage:   "Channel ID for the cast, or a channel URL to use as the parent URL",
+		},
+		&cli.StringFlag{
+			Name:  "parent-url",
+			Usage: "Parent URL for the cast, used instead of resolving --channel",
+		},
+		&cli.StringFlag{
+			Name:  "network",
+			Usage: "Farcaster network to sign for: mainnet, testnet or devnet (default: saved preference or mainnet)",
+		},
+		&cli.StringFlag{
+			Name:  "file",
+			Usage: "Read the cast from a file, with optional frontmatter for embed, channel, parent-url, reply and reply-fid",
+		},
+		&cli.BoolFlag{
+			Name:  "editor",
+			Usage: "Write the cast in $EDITOR, starting from a frontmatter template filled in from the other flags",
+		},
+		&cli.StringFlag{
+			Name:    "template",
+			Aliases: []string{"t"},
+			Usage:   "Fill the cast in from a template saved with mast templates edit",
+		},
+		&cli.StringSliceFlag{
+			Name:  "var",
+			Usage: "Set a template variable as name=value, can be repeated",
+		},
+		&cli.StringSliceFlag{
+			Name:  "attach",
+			Usage: "Upload a file with the uploader set by mast uploader and embed its URL, can be repeated, same as --embed file:<path>",
+		},
+	}
+}
+
+// castDataFromFlags builds CastData from the cast flags, from stdin when the
+// argument is -, from --file or from $EDITOR, falling back to the compose
+// TUI when none of them are set
+func castDataFromFlags(ctx *cli.Context) (compose.CastData, error) {
+	embeds, err := embedsFromFlags(ctx)
+	if err != nil {
+		return compose.CastData{}, err
+	}
+	castData := compose.CastData{
+		Message:   ctx.String("message"),
+		Embeds:    embeds,
+		Channel:   ctx.String("channel"),
+		ParentURL: ctx.String("parent-url"),
+	}
+
+	if ctx.String("template") != "" {
+		casts, err := templateCasts(ctx)
+		if err != nil {
+			return compose.CastData{}, err
+		}
+		if len(casts) > 1 {
+			return compose.CastData{}, fmt.Errorf("template %s splits into %d casts, send the thread with mast new", ctx.String("template"), len(casts))
+		}
+		return casts[0], nil
+	}
+	if len(ctx.StringSlice("var")) > 0 {
+		return compose.CastData{}, fmt.Errorf("--var only applies together with --template")
+	}
+
+	fromStdin := ctx.Args().First() == "-"
+	if fromStdin && ctx.NArg() > 1 {
+		return compose.CastData{}, fmt.Errorf("flags go before -, as in mast %s --channel dev -", ctx.Command.Name)
+	}
+	if ctx.NArg() > 0 && !fromStdin {
+		return compose.CastData{}, fmt.Errorf("unexpected argument %q, use - to read the cast from stdin", ctx.Args().First())
+	}
+	sources := 0
+	for _, set := range []bool{fromStdin, ctx.String("file") != "", ctx.Bool("editor")} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return compose.CastData{}, fmt.Errorf("-, --file and --editor can't be used together")
+	}
+	if sources == 1 && castData.Message != "" {
+		return compose.CastData{}, fmt.Errorf("--message can't be combined with -, --file or --editor")
+	}
+
+	if ctx.Bool("editor") {
+		return compose.EditDraft(castData)
+	}
+	if sources == 1 {
+		var data []byte
+		var err error
+		if fromStdin {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(ctx.String("file"))
+		}
+		if err != nil {
+			return compose.CastData{}, fmt.Errorf("Failed to read the cast: %v", err)
+		}
+		draft, err := compose.ParseDraft(string(data))
+		if err != nil {
+			return compose.CastData{}, err
+		}
+		castData = mergeDraft(castData, draft)
+		if castData.Message == "" && len(castData.Embeds) == 0 {
+			return compose.CastData{}, fmt.Errorf("the cast is empty, at least a message or embed must be provided")
+		}
+		if castData.Embeds, err = compose.NormalizeEmbeds(castData.Embeds); err != nil {
+			return compose.CastData{}, err
+		}
+		return castData, nil
+	}
+
+	if castData.Message == "" && len(castData.Embeds) == 0 && castData.Channel == "" && castData.ParentURL == "" {
+		return compose.ComposeCast()
+	}
+
+	if castData.Message == "" && len(castData.Embeds) == 0 {
+		return compose.CastData{}, fmt.Errorf("at least a message or embed must be provided")
+	}
+
+	return castData, nil
+}
+
+// mergeDraft fills castData with the fields set in a draft's frontmatter,
+// which take precedence over the flags
+func mergeDraft(castData compose.CastData, draft compose.CastData) compose.CastData {
+	castData.Message = draft.Message
+	if len(draft.Embeds) > 0 {
+		castData.Embeds = draft.Embeds
+	}
+	if draft.Channel != "" {
+		castData.Channel = draft.Channel
+	}
+	if draft.ParentURL != "" {
+		castData.ParentURL = draft.ParentURL
+	}
+	if draft.ParentCast != nil {
+		castData.ParentCast = draft.ParentCast
+	}
+	return castData
+}
+
+// templateCasts renders --template with the --var values. --channel,
+// --parent-url and the embed flags take precedence over the template.
+func templateCasts(ctx *cli.Context) ([]compose.CastData, error) {
+	if ctx.String("message") != "" || ctx.String("file") != "" || ctx.Bool("editor") || ctx.NArg() > 0 {
+		return nil, fmt.Errorf("--template can't be combined with --message, -, --file or --editor")
+	}
+	tmpl, err := templates.Load(ctx.String("template"))
+	if err != nil {
+		return nil, err
+	}
+	vars, err := templates.ParseVars(ctx.StringSlice("var"))
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := tmpl.Render(vars)
+	if err != nil {
+		return nil, err
+	}
+	casts, err := compose.FromTemplate(rendered)
+	if err != nil {
+		return nil, err
+	}
+
+	embeds, err := embedsFromFlags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(embeds) > 0 {
+		casts[0].Embeds = embeds
+	}
+	if ctx.String("channel") != "" || ctx.String("parent-url") != "" {
+		casts[0].Channel = ctx.String("channel")
+		casts[0].ParentURL = ctx.String("parent-url")
+	}
+	if casts[0].Message == "" && len(casts[0].Embeds) == 0 {
+		return nil, fmt.Errorf("template %s rendered an empty cast", tmpl.Name)
+	}
+	return casts, nil
+}
+
+// embedsFromFlags collects --url, --url2, --embed and --attach into one
+// list, checked against the embed limit before anything is uploaded
+func embedsFromFlags(ctx *cli.Context) ([]compose.Embed, error) {
+	var embeds []compose.Embed
+	for _, u := range []string{ctx.String("url"), ctx.String("url2")} {
+		if u != "" {
+			embeds = append(embeds, compose.Embed{Kind: compose.EmbedURL, URL: u})
+		}
+	}
+	for _, value := range ctx.StringSlice("embed") {
+		embed, err := compose.ParseEmbed(value)
+		if err != nil {
+			return nil, err
+		}
+		embeds = append(embeds, embed)
+	}
+	for _, path := range ctx.StringSlice("attach") {
+		embeds = append(embeds, compose.Embed{Kind: compose.EmbedFile, Path: path})
+	}
+	return compose.NormalizeEmbeds(embeds)
+}
@@ -0,0 +1,154 @@
+package message
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mast/protobufs"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/zeebo/blake3"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const farcasterEpoch int64 = 1609459200 // January 1, 2021 UTC
+
+// Timestamp converts t into seconds since the Farcaster epoch
+func Timestamp(t time.Time) uint32 {
+	return uint32(t.Unix() - farcasterEpoch)
+}
+
+// FromTimestamp converts seconds since the Farcaster epoch back into a time
+func FromTimestamp(ts uint32) time.Time {
+	return time.Unix(int64(ts)+farcasterEpoch, 0)
+}
+
+// Hash returns the truncated blake3-160 digest hubs expect for dataBytes
+func Hash(dataBytes []byte) []byte {
+	hasher := blake3.New()
+	hasher.Write(dataBytes)
+	return hasher.Sum(nil)[:20]
+}
+
+// ParsePrivateKey decodes a hex encoded ed25519 seed, with or without 0x
+func ParsePrivateKey(privateKeyHex string) (ed25519.PrivateKey, error) {
+	privateKeyHex = strings.TrimPrefix(strings.TrimSpace(privateKeyHex), "0x")
+	privateKeyBytes, err := hex.DecodeString(privateKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid hex string: %v", err)
+	}
+	if len(privateKeyBytes) != ed25519.SeedSize {
+		return nil, fmt.Errorf("Invalid private key: must be exactly 32 bytes (64 hex characters)")
+	}
+	return ed25519.NewKeyFromSeed(privateKeyBytes), nil
+}
+
+// Sign serializes msgData, hashes it and signs the hash with the signer key
+func Sign(msgData *protobufs.MessageData, privateKeyHex string) (*protobufs.Message, error) {
+	msgDataBytes, err := proto.Marshal(msgData)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode message data: %v", err)
+	}
+
+	privateKey, err := ParsePrivateKey(privateKeyHex)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := Hash(msgDataBytes)
+
+	return &protobufs.Message{
+		HashScheme:      protobufs.HashScheme_HASH_SCHEME_BLAKE3,
+		Hash:            hash,
+		SignatureScheme: protobufs.SignatureScheme_SIGNATURE_SCHEME_ED25519,
+		Signature:       ed25519.Sign(privateKey, hash),
+		Signer:          privateKey.Public().(ed25519.PublicKey),
+		DataBytes:       msgDataBytes,
+	}, nil
+}
+
+// MessageDataBytes returns the serialized MessageData the hash and signature
+// cover, falling back to encoding Data when DataBytes is not set
+func MessageDataBytes(msg *protobufs.Message) ([]byte, error) {
+	if len(msg.DataBytes) > 0 {
+		return msg.DataBytes, nil
+	}
+	if msg.Data == nil {
+		return nil, fmt.Errorf("Message has no data")
+	}
+	return proto.Marshal(msg.Data)
+}
+
+// Verify recomputes the blake3 hash of msg and checks its ed25519 signature
+func Verify(msg *protobufs.Message) error {
+	dataBytes, err := MessageDataBytes(msg)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(Hash(dataBytes), msg.Hash) {
+		return fmt.Errorf("Hash does not match message data")
+	}
+	if len(msg.Signer) != ed25519.PublicKeySize {
+		return fmt.Errorf("Signer must be a %d byte ed25519 public key", ed25519.PublicKeySize)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(msg.Signer), msg.Hash, msg.Signature) {
+		return fmt.Errorf("Signature is not valid for signer")
+	}
+	return nil
+}
+
+// ValidateEncoding checks that encoding is one EncodeBytes understands
+func ValidateEncoding(encoding string) error {
+	switch encoding {
+	case "", "hex", "base64":
+		return nil
+	default:
+		return fmt.Errorf("Unknown encoding %q, expected hex or base64", encoding)
+	}
+}
+
+// EncodeBytes renders b as "hex" (0x prefixed) or "base64"
+func EncodeBytes(b []byte, encoding string) (string, error) {
+	if err := ValidateEncoding(encoding); err != nil {
+		return "", err
+	}
+	if encoding == "base64" {
+		return base64.StdEncoding.EncodeToString(b), nil
+	}
+	return "0x" + hex.EncodeToString(b), nil
+}
+
+// Print writes a human readable dump of a signed message that was not submitted
+func Print(w io.Writer, msgData *protobufs.MessageData, msg *protobufs.Message, encoding string) error {
+	dataJSON, err := protojson.MarshalOptions{Multiline: true}.Marshal(msgData)
+	if err != nil {
+		return fmt.Errorf("Failed to encode message data as json: %v", err)
+	}
+
+	msgBytes, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("Failed to encode message: %v", err)
+	}
+	encoded, err := EncodeBytes(msgBytes, encoding)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "MessageData:\n%s\n\n", dataJSON)
+	fmt.Fprintf(w, "Hash: 0x%s\n", hex.EncodeToString(msg.Hash))
+	fmt.Fprintf(w, "Signer: 0x%s\n\n", hex.EncodeToString(msg.Signer))
+	fmt.Fprintf(w, "Message (%s):\n%s\n", encodingName(encoding), encoded)
+	return nil
+}
+
+func encodingName(encoding string) string {
+	if encoding == "" {
+		return "hex"
+	}
+	return encoding
+}
@@ -0,0 +1,106 @@
+package message
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"mast/protobufs"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/zeebo/blake3"
+)
+
+const testPrivateKey = "0x0707070707070707070707070707070707070707070707070707070707070707"
+
+func TestHashIsBlake3_160(t *testing.T) {
+	input := []byte("farcaster message data")
+
+	full := blake3.Sum256(input)
+	got := Hash(input)
+
+	if len(got) != 20 {
+		t.Fatalf("hash length = %d, want 20", len(got))
+	}
+	if !bytes.Equal(got, full[:20]) {
+		t.Fatalf("Hash = %x, want %x", got, full[:20])
+	}
+}
+
+func TestSignProducesVerifiableSignature(t *testing.T) {
+	msgData := &protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_CAST_ADD,
+		Fid:       6596,
+		Timestamp: 100,
+		Network:   protobufs.FarcasterNetwork_FARCASTER_NETWORK_MAINNET,
+		Body: &protobufs.MessageData_CastAddBody{
+			CastAddBody: &protobufs.CastAddBody{Text: "gm"},
+		},
+	}
+
+	msg, err := Sign(msgData, testPrivateKey)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	dataBytes, err := proto.Marshal(msgData)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if !bytes.Equal(msg.DataBytes, dataBytes) {
+		t.Fatal("DataBytes does not match the encoded MessageData")
+	}
+	if !bytes.Equal(msg.Hash, Hash(dataBytes)) {
+		t.Fatalf("Hash = %x, want %x", msg.Hash, Hash(dataBytes))
+	}
+	if !ed25519.Verify(ed25519.PublicKey(msg.Signer), msg.Hash, msg.Signature) {
+		t.Fatal("signature does not verify against signer and hash")
+	}
+
+	key, err := ParsePrivateKey(testPrivateKey)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey: %v", err)
+	}
+	if !bytes.Equal(msg.Signer, key.Public().(ed25519.PublicKey)) {
+		t.Fatal("signer is not the public key of the private key")
+	}
+	if msg.HashScheme != protobufs.HashScheme_HASH_SCHEME_BLAKE3 {
+		t.Errorf("HashScheme = %v", msg.HashScheme)
+	}
+	if msg.SignatureScheme != protobufs.SignatureScheme_SIGNATURE_SCHEME_ED25519 {
+		t.Errorf("SignatureScheme = %v", msg.SignatureScheme)
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	valid := "0707070707070707070707070707070707070707070707070707070707070707"
+
+	for _, key := range []string{valid, "0x" + valid, " 0x" + valid + "\n"} {
+		if _, err := ParsePrivateKey(key); err != nil {
+			t.Errorf("ParsePrivateKey(%q): %v", key, err)
+		}
+	}
+
+	invalid := map[string]string{
+		"too short": valid[:62],
+		"too long":  valid + "07",
+		"odd":       valid[:63],
+		"non-hex":   "zz" + valid[2:],
+		"empty":     "",
+	}
+	for name, key := range invalid {
+		if _, err := ParsePrivateKey(key); err == nil {
+			t.Errorf("%s: ParsePrivateKey(%q) returned no error", name, key)
+		}
+	}
+}
+
+func TestValidateEncoding(t *testing.T) {
+	for _, encoding := range []string{"", "hex", "base64"} {
+		if err := ValidateEncoding(encoding); err != nil {
+			t.Errorf("ValidateEncoding(%q): %v", encoding, err)
+		}
+	}
+	if err := ValidateEncoding("base32"); err == nil {
+		t.Error("expected base32 to be rejected")
+	}
+}
@@ -0,0 +1,112 @@
+package message
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mast/protobufs"
+	"os"
+
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	FormatBinary = "binary"
+	FormatJSON   = "json"
+)
+
+// Write appends msgs to w as varint length-prefixed protobufs ("binary") or
+// as one protojson object per line ("json")
+func Write(w io.Writer, msgs []*protobufs.Message, format string) error {
+	for _, msg := range msgs {
+		switch format {
+		case "", FormatBinary:
+			if _, err := protodelim.MarshalTo(w, msg); err != nil {
+				return fmt.Errorf("Failed to encode message: %v", err)
+			}
+		case FormatJSON:
+			msgJSON, err := protojson.Marshal(msg)
+			if err != nil {
+				return fmt.Errorf("Failed to encode message as json: %v", err)
+			}
+			if _, err := fmt.Fprintf(w, "%s\n", msgJSON); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("Unknown format %q, expected binary or json", format)
+		}
+	}
+	return nil
+}
+
+// Read decodes every message in r using the given format, either
+// length-prefixed "binary" or newline separated "json"
+func Read(r io.Reader, format string) ([]*protobufs.Message, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "", FormatBinary:
+		return readBinary(data)
+	case FormatJSON:
+		return readJSON(data)
+	default:
+		return nil, fmt.Errorf("Unknown format %q, expected binary or json", format)
+	}
+}
+
+// ReadFile reads messages from path, or from stdin when path is "-"
+func ReadFile(path string, format string) ([]*protobufs.Message, error) {
+	if path == "-" {
+		return Read(os.Stdin, format)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f, format)
+}
+
+func readJSON(data []byte) ([]*protobufs.Message, error) {
+	var msgs []*protobufs.Message
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			return msgs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode json message %d: %v", len(msgs)+1, err)
+		}
+
+		msg := &protobufs.Message{}
+		if err := protojson.Unmarshal(raw, msg); err != nil {
+			return nil, fmt.Errorf("Failed to decode json message %d: %v", len(msgs)+1, err)
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func readBinary(data []byte) ([]*protobufs.Message, error) {
+	var msgs []*protobufs.Message
+	reader := bufio.NewReader(bytes.NewReader(data))
+	for {
+		msg := &protobufs.Message{}
+		err := protodelim.UnmarshalFrom(reader, msg)
+		if errors.Is(err, io.EOF) {
+			return msgs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode message %d: %v", len(msgs)+1, err)
+		}
+		msgs = append(msgs, msg)
+	}
+}
@@ -0,0 +1,74 @@
+package message
+
+import (
+	"bytes"
+	"mast/protobufs"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+func signedTestMessages(t *testing.T) []*protobufs.Message {
+	t.Helper()
+
+	var msgs []*protobufs.Message
+	for _, text := range []string{"gm", strings.Repeat("a", 72), "hello\nworld"} {
+		msg, err := Sign(&protobufs.MessageData{
+			Type:      protobufs.MessageType_MESSAGE_TYPE_CAST_ADD,
+			Fid:       6596,
+			Timestamp: 100,
+			Network:   protobufs.FarcasterNetwork_FARCASTER_NETWORK_MAINNET,
+			Body: &protobufs.MessageData_CastAddBody{
+				CastAddBody: &protobufs.CastAddBody{Text: text},
+			},
+		}, testPrivateKey)
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	msgs := signedTestMessages(t)
+
+	for _, format := range []string{FormatBinary, FormatJSON} {
+		var buf bytes.Buffer
+		if err := Write(&buf, msgs, format); err != nil {
+			t.Fatalf("%s: Write: %v", format, err)
+		}
+
+		got, err := Read(&buf, format)
+		if err != nil {
+			t.Fatalf("%s: Read: %v", format, err)
+		}
+		if len(got) != len(msgs) {
+			t.Fatalf("%s: read %d messages, want %d", format, len(got), len(msgs))
+		}
+		for i := range msgs {
+			if !proto.Equal(got[i], msgs[i]) {
+				t.Errorf("%s: message %d differs after round trip", format, i)
+			}
+			if err := Verify(got[i]); err != nil {
+				t.Errorf("%s: message %d failed verification: %v", format, i, err)
+			}
+		}
+	}
+}
+
+func TestReadUnknownFormat(t *testing.T) {
+	if _, err := Read(strings.NewReader(""), "yaml"); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	msg := signedTestMessages(t)[0]
+	msg.DataBytes = append([]byte{}, msg.DataBytes...)
+	msg.DataBytes[len(msg.DataBytes)-1] ^= 0xff
+	if err := Verify(msg); err == nil {
+		t.Fatal("expected tampered data to fail verification")
+	}
+}
@@ -0,0 +1,229 @@
+package notifications
+
+import (
+	"fmt"
+	"io"
+	"mast/auth"
+	"mast/feed"
+	"mast/thread"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const itemHeight = 3
+
+var (
+	titleStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFFFF")).Background(lipgloss.Color("#7C65C1")).Padding(0, 1)
+	accentStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#7C65C1")).Bold(true)
+	mutedStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#767676"))
+	selectedStyle = lipgloss.NewStyle().Border(lipgloss.NormalBorder(), false, false, false, true).BorderForeground(lipgloss.Color("#7C65C1")).PaddingLeft(1)
+	itemStyle     = lipgloss.NewStyle().PaddingLeft(2)
+)
+
+var icons = map[string]string{
+	TypeMention: "@",
+	TypeReply:   "↩",
+	TypeLike:    "♥",
+	TypeRecast:  "⟳",
+}
+
+type notificationItem struct {
+	Notification
+	unread bool
+}
+
+func (i notificationItem) FilterValue() string { return i.Summary() + " " + i.Text }
+
+// notificationDelegate renders a notification as a summary line followed by
+// the cast text and the cast it was about
+type notificationDelegate struct{}
+
+func (d notificationDelegate) Height() int                               { return itemHeight }
+func (d notificationDelegate) Spacing() int                              { return 1 }
+func (d notificationDelegate) Update(msg tea.Msg, m *list.Model) tea.Cmd { return nil }
+
+func (d notificationDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	n, ok := item.(notificationItem)
+	if !ok {
+		return
+	}
+	width := m.Width() - 4
+	if width < 20 {
+		width = 20
+	}
+
+	marker := "  "
+	if n.unread {
+		marker = accentStyle.Render("● ")
+	}
+	header := marker + icons[n.Type] + " " + accentStyle.Render(n.Summary()) + mutedStyle.Render(" · "+feed.RelativeTime(n.Timestamp, time.Now()))
+	lines := []string{header}
+	if text := oneLine(n.Text); text != "" {
+		lines = append(lines, "  "+clip(text, width-2))
+	}
+	if target := oneLine(n.TargetText); target != "" {
+		lines = append(lines, mutedStyle.Render("  ↳ "+clip(target, width-4)))
+	}
+	for len(lines) < itemHeight {
+		lines = append(lines, "")
+	}
+
+	style := itemStyle
+	if index == m.Index() {
+		style = selectedStyle
+	}
+	fmt.Fprint(w, style.Render(strings.Join(lines[:itemHeight], "\n")))
+}
+
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func clip(text string, width int) string {
+	runes := []rune(text)
+	if width < 1 || len(runes) <= width {
+		return text
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+var openKey = key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "open thread"))
+
+type model struct {
+	list list.Model
+	// open is set when the list quits so the thread can be shown
+	open *Notification
+}
+
+func newModel(notifications []Notification, lastSeen time.Time) model {
+	items := make([]list.Item, len(notifications))
+	unread := 0
+	for i, n := range notifications {
+		item := notificationItem{Notification: n, unread: n.Timestamp.After(lastSeen)}
+		if item.unread {
+			unread++
+		}
+		items[i] = item
+	}
+
+	l := list.New(items, notificationDelegate{}, 80, 24)
+	l.Title = fmt.Sprintf("Notifications · %d unread", unread)
+	l.Styles.Title = titleStyle
+	l.SetStatusBarItemName("notification", "notifications")
+	l.AdditionalShortHelpKeys = func() []key.Binding { return []key.Binding{openKey} }
+	l.AdditionalFullHelpKeys = l.AdditionalShortHelpKeys
+
+	return model{list: l}
+}
+
+func (m model) Init() tea.Cmd {
+	return nil
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.list.SetSize(msg.Width, msg.Height)
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return m, tea.Quit
+		}
+		if m.list.FilterState() != list.Filtering && key.Matches(msg, openKey) {
+			if selected, ok := m.list.SelectedItem().(notificationItem); ok {
+				m.open = &selected.Notification
+				return m, tea.Quit
+			}
+		}
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m model) View() string {
+	return m.list.View()
+}
+
+// Options controls what Show fetches and how it is printed
+type Options struct {
+	// Since overrides the last seen marker, see ParseSince
+	Since string
+	// RecentCasts is how many of the latest casts are checked for replies
+	// and reactions
+	RecentCasts int
+	JSONL       bool
+	// KeepUnread leaves the last seen marker where it was
+	KeepUnread bool
+}
+
+// Show collects the notifications for the configured fid and lists them,
+// or writes them as JSON lines, then marks them as seen
+func Show(opts Options) error {
+	fid, _, err := auth.FindFidAndPrivateKey()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	lastSeen := LastSeen(now)
+	since := lastSeen
+	if opts.Since != "" {
+		if since, err = ParseSince(opts.Since, now); err != nil {
+			return err
+		}
+	}
+
+	notifications, err := Collect(fid, since, opts.RecentCasts, feed.NewUsernames())
+	if err != nil {
+		return err
+	}
+
+	if opts.JSONL {
+		err = WriteJSONL(os.Stdout, notifications)
+	} else if len(notifications) == 0 {
+		fmt.Printf("No notifications since %s\n", since.Local().Format("Jan 2 15:04"))
+	} else {
+		err = browse(fid, notifications, lastSeen)
+	}
+	if err != nil || opts.KeepUnread {
+		return err
+	}
+	return MarkSeen(notifications)
+}
+
+// browse opens the list, and the thread of a notification when one is
+// picked, returning to the list afterwards
+func browse(fid uint64, notifications []Notification, lastSeen time.Time) error {
+	m := newModel(notifications, lastSeen)
+	for {
+		result, err := tea.NewProgram(m, tea.WithAltScreen()).Run()
+		if err != nil {
+			return err
+		}
+		m = result.(model)
+		if m.open == nil {
+			return nil
+		}
+
+		n := *m.open
+		m.open = nil
+		castFid, castHash := n.Fid, n.Hash
+		if n.Type == TypeLike || n.Type == TypeRecast {
+			castFid, castHash = fid, n.TargetHash
+		}
+		hash, err := thread.ParseHash(castHash)
+		if err != nil {
+			return err
+		}
+		if err := thread.Show(castFid, hash, ""); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package notifications
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mast/feed"
+	"mast/hub"
+	"mast/message"
+	"mast/protobufs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TypeMention = "mention"
+	TypeReply   = "reply"
+	TypeLike    = "like"
+	TypeRecast  = "recast"
+
+	// maxPages bounds how far back mentions are paged
+	maxPages = 10
+	// defaultWindow is how far back to look when nothing has been seen yet
+	defaultWindow = 7 * 24 * time.Hour
+)
+
+// Notification is one thing that happened to fid: a mention, a reply to one
+// of its casts, or a reaction to one of them
+type Notification struct {
+	Type       string    `json:"type"`
+	Fid        uint64    `json:"fid"`
+	Username   string    `json:"username,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+	Hash       string    `json:"hash"`
+	Text       string    `json:"text,omitempty"`
+	TargetHash string    `json:"targetHash,omitempty"`
+	TargetText string    `json:"targetText,omitempty"`
+}
+
+// Author is @username, or fid:N when the username is unknown
+func (n Notification) Author() string {
+	if n.Username != "" {
+		return "@" + n.Username
+	}
+	return fmt.Sprintf("fid:%d", n.Fid)
+}
+
+// Summary is the one line description shown in the list
+func (n Notification) Summary() string {
+	switch n.Type {
+	case TypeMention:
+		return fmt.Sprintf("%s mentioned you", n.Author())
+	case TypeReply:
+		return fmt.Sprintf("%s replied to your cast", n.Author())
+	case TypeLike:
+		return fmt.Sprintf("%s liked your cast", n.Author())
+	case TypeRecast:
+		return fmt.Sprintf("%s recast your cast", n.Author())
+	}
+	return n.Author()
+}
+
+type collector struct {
+	fid       uint64
+	since     time.Time
+	usernames *feed.Usernames
+	found     []Notification
+}
+
+func (c *collector) addCast(kind string, cast feed.Cast, target *feed.Cast) {
+	if cast.Fid == c.fid || !cast.Timestamp.After(c.since) {
+		return
+	}
+	c.usernames.Fill(&cast)
+	n := Notification{
+		Type:      kind,
+		Fid:       cast.Fid,
+		Username:  cast.Author,
+		Timestamp: cast.Timestamp,
+		Hash:      fmt.Sprintf("0x%x", cast.Hash),
+		Text:      cast.Text,
+	}
+	if target != nil {
+		n.TargetHash = fmt.Sprintf("0x%x", target.Hash)
+		n.TargetText = target.Text
+	}
+	c.found = append(c.found, n)
+}
+
+// pages calls fn with every message from path, newest first, until a page
+// ends before since
+func (c *collector) pages(path string, params url.Values, fn func(*protobufs.Message)) error {
+	params.Set("reverse", "true")
+	for i := 0; i < maxPages; i++ {
+		page, err := hub.GetMessages(path, params)
+		if err != nil {
+			return err
+		}
+		older := false
+		for _, msg := range page.Messages {
+			if message.FromTimestamp(msg.GetData().GetTimestamp()).After(c.since) {
+				fn(msg)
+			} else {
+				older = true
+			}
+		}
+		if older || page.NextPageToken == "" {
+			return nil
+		}
+		params.Set("pageToken", page.NextPageToken)
+	}
+	return nil
+}
+
+// Collect gathers notifications for fid newer than since. Replies and
+// reactions are looked up on the latest recentCasts casts fid has published.
+func Collect(fid uint64, since time.Time, recentCasts int, usernames *feed.Usernames) ([]Notification, error) {
+	c := &collector{fid: fid, since: since, usernames: usernames}
+	fidParam := strconv.FormatUint(fid, 10)
+
+	err := c.pages("/v1/castsByMention", url.Values{"fid": {fidParam}}, func(msg *protobufs.Message) {
+		if cast, ok := feed.FromMessage(msg); ok {
+			c.addCast(TypeMention, cast, nil)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := hub.GetMessages("/v1/castsByFid", url.Values{
+		"fid":      {fidParam},
+		"reverse":  {"true"},
+		"pageSize": {strconv.Itoa(recentCasts)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, msg := range page.Messages {
+		mine, ok := feed.FromMessage(msg)
+		if !ok {
+			continue
+		}
+		hash := "0x" + hex.EncodeToString(mine.Hash)
+
+		err := c.pages("/v1/castsByParent", url.Values{"fid": {fidParam}, "hash": {hash}}, func(msg *protobufs.Message) {
+			if reply, ok := feed.FromMessage(msg); ok {
+				c.addCast(TypeReply, reply, &mine)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		err = c.pages("/v1/reactionsByCast", url.Values{"target_fid": {fidParam}, "target_hash": {hash}}, func(msg *protobufs.Message) {
+			c.addReaction(msg, &mine)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(c.found, func(i, j int) bool { return c.found[i].Timestamp.After(c.found[j].Timestamp) })
+	return c.found, nil
+}
+
+func (c *collector) addReaction(msg *protobufs.Message, target *feed.Cast) {
+	msgData := msg.GetData()
+	if msgData.GetType() != protobufs.MessageType_MESSAGE_TYPE_REACTION_ADD || msgData.Fid == c.fid {
+		return
+	}
+
+	kind := TypeLike
+	switch msgData.GetReactionBody().GetType() {
+	case protobufs.ReactionType_REACTION_TYPE_LIKE:
+	case protobufs.ReactionType_REACTION_TYPE_RECAST:
+		kind = TypeRecast
+	default:
+		return
+	}
+
+	c.found = append(c.found, Notification{
+		Type:       kind,
+		Fid:        msgData.Fid,
+		Username:   c.usernames.Get(msgData.Fid),
+		Timestamp:  message.FromTimestamp(msgData.Timestamp),
+		Hash:       fmt.Sprintf("0x%x", msg.Hash),
+		TargetHash: fmt.Sprintf("0x%x", target.Hash),
+		TargetText: target.Text,
+	})
+}
+
+// WriteJSONL writes one notification per line
+func WriteJSONL(w io.Writer, notifications []Notification) error {
+	encoder := json.NewEncoder(w)
+	for _, n := range notifications {
+		if err := encoder.Encode(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseSince accepts a duration such as 24h or 7d, a date, or an RFC 3339
+// time, and returns the time it points at
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.Add(-time.Duration(n) * 24 * time.Hour), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid --since %q, expected a duration such as 24h or 7d, a date such as 2024-06-01, or an RFC 3339 time", value)
+}
+
+func markerPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".fc-cast-notifications-seen"), nil
+}
+
+func readMarker() (time.Time, bool) {
+	path, err := markerPath()
+	if err != nil {
+		return time.Time{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
+// LastSeen returns the time of the newest notification already shown, or
+// a week before now when there is none
+func LastSeen(now time.Time) time.Time {
+	if seen, ok := readMarker(); ok {
+		return seen
+	}
+	return now.Add(-defaultWindow)
+}
+
+// MarkSeen moves the last seen marker forward to the newest notification
+func MarkSeen(notifications []Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	newest := notifications[0].Timestamp
+	for _, n := range notifications {
+		if n.Timestamp.After(newest) {
+			newest = n.Timestamp
+		}
+	}
+	if seen, ok := readMarker(); ok && !newest.After(seen) {
+		return nil
+	}
+
+	path, err := markerPath()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strconv.FormatInt(newest.Unix(), 10)), 0600)
+}
@@ -0,0 +1,179 @@
+package notifications
+
+import (
+	"bytes"
+	"mast/devhub"
+	"mast/feed"
+	"mast/hub"
+	"mast/message"
+	"mast/protobufs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+const testPrivateKey = "0707070707070707070707070707070707070707070707070707070707070707"
+
+func startHub(t *testing.T) string {
+	t.Helper()
+	handler, err := devhub.NewHandler(devhub.Options{Network: protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	t.Setenv("HOME", t.TempDir())
+	if err := hub.SaveHubPreference(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	return server.URL
+}
+
+func submit(t *testing.T, hubURL string, msgData *protobufs.MessageData) *protobufs.Message {
+	t.Helper()
+	msgData.Network = protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET
+	msg, err := message.Sign(msgData, testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgBytes, _ := proto.Marshal(msg)
+	resp, err := http.Post(hubURL+"/v1/submitMessage", "application/octet-stream", bytes.NewReader(msgBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("submit returned %d", resp.StatusCode)
+	}
+	return msg
+}
+
+func cast(fid uint64, ts uint32, body *protobufs.CastAddBody) *protobufs.MessageData {
+	return &protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_CAST_ADD,
+		Fid:       fid,
+		Timestamp: ts,
+		Body:      &protobufs.MessageData_CastAddBody{CastAddBody: body},
+	}
+}
+
+func reaction(fid uint64, ts uint32, reactionType protobufs.ReactionType, targetFid uint64, target *protobufs.Message) *protobufs.MessageData {
+	return &protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_REACTION_ADD,
+		Fid:       fid,
+		Timestamp: ts,
+		Body: &protobufs.MessageData_ReactionBody{ReactionBody: &protobufs.ReactionBody{
+			Type:   reactionType,
+			Target: &protobufs.ReactionBody_TargetCastId{TargetCastId: &protobufs.CastId{Fid: targetFid, Hash: target.Hash}},
+		}},
+	}
+}
+
+func TestCollectMergesMentionsRepliesAndReactions(t *testing.T) {
+	hubURL := startHub(t)
+	now := message.Timestamp(time.Now())
+	const me = 6596
+
+	submit(t, hubURL, &protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_USER_DATA_ADD,
+		Fid:       3,
+		Timestamp: now - 1000,
+		Body: &protobufs.MessageData_UserDataBody{UserDataBody: &protobufs.UserDataBody{
+			Type:  protobufs.UserDataType_USER_DATA_TYPE_USERNAME,
+			Value: "dwr",
+		}},
+	})
+
+	mine := submit(t, hubURL, cast(me, now-500, &protobufs.CastAddBody{Text: "my cast"}))
+	submit(t, hubURL, cast(3, now-7200, &protobufs.CastAddBody{Text: "old  mention", Mentions: []uint64{me}, MentionsPositions: []uint32{4}}))
+	submit(t, hubURL, cast(3, now-400, &protobufs.CastAddBody{Text: "hi ", Mentions: []uint64{me}, MentionsPositions: []uint32{3}}))
+	submit(t, hubURL, cast(3, now-300, &protobufs.CastAddBody{
+		Text:   "nice",
+		Parent: &protobufs.CastAddBody_ParentCastId{ParentCastId: &protobufs.CastId{Fid: me, Hash: mine.Hash}},
+	}))
+	submit(t, hubURL, cast(me, now-250, &protobufs.CastAddBody{
+		Text:   "thanks",
+		Parent: &protobufs.CastAddBody_ParentCastId{ParentCastId: &protobufs.CastId{Fid: me, Hash: mine.Hash}},
+	}))
+	submit(t, hubURL, reaction(3, now-200, protobufs.ReactionType_REACTION_TYPE_LIKE, me, mine))
+	submit(t, hubURL, reaction(4, now-100, protobufs.ReactionType_REACTION_TYPE_RECAST, me, mine))
+	submit(t, hubURL, reaction(me, now-50, protobufs.ReactionType_REACTION_TYPE_LIKE, me, mine))
+
+	since := message.FromTimestamp(now - 3600)
+	got, err := Collect(me, since, 10, feed.NewUsernames())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"fid:4 recast your cast",
+		"@dwr liked your cast",
+		"@dwr replied to your cast",
+		"@dwr mentioned you",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d notifications: %v", len(got), got)
+	}
+	for i, n := range got {
+		if n.Summary() != want[i] {
+			t.Errorf("notification %d = %q, want %q", i, n.Summary(), want[i])
+		}
+	}
+	if got[2].Text != "nice" || got[2].TargetText != "my cast" {
+		t.Errorf("reply = %+v", got[2])
+	}
+	if got[3].Text != "hi fid:6596" {
+		t.Errorf("mention text = %q", got[3].Text)
+	}
+
+	var out bytes.Buffer
+	if err := WriteJSONL(&out, got); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], `{"type":"recast","fid":4,`) {
+		t.Errorf("JSONL output:\n%s", out.String())
+	}
+}
+
+func TestMarkSeenOnlyMovesForward(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	now := time.Unix(1700000000, 0)
+
+	if got := LastSeen(now); !got.Equal(now.Add(-defaultWindow)) {
+		t.Errorf("LastSeen without a marker = %v", got)
+	}
+	if err := MarkSeen([]Notification{{Timestamp: now.Add(-time.Hour)}, {Timestamp: now}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := MarkSeen([]Notification{{Timestamp: now.Add(-2 * time.Hour)}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := LastSeen(now); !got.Equal(now) {
+		t.Errorf("LastSeen = %v, want %v", got, now)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Time{
+		"90m":                  now.Add(-90 * time.Minute),
+		"2d":                   now.Add(-48 * time.Hour),
+		"2024-06-01T08:00:00Z": time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC),
+		"2024-06-01":           time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local),
+	}
+	for value, want := range cases {
+		got, err := ParseSince(value, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("ParseSince(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+	if _, err := ParseSince("yesterday", now); err == nil {
+		t.Error("ParseSince accepted yesterday")
+	}
+}
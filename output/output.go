@@ -0,0 +1,43 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Print writes v to stdout as one line of JSON
+func Print(v interface{}) error {
+	return Fprint(os.Stdout, v)
+}
+
+// Fprint writes v to w as one line of JSON
+func Fprint(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("Failed to encode output: %v", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// PrintError writes err to stdout as a JSON error with its code, so
+// scripts read results and errors from the same stream
+func PrintError(err error) {
+	Print(NewErrorResult(err))
+}
+
+// PrintSetting prints text, or the setting as {"<name>": value} under
+// --output json, with "saved": true when the command changed it
+func PrintSetting(name string, value interface{}, saved bool, text string) error {
+	if !IsJSON() {
+		fmt.Println(text)
+		return nil
+	}
+	result := map[string]interface{}{name: value}
+	if saved {
+		result["saved"] = true
+	}
+	return Print(result)
+}
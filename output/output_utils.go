@@ -0,0 +1,181 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"mast/auth"
+	"mast/channels"
+	"mast/hub"
+	"mast/message"
+	"mast/protobufs"
+	"net/http"
+	"time"
+)
+
+const (
+	// Text is the default output, styled for people
+	Text = "text"
+	// JSON prints one JSON object per line for every result or error
+	JSON = "json"
+)
+
+// Error codes printed with --output json. They are part of mast's
+// interface, so existing codes must not change meaning.
+const (
+	CodeError           = "error"
+	CodeInvalidInput    = "invalid_input"
+	CodeNotAuthorized   = "not_authorized"
+	CodeNetworkMismatch = "network_mismatch"
+	CodeNotMember       = "not_channel_member"
+	CodeInteractive     = "interactive_required"
+	CodeRateLimited     = "rate_limited"
+	CodeDuplicate       = "duplicate"
+	CodeHubAuth         = "hub_auth_failed"
+	CodeHubPayment      = "hub_payment_required"
+	CodeHubRejected     = "hub_rejected"
+	CodeHubUnavailable  = "hub_unavailable"
+	CodeSubmitFailed    = "submit_failed"
+)
+
+// warpcastURL is where a cast can be opened by its hash alone
+const warpcastURL = "https://warpcast.com/~/conversations/"
+
+var format = Text
+
+// SetFormat chooses how results and errors are printed
+func SetFormat(f string) error {
+	if f != Text && f != JSON {
+		return Errorf(CodeInvalidInput, "Unsupported output %q, expected text or json", f)
+	}
+	format = f
+	return nil
+}
+
+// IsJSON reports whether --output json was given, in which case commands
+// print JSON and never open a TUI
+func IsJSON() bool {
+	return format == JSON
+}
+
+// Error attaches a stable code to an error
+type Error struct {
+	Code string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errorf returns an error printed with code under --output json
+func Errorf(code string, format string, args ...interface{}) error {
+	return &Error{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// Interactive is returned by commands that need a terminal to run when
+// JSON output is on
+func Interactive(what string) error {
+	return Errorf(CodeInteractive, "%s needs the interactive TUI, which --output json turns off", what)
+}
+
+// Code classifies err into one of the error codes
+func Code(err error) string {
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	var credentials *auth.CredentialsError
+	if errors.As(err, &credentials) {
+		return CodeNotAuthorized
+	}
+	var mismatch *hub.NetworkMismatchError
+	if errors.As(err, &mismatch) {
+		return CodeNetworkMismatch
+	}
+	var membership *channels.MembershipError
+	if errors.As(err, &membership) {
+		return CodeNotMember
+	}
+	var hubErr *hub.HubError
+	if errors.As(err, &hubErr) {
+		switch {
+		case hubErr.RateLimited():
+			return CodeRateLimited
+		case hubErr.Duplicate():
+			return CodeDuplicate
+		case hubErr.StatusCode == http.StatusUnauthorized || hubErr.StatusCode == http.StatusForbidden:
+			return CodeHubAuth
+		case hubErr.StatusCode == http.StatusPaymentRequired:
+			return CodeHubPayment
+		case hubErr.StatusCode >= 500:
+			return CodeHubUnavailable
+		default:
+			return CodeHubRejected
+		}
+	}
+	return CodeError
+}
+
+// ErrorResult is what an error prints as under --output json
+type ErrorResult struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// RetryAfter is in seconds, set when a rate limited hub said how long
+	// to wait
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+// NewErrorResult describes err with its code
+func NewErrorResult(err error) ErrorResult {
+	body := ErrorBody{Code: Code(err), Message: err.Error()}
+	var hubErr *hub.HubError
+	if errors.As(err, &hubErr) {
+		body.RetryAfter = int(hubErr.RetryAfter / time.Second)
+	}
+	return ErrorResult{Error: body}
+}
+
+// CastResult is printed for every cast or message a command sends or signs
+type CastResult struct {
+	Hash      string `json:"hash"`
+	Fid       uint64 `json:"fid"`
+	Timestamp string `json:"timestamp"`
+	Network   string `json:"network"`
+	Hub       string `json:"hub,omitempty"`
+	URL       string `json:"url"`
+	// Status is the submit status for messages sent from a file
+	Status string `json:"status,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Text   string `json:"text,omitempty"`
+	// DryRun results carry the signed message instead of being sent
+	DryRun  bool   `json:"dry_run,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// NewCastResult describes the message built from msgData with hash, and the
+// preferred hub it is sent to
+func NewCastResult(msgData *protobufs.MessageData, hash string) CastResult {
+	hubURL, _, _ := hub.RetrieveHubPreference()
+	result := CastResult{
+		Hash:    hash,
+		Fid:     msgData.GetFid(),
+		Network: hub.NetworkName(msgData.GetNetwork()),
+		Hub:     hubURL,
+		URL:     warpcastURL + hash,
+	}
+	if msgData.GetTimestamp() != 0 {
+		result.Timestamp = message.FromTimestamp(msgData.GetTimestamp()).UTC().Format(time.RFC3339)
+	}
+	if body := msgData.GetCastAddBody(); body != nil {
+		result.Text = body.Text
+	}
+	return result
+}
@@ -0,0 +1,84 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mast/auth"
+	"mast/channels"
+	"mast/hub"
+	"mast/message"
+	"mast/protobufs"
+	"testing"
+	"time"
+)
+
+func TestCode(t *testing.T) {
+	for want, err := range map[string]error{
+		CodeInteractive:     Interactive("mast feed"),
+		CodeNotAuthorized:   fmt.Errorf("Problem retrieving credentials: %w", &auth.CredentialsError{Missing: "FID"}),
+		CodeNetworkMismatch: &hub.NetworkMismatchError{},
+		CodeNotMember:       &channels.MembershipError{ChannelID: "dev", Fid: 3},
+		CodeRateLimited:     &hub.HubError{StatusCode: 429},
+		CodeDuplicate:       &hub.HubError{StatusCode: 400, ErrCode: "bad_request.duplicate"},
+		CodeHubAuth:         &hub.HubError{StatusCode: 401},
+		CodeHubPayment:      &hub.HubError{StatusCode: 402},
+		CodeHubRejected:     &hub.HubError{StatusCode: 400, Reason: "invalid signer"},
+		CodeHubUnavailable:  &hub.HubError{StatusCode: 503},
+		CodeError:           fmt.Errorf("something else"),
+	} {
+		if got := Code(err); got != want {
+			t.Errorf("Code(%v) = %s, want %s", err, got, want)
+		}
+	}
+}
+
+func TestErrorResult(t *testing.T) {
+	var b bytes.Buffer
+	err := fmt.Errorf("Failed: %w", &hub.HubError{StatusCode: 429, RetryAfter: 30 * time.Second})
+	if err := Fprint(&b, NewErrorResult(err)); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"error":{"code":"rate_limited","message":"Failed: Rate limited (429). Please try again later.","retry_after":30}}` + "\n"
+	if b.String() != want {
+		t.Errorf("error line = %s", b.String())
+	}
+}
+
+func TestNewCastResult(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	sent := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	result := NewCastResult(&protobufs.MessageData{
+		Fid:       6596,
+		Timestamp: message.Timestamp(sent),
+		Network:   protobufs.FarcasterNetwork_FARCASTER_NETWORK_MAINNET,
+		Body:      &protobufs.MessageData_CastAddBody{CastAddBody: &protobufs.CastAddBody{Text: "gm"}},
+	}, "0xabc")
+
+	data, _ := json.Marshal(result)
+	var fields map[string]interface{}
+	json.Unmarshal(data, &fields)
+	for key, want := range map[string]interface{}{
+		"hash":      "0xabc",
+		"fid":       float64(6596),
+		"timestamp": "2024-06-01T12:00:00Z",
+		"network":   "mainnet",
+		"hub":       "https://hub-api.neynar.com",
+		"url":       "https://warpcast.com/~/conversations/0xabc",
+		"text":      "gm",
+	} {
+		if fields[key] != want {
+			t.Errorf("%s = %v, want %v", key, fields[key], want)
+		}
+	}
+}
+
+func TestSetFormat(t *testing.T) {
+	defer SetFormat(Text)
+	if err := SetFormat("yaml"); err == nil || Code(err) != CodeInvalidInput {
+		t.Errorf("SetFormat(yaml) = %v", err)
+	}
+	if err := SetFormat(JSON); err != nil || !IsJSON() {
+		t.Errorf("SetFormat(json) = %v", err)
+	}
+}
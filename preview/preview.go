@@ -0,0 +1,63 @@
+package preview
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	titleStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#7C65C1"))
+	mutedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#767676"))
+	failStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6B6B"))
+)
+
+// Render lays out a fetched preview in lines of at most width characters,
+// starting with what went wrong when err is set
+func Render(p Preview, err error, width int) []string {
+	var lines []string
+	if err != nil {
+		lines = append(lines, failStyle.Render(truncate("✗ "+err.Error(), width)))
+	}
+	if p.Redirected() {
+		lines = append(lines, failStyle.Render(truncate("↪ redirects to "+p.FinalURL, width)))
+	}
+	if err != nil {
+		return lines
+	}
+
+	if p.Title != "" {
+		title := p.Title
+		if p.Frame {
+			title += " · frame"
+		}
+		lines = append(lines, titleStyle.Render(truncate(title, width)))
+	} else if p.Frame {
+		lines = append(lines, titleStyle.Render("frame"))
+	}
+	if p.Description != "" {
+		lines = append(lines, mutedStyle.Render(truncate(p.Description, width)))
+	}
+	if p.Image != "" {
+		lines = append(lines, mutedStyle.Render(truncate("image "+p.Image, width)))
+	}
+	if len(lines) == 0 {
+		note := "No OpenGraph tags on the page"
+		if p.ContentType != "" && p.ContentType != "text/html" {
+			note = fmt.Sprintf("No preview for %s", p.ContentType)
+		}
+		lines = append(lines, mutedStyle.Render(note))
+	}
+	return lines
+}
+
+func truncate(s string, width int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if width <= 1 || utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,151 @@
+package preview
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// maxPageBytes is how much of a page is read looking for meta tags
+	maxPageBytes = 512 << 10
+	// fetchTimeout bounds a preview so a slow site doesn't hold up the TUI
+	fetchTimeout = 10 * time.Second
+)
+
+// Preview is what a cast client is likely to show for an embedded URL
+type Preview struct {
+	URL string
+	// FinalURL is where the URL ended up after redirects
+	FinalURL    string
+	Status      int
+	ContentType string
+	Title       string
+	Description string
+	Image       string
+	SiteName    string
+	// Frame is set when the page declares a Farcaster frame or mini app
+	Frame bool
+}
+
+// Redirected reports whether fetching the URL was redirected elsewhere
+func (p Preview) Redirected() bool {
+	return p.FinalURL != "" && p.FinalURL != p.URL
+}
+
+var (
+	headEndPattern = regexp.MustCompile(`(?i)</head\s*>`)
+	metaPattern    = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attrPattern    = regexp.MustCompile(`(?s)([a-zA-Z_:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	titlePattern   = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title\s*>`)
+)
+
+// Meta returns the content of each <meta> tag in the page's head, keyed by
+// its lowercased property or name. The first tag wins when one repeats.
+func Meta(page string) map[string]string {
+	if loc := headEndPattern.FindStringIndex(page); loc != nil {
+		page = page[:loc[0]]
+	}
+	meta := map[string]string{}
+	for _, tag := range metaPattern.FindAllString(page, -1) {
+		attrs := map[string]string{}
+		for _, m := range attrPattern.FindAllStringSubmatch(tag, -1) {
+			attrs[strings.ToLower(m[1])] = m[2] + m[3] + m[4]
+		}
+		key := attrs["property"]
+		if key == "" {
+			key = attrs["name"]
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" {
+			continue
+		}
+		if _, ok := meta[key]; !ok {
+			meta[key] = strings.TrimSpace(html.UnescapeString(attrs["content"]))
+		}
+	}
+	if m := titlePattern.FindStringSubmatch(page); m != nil {
+		meta["title"] = strings.Join(strings.Fields(html.UnescapeString(m[1])), " ")
+	}
+	return meta
+}
+
+// Parse fills a preview from the OpenGraph, Twitter card and fc:frame tags
+// of page, resolving a relative image against base
+func Parse(page string, base *url.URL) Preview {
+	meta := Meta(page)
+	p := Preview{
+		Title:       first(meta, "og:title", "twitter:title", "title"),
+		Description: first(meta, "og:description", "twitter:description", "description"),
+		Image:       first(meta, "og:image", "og:image:url", "twitter:image", "twitter:image:src", "fc:frame:image"),
+		SiteName:    first(meta, "og:site_name"),
+	}
+	for _, key := range []string{"fc:frame", "fc:miniapp", "fc:frame:image"} {
+		if meta[key] != "" {
+			p.Frame = true
+		}
+	}
+	if p.Image != "" && base != nil {
+		if ref, err := url.Parse(p.Image); err == nil {
+			p.Image = base.ResolveReference(ref).String()
+		}
+	}
+	return p
+}
+
+func first(meta map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if meta[key] != "" {
+			return meta[key]
+		}
+	}
+	return ""
+}
+
+// Fetch downloads rawURL and builds its preview. Responses that aren't
+// successful still return the preview with their status alongside the
+// error.
+func Fetch(rawURL string) (Preview, error) {
+	p := Preview{URL: rawURL}
+	client := &http.Client{Timeout: fetchTimeout}
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return p, fmt.Errorf("Failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", "mast-preview")
+	req.Header.Set("Accept", "text/html, application/xhtml+xml, */*;q=0.5")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return p, fmt.Errorf("Failed to fetch %s: %v", rawURL, err)
+	}
+	defer resp.Body.Close()
+	p.Status = resp.StatusCode
+	p.FinalURL = resp.Request.URL.String()
+	p.ContentType, _, _ = mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode >= 400 {
+		return p, fmt.Errorf("%s returned %s", rawURL, resp.Status)
+	}
+
+	switch {
+	case strings.HasPrefix(p.ContentType, "image/"):
+		p.Image = p.FinalURL
+		return p, nil
+	case p.ContentType != "" && p.ContentType != "text/html" && p.ContentType != "application/xhtml+xml":
+		return p, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPageBytes))
+	if err != nil {
+		return p, fmt.Errorf("Failed to read %s: %v", rawURL, err)
+	}
+	parsed := Parse(string(data), resp.Request.URL)
+	parsed.URL, parsed.FinalURL, parsed.Status, parsed.ContentType = p.URL, p.FinalURL, p.Status, p.ContentType
+	return parsed, nil
+}
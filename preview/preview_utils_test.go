@@ -0,0 +1,77 @@
+package preview
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+const page = `<!doctype html>
+<html><head>
+<title>
+  Fallback   title
+</title>
+<meta property="og:title" content="Mast &amp; friends">
+<meta name='twitter:title' content='Twitter title'>
+<meta name="twitter:description" content="A CLI for Farcaster">
+<meta content="/images/card.png" property="og:image" />
+<meta name="fc:frame" content="vNext">
+</head>
+<body><meta property="og:description" content="from the body"></body>
+</html>`
+
+func TestParse(t *testing.T) {
+	base, _ := url.Parse("https://example.com/posts/1")
+	p := Parse(page, base)
+	want := Preview{
+		Title:       "Mast & friends",
+		Description: "A CLI for Farcaster",
+		Image:       "https://example.com/images/card.png",
+		Frame:       true,
+	}
+	if p != want {
+		t.Errorf("Parse = %+v", p)
+	}
+
+	if p := Parse("<html><head><title>Just a title</title></head></html>", base); p.Title != "Just a title" || p.Frame {
+		t.Errorf("title only = %+v", p)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/logo.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte{0x89, 'P', 'N', 'G'})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	p, err := Fetch(server.URL + "/page")
+	if err != nil || p.Title != "Mast & friends" || p.Image != server.URL+"/images/card.png" || p.Redirected() {
+		t.Errorf("page = %+v, %v", p, err)
+	}
+
+	p, err = Fetch(server.URL + "/old")
+	if err != nil || !p.Redirected() || p.FinalURL != server.URL+"/page" || p.Title == "" {
+		t.Errorf("redirect = %+v, %v", p, err)
+	}
+
+	p, err = Fetch(server.URL + "/logo.png")
+	if err != nil || p.Image != server.URL+"/logo.png" {
+		t.Errorf("image = %+v, %v", p, err)
+	}
+
+	p, err = Fetch(server.URL + "/missing")
+	if err == nil || p.Status != http.StatusNotFound {
+		t.Errorf("missing = %+v, %v", p, err)
+	}
+}
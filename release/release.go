@@ -0,0 +1,126 @@
+package release
+
+import (
+	"fmt"
+	"mast/auth"
+	"mast/compose"
+	"mast/output"
+	"mast/protobufs"
+	"mast/thread"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	okStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#7C65C1"))
+	mutedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#767676"))
+)
+
+// Options controls which release is announced and where
+type Options struct {
+	// Dir is any directory inside the repository
+	Dir string
+	// Tag defaults to the latest tag reachable from HEAD
+	Tag     string
+	Channel string
+	// URL overrides the repository URL taken from the origin remote
+	URL     string
+	DryRun  bool
+	Network protobufs.FarcasterNetwork
+	Force   bool
+}
+
+// Announce drafts a cast, or a thread when the notes don't fit in one, for
+// the release and opens each part in the compose TUI for review before
+// sending it
+func Announce(opts Options) error {
+	r, err := Load(opts.Dir, opts.Tag)
+	if err != nil {
+		return err
+	}
+	if opts.URL != "" {
+		r.URL = opts.URL
+	}
+	parts := Draft(r)
+
+	source := "the changelog"
+	if !r.FromChangelog {
+		source = "commits since " + r.Previous
+		if r.Previous == "" {
+			source = "all commits"
+		}
+	}
+
+	if opts.DryRun && output.IsJSON() {
+		for i, part := range parts {
+			draft := draftCast{DryRun: true, Tag: r.Tag, Part: i + 1, Parts: len(parts), Text: part}
+			if i == 0 {
+				draft.Embed = r.URL
+			}
+			if err := output.Print(draft); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if output.IsJSON() {
+		return output.Errorf(output.CodeInteractive, "mast release opens every cast for review, which --output json turns off, pass --dry-run to print the drafts")
+	}
+	if opts.DryRun {
+		fmt.Println(mutedStyle.Render(fmt.Sprintf("%s from %s, %d casts", r.Tag, source, len(parts))))
+		for i, part := range parts {
+			fmt.Println(okStyle.Render(fmt.Sprintf("\nCast %d of %d", i+1, len(parts))))
+			fmt.Println(part)
+			if i == 0 && r.URL != "" {
+				fmt.Println(mutedStyle.Render("  embed " + r.URL))
+			}
+		}
+		return nil
+	}
+
+	fid, _, err := auth.FindFidAndPrivateKey()
+	if err != nil {
+		return fmt.Errorf("Problem retrieving credentials, run mast auth to authorize the CLI: %w", err)
+	}
+
+	var parent *protobufs.CastId
+	for i, part := range parts {
+		draft := compose.CastData{Message: part, ParentCast: parent}
+		if parent == nil {
+			draft.Embeds = []compose.Embed{{Kind: compose.EmbedURL, URL: r.URL}}
+			draft.Channel = opts.Channel
+		}
+		heading := fmt.Sprintf("Announcing %s from %s · cast %d of %d", r.Tag, source, i+1, len(parts))
+
+		castData, err := compose.ComposeDraft(draft, heading)
+		if err != nil {
+			if i > 0 {
+				return fmt.Errorf("%v, %d of %d casts were sent", err, i, len(parts))
+			}
+			return err
+		}
+
+		hash, err := compose.Publish(castData, compose.SendOptions{Network: opts.Network, Force: opts.Force})
+		if err != nil {
+			return fmt.Errorf("Failed to send cast %d of %d: %v", i+1, len(parts), err)
+		}
+		fmt.Println(okStyle.Render(fmt.Sprintf("Cast %d of %d sent: %s", i+1, len(parts), hash)))
+
+		parentHash, err := thread.ParseHash(hash)
+		if err != nil {
+			return err
+		}
+		parent = &protobufs.CastId{Fid: fid, Hash: parentHash}
+	}
+	return nil
+}
+
+// draftCast is what --dry-run prints for each cast under --output json
+type draftCast struct {
+	DryRun bool   `json:"dry_run"`
+	Tag    string `json:"tag"`
+	Part   int    `json:"part"`
+	Parts  int    `json:"parts"`
+	Text   string `json:"text"`
+	Embed  string `json:"embed,omitempty"`
+}
@@ -0,0 +1,205 @@
+package release
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxCastBytes is the longest text hubs accept in a cast
+const maxCastBytes = 320
+
+// Release is what an announcement is drafted from, read from the local
+// repository only
+type Release struct {
+	Name     string
+	Tag      string
+	Previous string
+	URL      string
+	// Notes are the changelog lines for Tag, or the commit subjects since
+	// Previous when the changelog has no section for it
+	Notes         []string
+	FromChangelog bool
+}
+
+// git runs a git command in dir and returns its trimmed output
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Load reads the release for tag from the repository at dir, or for the
+// latest tag reachable from HEAD when tag is empty
+func Load(dir string, tag string) (Release, error) {
+	root, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return Release{}, fmt.Errorf("Not inside a git repository: %v", err)
+	}
+
+	if tag == "" {
+		if tag, err = git(root, "describe", "--tags", "--abbrev=0"); err != nil {
+			return Release{}, fmt.Errorf("No tags found, tag a release first: %v", err)
+		}
+	} else if _, err := git(root, "rev-parse", "--verify", "--quiet", tag+"^{commit}"); err != nil {
+		return Release{}, fmt.Errorf("Tag %s not found", tag)
+	}
+
+	r := Release{Tag: tag, Name: filepath.Base(root)}
+	// A tag on the very first commit has no previous one, which is fine
+	r.Previous, _ = git(root, "describe", "--tags", "--abbrev=0", tag+"^")
+
+	if remote, err := git(root, "remote", "get-url", "origin"); err == nil {
+		r.URL = RepoURL(remote)
+		if r.URL != "" {
+			r.Name = path.Base(r.URL)
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(root, "CHANGELOG.md")); err == nil {
+		r.Notes = ChangelogSection(string(data), tag)
+		r.FromChangelog = len(r.Notes) > 0
+	}
+	if !r.FromChangelog {
+		if r.Notes, err = Commits(root, r.Previous, tag); err != nil {
+			return Release{}, err
+		}
+	}
+	return r, nil
+}
+
+// Commits returns the subjects of the non-merge commits in previous..tag,
+// oldest first. An empty previous means every commit up to tag.
+func Commits(dir string, previous string, tag string) ([]string, error) {
+	rangeArg := tag
+	if previous != "" {
+		rangeArg = previous + ".." + tag
+	}
+	out, err := git(dir, "log", "--no-merges", "--reverse", "--format=%s", rangeArg)
+	if err != nil {
+		return nil, err
+	}
+	var subjects []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			subjects = append(subjects, line)
+		}
+	}
+	return subjects, nil
+}
+
+var (
+	scpRemote   = regexp.MustCompile(`^[\w.-]+@([\w.-]+):(.+)$`)
+	headingLine = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	bulletLine  = regexp.MustCompile(`^\s*[-*+]\s+`)
+	mdLink      = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+)
+
+// RepoURL turns a git remote such as git@github.com:owner/repo.git into the
+// https URL of the repository, or "" for a local path. Credentials in the
+// remote are dropped.
+func RepoURL(remote string) string {
+	remote = strings.TrimSpace(remote)
+	if m := scpRemote.FindStringSubmatch(remote); m != nil {
+		remote = "ssh://" + m[1] + "/" + m[2]
+	}
+	u, err := url.Parse(remote)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	switch u.Scheme {
+	case "https", "http", "ssh", "git":
+	default:
+		return ""
+	}
+	return "https://" + u.Hostname() + strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), ".git")
+}
+
+// ChangelogSection returns the lines under the CHANGELOG.md heading for tag,
+// such as "## [1.2.0] - 2024-06-01" or "## v1.2.0", up to the next heading
+// of the same or a higher level. Bullets are kept as "- ", sub-headings
+// become "Added:" style lines and markdown links are reduced to their text.
+func ChangelogSection(changelog string, tag string) []string {
+	version := regexp.QuoteMeta(strings.TrimPrefix(tag, "v"))
+	match := regexp.MustCompile(`(^|[^\w.])v?` + version + `([^\w.]|$)`)
+
+	var notes []string
+	level := 0
+	scanner := bufio.NewScanner(strings.NewReader(changelog))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		if h := headingLine.FindStringSubmatch(line); h != nil {
+			if level > 0 && len(h[1]) <= level {
+				break
+			}
+			if level == 0 {
+				if match.MatchString(h[2]) {
+					level = len(h[1])
+				}
+				continue
+			}
+			notes = append(notes, mdLink.ReplaceAllString(h[2], "$1")+":")
+			continue
+		}
+		if level == 0 || strings.TrimSpace(line) == "" {
+			continue
+		}
+		if bulletLine.MatchString(line) {
+			line = "- " + bulletLine.ReplaceAllString(line, "")
+		} else {
+			line = strings.TrimSpace(line)
+		}
+		notes = append(notes, mdLink.ReplaceAllString(line, "$1"))
+	}
+	return notes
+}
+
+// Draft lays the release out as cast texts that each fit in a cast. The
+// first one names the release and the rest continue the notes as a thread.
+func Draft(r Release) []string {
+	header := fmt.Sprintf("%s %s is out", r.Name, r.Tag)
+	if len(r.Notes) == 0 {
+		return []string{header}
+	}
+
+	parts := []string{header + "\n"}
+	for _, note := range r.Notes {
+		if !strings.HasPrefix(note, "- ") && !strings.HasSuffix(note, ":") {
+			note = "- " + note
+		}
+		note = truncate(note, maxCastBytes)
+		last := len(parts) - 1
+		if len(parts[last])+1+len(note) > maxCastBytes {
+			parts = append(parts, note)
+			continue
+		}
+		parts[last] += "\n" + note
+	}
+	return parts
+}
+
+// truncate cuts s to at most max bytes on a rune boundary, ending in an
+// ellipsis when anything was cut
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	cut := max - len("…")
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return strings.TrimRight(s[:cut], " ") + "…"
+}
@@ -0,0 +1,101 @@
+package restore
+
+import (
+	"fmt"
+	"mast/hub"
+	"mast/message"
+	"mast/output"
+	"mast/submit"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	okStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#7C65C1"))
+	warnStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#767676"))
+	failStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6B6B"))
+)
+
+// Restore replays the signed messages in path to the preferred hub and
+// prints one line per message, or one JSON result under --output json
+func Restore(path string, format string, opts Options) error {
+	msgs, err := message.ReadFile(path, format)
+	if err != nil {
+		return err
+	}
+	if len(msgs) == 0 {
+		return fmt.Errorf("No messages found in %s", path)
+	}
+
+	hubURL, _, err := hub.RetrieveHubPreference()
+	if err != nil {
+		return err
+	}
+	info, err := hub.Info()
+	if err != nil {
+		return err
+	}
+	hubNetwork, _ := info.ReportedNetwork()
+
+	if opts.Checkpoint == "" {
+		opts.Checkpoint = DefaultCheckpoint(path)
+	}
+
+	started := false
+	progress, err := Run(msgs, hubURL, hubNetwork, opts, func(e Event) {
+		if !started {
+			started = true
+			if e.Index > 0 {
+				note(fmt.Sprintf("Resuming at message %d of %d from %s", e.Index+1, e.Total, opts.Checkpoint))
+			}
+		}
+
+		line := fmt.Sprintf("[%d/%d] %s %s", e.Index+1, e.Total, e.Result.Hash, e.Result.Status)
+		if e.Retry {
+			note(fmt.Sprintf("%s, retrying in %s", line, e.Wait.Round(time.Second)))
+			return
+		}
+		if output.IsJSON() {
+			output.Print(submit.NewCastResult(msgs[e.Index], e.Result))
+			return
+		}
+		if e.Result.Reason != "" {
+			line += ": " + e.Result.Reason
+		}
+		switch e.Result.Status {
+		case submit.StatusAccepted:
+			fmt.Println(okStyle.Render(line))
+		case submit.StatusDuplicate:
+			fmt.Println(warnStyle.Render(line))
+		default:
+			fmt.Println(failStyle.Render(line))
+		}
+	})
+	if err == nil && !started {
+		note(fmt.Sprintf("All %d messages were already restored to %s, pass --restart to replay them again", len(msgs), hubURL))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	note(fmt.Sprintf("%d accepted, %d duplicate, %d rejected, %d invalid. Progress is saved in %s",
+		progress.Counts[submit.StatusAccepted], progress.Counts[submit.StatusDuplicate],
+		progress.Counts[submit.StatusRejected], progress.Counts[submit.StatusInvalid], opts.Checkpoint))
+	if failed := len(progress.Failed); failed > 0 {
+		return output.Errorf(output.CodeSubmitFailed, "%d of %d messages were not restored", failed, len(msgs))
+	}
+	return nil
+}
+
+// note prints progress that isn't a message result, on stderr under
+// --output json so stdout only carries results
+func note(line string) {
+	if output.IsJSON() {
+		fmt.Fprintln(os.Stderr, line)
+		return
+	}
+	fmt.Println(warnStyle.Render(line))
+}
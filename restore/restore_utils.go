@@ -0,0 +1,169 @@
+package restore
+
+import (
+	"encoding/json"
+	"fmt"
+	"mast/protobufs"
+	"mast/submit"
+	"os"
+	"time"
+)
+
+const (
+	defaultRate = 5
+	maxBackoff  = time.Minute
+)
+
+// sleep is replaced in tests
+var sleep = time.Sleep
+
+// Options controls how fast messages are replayed and where progress is
+// kept
+type Options struct {
+	// Rate is the most messages submitted per second
+	Rate float64
+	// Retries is how many times a rate limited message is tried again
+	// before the restore stops
+	Retries int
+	// Checkpoint is the file progress is saved to after every message
+	Checkpoint string
+	// Restart ignores the checkpoint and starts from the first message
+	Restart bool
+}
+
+// Checkpoint records, per hub, how far a restore got and what happened to
+// the messages that were not accepted
+type Checkpoint struct {
+	Hubs map[string]*Progress `json:"hubs"`
+}
+
+type Progress struct {
+	// Next is the index of the next message to submit
+	Next   int            `json:"next"`
+	Counts map[string]int `json:"counts"`
+	Failed []Failure      `json:"failed,omitempty"`
+}
+
+type Failure struct {
+	Hash   string `json:"hash"`
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// DefaultCheckpoint is the checkpoint file kept next to an archive
+func DefaultCheckpoint(path string) string {
+	return path + ".restore.json"
+}
+
+func loadCheckpoint(path string) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{Hubs: map[string]*Progress{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, fmt.Errorf("Failed to read checkpoint %s: %v", path, err)
+	}
+	if checkpoint.Hubs == nil {
+		checkpoint.Hubs = map[string]*Progress{}
+	}
+	return checkpoint, nil
+}
+
+func (c *Checkpoint) save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Event is reported once per message, with Index counting from zero
+type Event struct {
+	Index  int
+	Total  int
+	Result submit.Result
+	// Retry is set when the message was rate limited and will be tried
+	// again after Wait
+	Retry bool
+	Wait  time.Duration
+}
+
+// Run submits msgs to hubURL in order, starting after the last message the
+// checkpoint recorded for that hub. It waits between messages to stay under
+// opts.Rate and backs off when the hub rate limits, stopping with an error
+// once a message has been retried opts.Retries times. The checkpoint is
+// saved after every message so the next run resumes from the same place.
+func Run(msgs []*protobufs.Message, hubURL string, hubNetwork protobufs.FarcasterNetwork, opts Options, report func(Event)) (*Progress, error) {
+	checkpoint, err := loadCheckpoint(opts.Checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	progress := checkpoint.Hubs[hubURL]
+	if progress == nil || opts.Restart {
+		progress = &Progress{}
+		checkpoint.Hubs[hubURL] = progress
+	}
+	if progress.Counts == nil {
+		progress.Counts = map[string]int{}
+	}
+
+	rate := opts.Rate
+	if rate <= 0 {
+		rate = defaultRate
+	}
+	interval := time.Duration(float64(time.Second) / rate)
+
+	var last time.Time
+	for progress.Next < len(msgs) {
+		if wait := interval - time.Since(last); !last.IsZero() && wait > 0 {
+			sleep(wait)
+		}
+
+		var result submit.Result
+		for attempt := 0; ; attempt++ {
+			last = time.Now()
+			result = submit.Message(msgs[progress.Next], hubNetwork)
+			if result.Status != submit.StatusRateLimited {
+				break
+			}
+			if attempt >= opts.Retries {
+				report(Event{Index: progress.Next, Total: len(msgs), Result: result})
+				return progress, fmt.Errorf("Stopped at message %d of %d, the hub is still rate limiting. Run the same command later to resume", progress.Next+1, len(msgs))
+			}
+			wait := backoff(attempt, result.RetryAfter)
+			report(Event{Index: progress.Next, Total: len(msgs), Result: result, Retry: true, Wait: wait})
+			sleep(wait)
+		}
+
+		progress.Counts[result.Status]++
+		if result.Status != submit.StatusAccepted && result.Status != submit.StatusDuplicate {
+			progress.Failed = append(progress.Failed, Failure{Hash: result.Hash, Status: result.Status, Reason: result.Reason})
+		}
+		progress.Next++
+		if err := checkpoint.save(opts.Checkpoint); err != nil {
+			return progress, err
+		}
+		report(Event{Index: progress.Next - 1, Total: len(msgs), Result: result})
+	}
+	return progress, nil
+}
+
+// backoff is the hub's Retry-After when it sent one, otherwise a doubling
+// wait starting at one second
+func backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	wait := time.Second << attempt
+	if wait > maxBackoff || wait <= 0 {
+		wait = maxBackoff
+	}
+	return wait
+}
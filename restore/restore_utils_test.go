@@ -0,0 +1,149 @@
+package restore
+
+import (
+	"mast/devhub"
+	"mast/hub"
+	"mast/message"
+	"mast/protobufs"
+	"mast/submit"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testPrivateKey = "0707070707070707070707070707070707070707070707070707070707070707"
+
+// startHub runs a mock hub that answers the first limited submits with 429
+func startHub(t *testing.T, limited int32) string {
+	t.Helper()
+	handler, err := devhub.NewHandler(devhub.Options{Network: protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var submits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/submitMessage" && atomic.AddInt32(&submits, 1) <= limited {
+			w.Header().Set("Retry-After", "3")
+			http.Error(w, `{"errCode":"unavailable.rate_limited","details":"too many requests"}`, http.StatusTooManyRequests)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("HOME", t.TempDir())
+	if err := hub.SaveHubPreference(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	return server.URL
+}
+
+func signedCasts(t *testing.T, texts ...string) []*protobufs.Message {
+	t.Helper()
+	now := message.Timestamp(time.Now())
+	var msgs []*protobufs.Message
+	for i, text := range texts {
+		msg, err := message.Sign(&protobufs.MessageData{
+			Type:      protobufs.MessageType_MESSAGE_TYPE_CAST_ADD,
+			Fid:       6596,
+			Timestamp: now + uint32(i),
+			Network:   protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET,
+			Body:      &protobufs.MessageData_CastAddBody{CastAddBody: &protobufs.CastAddBody{Text: text}},
+		}, testPrivateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func noSleep(t *testing.T) *[]time.Duration {
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	t.Cleanup(func() { sleep = time.Sleep })
+	return &waits
+}
+
+func TestRunReportsOutcomesAndResumes(t *testing.T) {
+	hubURL := startHub(t, 0)
+	noSleep(t)
+	msgs := signedCasts(t, "one", "two", "three")
+	msgs[1].Signature[0] ^= 0xff
+	opts := Options{Rate: 100, Checkpoint: filepath.Join(t.TempDir(), "checkpoint.json")}
+
+	var events []Event
+	progress, err := Run(msgs, hubURL, protobufs.FarcasterNetwork_FARCASTER_NETWORK_NONE, opts, func(e Event) { events = append(events, e) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 || events[0].Result.Status != submit.StatusAccepted || events[1].Result.Status != submit.StatusInvalid {
+		t.Fatalf("events = %+v", events)
+	}
+	if progress.Next != 3 || progress.Counts[submit.StatusAccepted] != 2 || len(progress.Failed) != 1 {
+		t.Errorf("progress = %+v", progress)
+	}
+
+	events = nil
+	if _, err := Run(msgs, hubURL, protobufs.FarcasterNetwork_FARCASTER_NETWORK_NONE, opts, func(e Event) { events = append(events, e) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("second run resubmitted %d messages", len(events))
+	}
+
+	opts.Restart = true
+	events = nil
+	if _, err := Run(msgs[:1], hubURL, protobufs.FarcasterNetwork_FARCASTER_NETWORK_NONE, opts, func(e Event) { events = append(events, e) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Result.Status != submit.StatusDuplicate {
+		t.Errorf("restart events = %+v", events)
+	}
+}
+
+func TestRunBacksOffWhenRateLimited(t *testing.T) {
+	hubURL := startHub(t, 3)
+	waits := noSleep(t)
+	msgs := signedCasts(t, "one", "two")
+	opts := Options{Rate: 100, Retries: 1, Checkpoint: filepath.Join(t.TempDir(), "checkpoint.json")}
+	report := func(Event) {}
+
+	progress, err := Run(msgs, hubURL, protobufs.FarcasterNetwork_FARCASTER_NETWORK_NONE, opts, report)
+	if err == nil {
+		t.Fatal("Run kept going while rate limited")
+	}
+	if progress.Next != 0 || len(*waits) != 1 || (*waits)[0] != 3*time.Second {
+		t.Errorf("next %d, waits %v", progress.Next, *waits)
+	}
+
+	opts.Retries = 5
+	progress, err = Run(msgs, hubURL, protobufs.FarcasterNetwork_FARCASTER_NETWORK_NONE, opts, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Next != 2 || progress.Counts[submit.StatusAccepted] != 2 {
+		t.Errorf("progress = %+v", progress)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cases := []struct {
+		attempt    int
+		retryAfter time.Duration
+		want       time.Duration
+	}{
+		{0, 0, time.Second},
+		{3, 0, 8 * time.Second},
+		{10, 0, maxBackoff},
+		{0, 30 * time.Second, 30 * time.Second},
+	}
+	for _, c := range cases {
+		if got := backoff(c.attempt, c.retryAfter); got != c.want {
+			t.Errorf("backoff(%d, %v) = %v, want %v", c.attempt, c.retryAfter, got, c.want)
+		}
+	}
+}
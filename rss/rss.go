@@ -0,0 +1,191 @@
+package rss
+
+import (
+	"fmt"
+	"mast/channels"
+	"mast/compose"
+	"mast/output"
+	"mast/protobufs"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	okStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#7C65C1"))
+	mutedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#767676"))
+	failStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6B6B"))
+)
+
+// Options controls which feeds are bridged and how their items are cast
+type Options struct {
+	Feeds    []string
+	Template string
+	// Channel is a channel id or parent URL to cast into
+	Channel  string
+	Once     bool
+	Interval time.Duration
+	// Max is the most casts per feed in one poll, the rest wait for the
+	// next one
+	Max     int
+	DryRun  bool
+	Network protobufs.FarcasterNetwork
+	Force   bool
+}
+
+// Run polls the feeds once, or forever every opts.Interval, casting every
+// item it hasn't seen before
+func Run(opts Options) error {
+	if len(opts.Feeds) == 0 {
+		return fmt.Errorf("At least one feed URL is required")
+	}
+	tmpl, err := ParseTemplate(opts.Template)
+	if err != nil {
+		return err
+	}
+	parentURL := ""
+	if opts.Channel != "" {
+		if parentURL, err = channels.ParentURL(opts.Channel, false); err != nil {
+			return err
+		}
+	}
+
+	for {
+		failed := Poll(opts, tmpl, parentURL)
+		if opts.Once || opts.DryRun {
+			if failed > 0 {
+				return fmt.Errorf("%d of %d feeds failed", failed, len(opts.Feeds))
+			}
+			return nil
+		}
+		note(fmt.Sprintf("Next poll at %s", time.Now().Add(opts.Interval).Format("15:04")))
+		time.Sleep(opts.Interval)
+	}
+}
+
+// Poll checks every feed once and returns how many of them failed. A feed
+// seen for the first time only has its items recorded, so adding a feed
+// doesn't cast its whole history.
+func Poll(opts Options, tmpl *template.Template, parentURL string) int {
+	seen, err := LoadSeen()
+	if err != nil {
+		fail(err)
+		return len(opts.Feeds)
+	}
+
+	failed := 0
+	for _, feed := range opts.Feeds {
+		if err := pollFeed(feed, seen, opts, tmpl, parentURL); err != nil {
+			failed++
+			fail(fmt.Errorf("%s: %v", feed, err))
+		}
+	}
+	return failed
+}
+
+func pollFeed(feed string, seen Seen, opts Options, tmpl *template.Template, parentURL string) error {
+	items, err := Fetch(feed)
+	if err != nil {
+		return err
+	}
+
+	if _, known := seen[feed]; !known {
+		if opts.DryRun {
+			note(fmt.Sprintf("%s is new, its %d current items would be marked as seen without casting", feed, len(items)))
+			return nil
+		}
+		seen[feed] = []string{}
+		for _, item := range items {
+			seen.Add(feed, item.GUID)
+		}
+		note(fmt.Sprintf("%s is new, marked its %d current items as seen. New items will be cast from now on", feed, len(items)))
+		return seen.Save()
+	}
+
+	posted := 0
+	for _, item := range items {
+		if seen.Has(feed, item.GUID) {
+			continue
+		}
+		if opts.Max > 0 && posted >= opts.Max {
+			note(fmt.Sprintf("%s: reached --max %d, the rest waits for the next poll", feed, opts.Max))
+			break
+		}
+
+		text, err := Render(tmpl, item)
+		if err != nil {
+			return err
+		}
+		castData := compose.CastData{Message: text, ParentURL: parentURL}
+		if item.Link != "" {
+			castData.Embeds = []compose.Embed{{Kind: compose.EmbedURL, URL: item.Link}}
+		}
+
+		if opts.DryRun && output.IsJSON() {
+			if err := output.Print(dryRunCast{DryRun: true, Feed: feed, Text: text, Link: item.Link, ParentURL: parentURL}); err != nil {
+				return err
+			}
+			posted++
+			continue
+		}
+		if opts.DryRun {
+			fmt.Println(okStyle.Render("Would cast: ") + text)
+			if item.Link != "" {
+				note("  embed " + item.Link)
+			}
+			if parentURL != "" {
+				note("  in " + parentURL)
+			}
+			posted++
+			continue
+		}
+
+		msgData, hash, err := compose.PublishMessage(castData, compose.SendOptions{Network: opts.Network, Force: opts.Force})
+		if err != nil {
+			return fmt.Errorf("Failed to cast %q: %v", item.Title, err)
+		}
+		seen.Add(feed, item.GUID)
+		if err := seen.Save(); err != nil {
+			return err
+		}
+		posted++
+		if output.IsJSON() {
+			if err := output.Print(output.NewCastResult(msgData, hash)); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Println(okStyle.Render(fmt.Sprintf("Cast %s", hash)) + mutedStyle.Render(" "+item.Title))
+	}
+	return nil
+}
+
+// dryRunCast is what --dry-run prints for an item under --output json
+type dryRunCast struct {
+	DryRun    bool   `json:"dry_run"`
+	Feed      string `json:"feed"`
+	Text      string `json:"text"`
+	Link      string `json:"link,omitempty"`
+	ParentURL string `json:"parent_url,omitempty"`
+}
+
+// note prints progress for people, on stderr under --output json so stdout
+// stays one JSON object per line
+func note(line string) {
+	if output.IsJSON() {
+		fmt.Fprintln(os.Stderr, line)
+		return
+	}
+	fmt.Println(mutedStyle.Render(line))
+}
+
+// fail reports a feed that failed without stopping the others
+func fail(err error) {
+	if output.IsJSON() {
+		output.PrintError(err)
+		return
+	}
+	fmt.Println(failStyle.Render(err.Error()))
+}
@@ -0,0 +1,298 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultTemplate is the cast text when no template is given, the link
+	// is added as an embed
+	DefaultTemplate = "{{.Title}}"
+
+	// maxCastBytes is the longest text hubs accept in a cast
+	maxCastBytes = 320
+	// maxSeen is how many GUIDs are remembered per feed
+	maxSeen = 1000
+)
+
+// Item is one entry of an RSS or Atom feed
+type Item struct {
+	FeedTitle string
+	Title     string
+	Link      string
+	GUID      string
+	Summary   string
+	Published time.Time
+}
+
+type rssDocument struct {
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	Description string `xml:"description"`
+	PubDate     string `xml:"pubDate"`
+}
+
+type atomDocument struct {
+	Title   string      `xml:"title"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	ID        string     `xml:"id"`
+	Links     []atomLink `xml:"link"`
+	Summary   string     `xml:"summary"`
+	Content   string     `xml:"content"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+// Parse reads an RSS 2.0 or Atom document and returns its items oldest
+// first
+func Parse(data []byte) ([]Item, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var root xml.StartElement
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse feed: %v", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			root = start
+			break
+		}
+	}
+
+	var items []Item
+	switch root.Name.Local {
+	case "rss":
+		var doc rssDocument
+		if err := decoder.DecodeElement(&doc, &root); err != nil {
+			return nil, fmt.Errorf("Failed to parse RSS feed: %v", err)
+		}
+		for _, i := range doc.Channel.Items {
+			item := Item{
+				FeedTitle: strings.TrimSpace(doc.Channel.Title),
+				Title:     strings.TrimSpace(i.Title),
+				Link:      strings.TrimSpace(i.Link),
+				GUID:      strings.TrimSpace(i.GUID),
+				Summary:   plainText(i.Description),
+				Published: parseTime(i.PubDate),
+			}
+			if item.GUID == "" {
+				item.GUID = item.Link
+			}
+			items = append(items, item)
+		}
+	case "feed":
+		var doc atomDocument
+		if err := decoder.DecodeElement(&doc, &root); err != nil {
+			return nil, fmt.Errorf("Failed to parse Atom feed: %v", err)
+		}
+		for _, e := range doc.Entries {
+			item := Item{
+				FeedTitle: strings.TrimSpace(doc.Title),
+				Title:     strings.TrimSpace(e.Title),
+				Link:      atomHref(e.Links),
+				GUID:      strings.TrimSpace(e.ID),
+				Summary:   plainText(e.Summary),
+				Published: parseTime(e.Published),
+			}
+			if item.Summary == "" {
+				item.Summary = plainText(e.Content)
+			}
+			if item.Published.IsZero() {
+				item.Published = parseTime(e.Updated)
+			}
+			if item.GUID == "" {
+				item.GUID = item.Link
+			}
+			items = append(items, item)
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported feed, expected <rss> or <feed> but found <%s>", root.Name.Local)
+	}
+
+	// Feeds usually list newest first, cast in the order items were published
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Published.Before(items[j].Published) })
+	return items, nil
+}
+
+func atomHref(links []atomLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return strings.TrimSpace(link.Href)
+		}
+	}
+	if len(links) > 0 {
+		return strings.TrimSpace(links[0].Href)
+	}
+	return ""
+}
+
+var timeLayouts = []string{time.RFC1123Z, time.RFC1123, time.RFC3339, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST", "2006-01-02"}
+
+func parseTime(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// plainText strips HTML tags and entities from a description
+func plainText(s string) string {
+	s = html.UnescapeString(tagPattern.ReplaceAllString(s, " "))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Fetch downloads and parses the feed at url
+func Fetch(url string) ([]Item, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", "mast-rss")
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml, text/xml")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to fetch %s. HTTP status: %d", url, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %v", url, err)
+	}
+	return Parse(data)
+}
+
+// ParseTemplate checks a cast template such as "New post: {{.Title}}"
+func ParseTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = DefaultTemplate
+	}
+	tmpl, err := template.New("cast").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// Render fills the template with item and cuts the text to the length a
+// cast allows
+func Render(tmpl *template.Template, item Item) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, item); err != nil {
+		return "", fmt.Errorf("Failed to render template for %q: %v", item.Title, err)
+	}
+	return truncate(strings.TrimSpace(b.String()), maxCastBytes), nil
+}
+
+// truncate cuts s to at most max bytes on a rune boundary, ending in an
+// ellipsis when anything was cut
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	cut := max - len("…")
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return strings.TrimRight(s[:cut], " ") + "…"
+}
+
+// Seen holds, per feed URL, the GUIDs of items already handled
+type Seen map[string][]string
+
+func seenPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".fc-cast-rss-seen.json"), nil
+}
+
+func LoadSeen() (Seen, error) {
+	path, err := seenPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Seen{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	seen := Seen{}
+	if err := json.Unmarshal(data, &seen); err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %v", path, err)
+	}
+	return seen, nil
+}
+
+func (s Seen) Save() error {
+	path, err := seenPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+func (s Seen) Has(feed string, guid string) bool {
+	for _, g := range s[feed] {
+		if g == guid {
+			return true
+		}
+	}
+	return false
+}
+
+// Add records guid for feed, forgetting the oldest GUIDs past maxSeen
+func (s Seen) Add(feed string, guid string) {
+	guids := append(s[feed], guid)
+	if len(guids) > maxSeen {
+		guids = guids[len(guids)-maxSeen:]
+	}
+	s[feed] = guids
+}
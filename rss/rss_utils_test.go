@@ -0,0 +1,133 @@
+package rss
+
+import (
+	"fmt"
+	"mast/devhub"
+	"mast/hub"
+	"mast/protobufs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPrivateKey = "0707070707070707070707070707070707070707070707070707070707070707"
+
+const rssFeed = `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Blog</title>
+%s
+<item><title>Second post</title><link>https://example.com/2</link><guid>post-2</guid><pubDate>Tue, 04 Jun 2024 10:00:00 +0000</pubDate><description>&lt;p&gt;More &amp;amp; more&lt;/p&gt;</description></item>
+<item><title>First post</title><link>https://example.com/1</link><guid>post-1</guid><pubDate>Mon, 03 Jun 2024 10:00:00 +0000</pubDate></item>
+</channel></rss>`
+
+const atomFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Releases</title>
+<entry><title>v1.1.0</title><id>tag:github.com,2008:v1.1.0</id><link rel="alternate" href="https://github.com/x/y/releases/v1.1.0"/><updated>2024-06-02T00:00:00Z</updated><content type="html">&lt;b&gt;Fixes&lt;/b&gt;</content></entry>
+<entry><title>v1.0.0</title><id>tag:github.com,2008:v1.0.0</id><link href="https://github.com/x/y/releases/v1.0.0"/><updated>2024-06-01T00:00:00Z</updated></entry>
+</feed>`
+
+func TestParse(t *testing.T) {
+	items, err := Parse([]byte(fmt.Sprintf(rssFeed, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].GUID != "post-1" || items[1].Summary != "More & more" || items[1].FeedTitle != "Blog" {
+		t.Errorf("rss items = %+v", items)
+	}
+
+	items, err = Parse([]byte(atomFeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Title != "v1.0.0" || items[1].Link != "https://github.com/x/y/releases/v1.1.0" || items[1].Summary != "Fixes" {
+		t.Errorf("atom items = %+v", items)
+	}
+
+	if _, err := Parse([]byte(`<html></html>`)); err == nil {
+		t.Error("Parse accepted an HTML page")
+	}
+}
+
+func TestRender(t *testing.T) {
+	tmpl, err := ParseTemplate("New on {{.FeedTitle}}: {{.Title}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := Render(tmpl, Item{FeedTitle: "Blog", Title: "Hello"})
+	if err != nil || text != "New on Blog: Hello" {
+		t.Errorf("Render = %q, %v", text, err)
+	}
+
+	text, _ = Render(tmpl, Item{FeedTitle: "Blog", Title: strings.Repeat("é", 400)})
+	if len(text) > maxCastBytes || !strings.HasSuffix(text, "…") {
+		t.Errorf("long text is %d bytes: %q", len(text), text)
+	}
+
+	if _, err := ParseTemplate("{{.Title"); err == nil {
+		t.Error("ParseTemplate accepted an unclosed action")
+	}
+}
+
+func TestPollSeedsThenCastsNewItems(t *testing.T) {
+	handler, err := devhub.NewHandler(devhub.Options{Network: protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hubServer := httptest.NewServer(handler)
+	defer hubServer.Close()
+
+	extra := ""
+	feedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, rssFeed, extra)
+	}))
+	defer feedServer.Close()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.WriteFile(filepath.Join(home, ".fc-cast-fid"), []byte("6596"), 0600)
+	os.WriteFile(filepath.Join(home, ".fc-cast-signer"), []byte(testPrivateKey), 0600)
+	if err := hub.SaveHubPreference(hubServer.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{Feeds: []string{feedServer.URL}, Network: protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET}
+	tmpl, _ := ParseTemplate("")
+
+	if failed := Poll(opts, tmpl, ""); failed != 0 {
+		t.Fatalf("first poll failed for %d feeds", failed)
+	}
+	seen, _ := LoadSeen()
+	if !seen.Has(feedServer.URL, "post-1") || !seen.Has(feedServer.URL, "post-2") {
+		t.Fatalf("seen after first poll = %v", seen)
+	}
+
+	extra = `<item><title>Third post</title><link>https://example.com/3</link><guid>post-3</guid><pubDate>Wed, 05 Jun 2024 10:00:00 +0000</pubDate></item>`
+	opts.DryRun = true
+	Poll(opts, tmpl, "")
+	if seen, _ := LoadSeen(); seen.Has(feedServer.URL, "post-3") {
+		t.Error("dry run marked the new item as seen")
+	}
+
+	opts.DryRun = false
+	if failed := Poll(opts, tmpl, ""); failed != 0 {
+		t.Fatalf("second poll failed for %d feeds", failed)
+	}
+	if failed := Poll(opts, tmpl, ""); failed != 0 {
+		t.Fatalf("third poll failed for %d feeds", failed)
+	}
+
+	page, err := hub.GetMessages("/v1/castsByFid", url.Values{"fid": {"6596"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Messages) != 1 {
+		t.Fatalf("hub has %d casts, want 1", len(page.Messages))
+	}
+	body := page.Messages[0].GetData().GetCastAddBody()
+	if body.Text != "Third post" || len(body.Embeds) != 1 || body.Embeds[0].GetUrl() != "https://example.com/3" {
+		t.Errorf("cast = %v", body)
+	}
+}
@@ -0,0 +1,115 @@
+package serve
+
+import (
+	"fmt"
+	"mast/hub"
+	"mast/output"
+	"mast/protobufs"
+	"mast/submit"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	okStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#7C65C1"))
+	mutedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#767676"))
+	failStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6B6B"))
+)
+
+// Options controls where the API listens and how casts are sent
+type Options struct {
+	Addr string
+	// Socket is a unix socket path used instead of Addr
+	Socket string
+	// Token overrides the one saved in ~/.fc-cast-serve-token
+	Token   string
+	Network protobufs.FarcasterNetwork
+	Force   bool
+}
+
+// Listening is printed once the API is up under --output json, followed by
+// a CastResponse for every cast request
+type Listening struct {
+	Address     string `json:"listening"`
+	TokenSource string `json:"token_source"`
+}
+
+// Serve runs the local REST API until interrupted, printing a line for
+// every cast request
+func Serve(opts Options) error {
+	if err := hub.CheckNetwork(opts.Network); err != nil {
+		return err
+	}
+	info, err := hub.Info()
+	if err != nil {
+		return err
+	}
+	hubNetwork, _ := info.ReportedNetwork()
+
+	token := opts.Token
+	tokenSource := "--token"
+	if token == "" {
+		if token, tokenSource, err = LoadToken(); err != nil {
+			return err
+		}
+	}
+
+	listener, err := Listen(opts.Addr, opts.Socket)
+	if err != nil {
+		return err
+	}
+	if opts.Socket != "" {
+		defer os.Remove(opts.Socket)
+	}
+
+	handler := &Handler{
+		Token:      token,
+		Network:    opts.Network,
+		HubNetwork: hubNetwork,
+		Force:      opts.Force,
+		Log: func(req CastRequest, resp CastResponse) {
+			if output.IsJSON() {
+				output.Print(resp)
+				return
+			}
+			line := fmt.Sprintf("%s %s %s", time.Now().Format("15:04:05"), resp.Status, resp.Hash)
+			if resp.Error != "" {
+				line += ": " + resp.Error
+			}
+			if resp.Status == submit.StatusAccepted || resp.Status == submit.StatusDuplicate {
+				fmt.Println(okStyle.Render(line))
+			} else {
+				fmt.Println(failStyle.Render(line))
+			}
+		},
+	}
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		server.Close()
+	}()
+
+	where := "http://" + listener.Addr().String()
+	if opts.Socket != "" {
+		where = "unix socket " + opts.Socket
+	}
+	if output.IsJSON() {
+		output.Print(Listening{Address: where, TokenSource: tokenSource})
+	} else {
+		fmt.Println(okStyle.Render("Listening on " + where))
+		fmt.Println(mutedStyle.Render("Bearer token from " + tokenSource + ", POST casts to /v1/casts"))
+	}
+
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
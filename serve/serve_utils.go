@@ -0,0 +1,304 @@
+package serve
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mast/auth"
+	"mast/compose"
+	"mast/protobufs"
+	"mast/submit"
+	"mast/thread"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultAddr only accepts connections from this machine
+	DefaultAddr = "127.0.0.1:8787"
+
+	// maxCastBytes is the longest text hubs accept in a cast
+	maxCastBytes = 320
+	// maxBodyBytes bounds the JSON a request may send
+	maxBodyBytes = 64 << 10
+)
+
+// CastRequest is the JSON body of POST /v1/casts
+type CastRequest struct {
+	Text   string   `json:"text"`
+	Embeds []string `json:"embeds,omitempty"`
+	// Channel is a channel id or parent URL, ignored for replies
+	Channel string     `json:"channel,omitempty"`
+	Parent  *ParentRef `json:"parent,omitempty"`
+}
+
+// ParentRef is the cast a request replies to
+type ParentRef struct {
+	Fid  uint64 `json:"fid"`
+	Hash string `json:"hash"`
+}
+
+// CastResponse is returned for every cast request. Status is one of the
+// submit statuses, or "error" when the request never reached the hub.
+type CastResponse struct {
+	Hash   string `json:"hash,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Handler answers the local REST API. Casts are signed with the account
+// mast is authorized for and submitted one at a time per account, so
+// requests arriving together keep their order on the hub.
+type Handler struct {
+	Token string
+	// Network is what casts are signed for and HubNetwork what the hub
+	// reports, FARCASTER_NETWORK_NONE when it didn't say
+	Network    protobufs.FarcasterNetwork
+	HubNetwork protobufs.FarcasterNetwork
+	// Force skips the channel membership check
+	Force bool
+	// Log is called once per handled cast request
+	Log func(CastRequest, CastResponse)
+
+	mu    sync.Mutex
+	locks map[uint64]*sync.Mutex
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1/health":
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	case "/v1/casts":
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSON(w, http.StatusMethodNotAllowed, CastResponse{Status: "error", Error: "Use POST to send a cast"})
+			return
+		}
+		if !h.authorized(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, CastResponse{Status: "error", Error: "Missing or wrong bearer token"})
+			return
+		}
+		h.cast(w, r)
+	default:
+		writeJSON(w, http.StatusNotFound, CastResponse{Status: "error", Error: "Not found"})
+	}
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && h.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) == 1
+}
+
+func (h *Handler) cast(w http.ResponseWriter, r *http.Request) {
+	var req CastRequest
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, CastResponse{Status: "error", Error: fmt.Sprintf("Invalid JSON: %v", err)})
+		return
+	}
+
+	castData, err := req.CastData()
+	if err != nil {
+		h.respond(w, req, http.StatusBadRequest, CastResponse{Status: "error", Error: err.Error()})
+		return
+	}
+
+	code, resp := h.submit(castData)
+	h.respond(w, req, code, resp)
+}
+
+func (h *Handler) respond(w http.ResponseWriter, req CastRequest, code int, resp CastResponse) {
+	if h.Log != nil {
+		h.Log(req, resp)
+	}
+	writeJSON(w, code, resp)
+}
+
+// submit signs and sends castData while holding the author's lock
+func (h *Handler) submit(castData compose.CastData) (int, CastResponse) {
+	if !h.Force {
+		if err := compose.CheckMembership(castData); err != nil {
+			return http.StatusForbidden, CastResponse{Status: "error", Error: err.Error()}
+		}
+	}
+
+	fid, _, err := auth.FindFidAndPrivateKey()
+	if err != nil {
+		return http.StatusInternalServerError, CastResponse{Status: "error", Error: fmt.Sprintf("Problem retrieving credentials, run mast auth to authorize the CLI: %v", err)}
+	}
+
+	// Signing happens under the lock too, so timestamps follow the order
+	// casts reach the hub
+	lock := h.lock(fid)
+	lock.Lock()
+	defer lock.Unlock()
+
+	_, msg, err := compose.BuildCast(castData, h.Network, false)
+	if err != nil {
+		return http.StatusInternalServerError, CastResponse{Status: "error", Error: err.Error()}
+	}
+	result := submit.Message(msg, h.HubNetwork)
+
+	resp := CastResponse{Hash: result.Hash, Status: result.Status, Error: result.Reason}
+	switch result.Status {
+	case submit.StatusAccepted, submit.StatusDuplicate:
+		return http.StatusOK, resp
+	case submit.StatusRateLimited:
+		return http.StatusTooManyRequests, resp
+	case submit.StatusInvalid:
+		return http.StatusInternalServerError, resp
+	default:
+		return http.StatusBadGateway, resp
+	}
+}
+
+func (h *Handler) lock(fid uint64) *sync.Mutex {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.locks == nil {
+		h.locks = map[uint64]*sync.Mutex{}
+	}
+	if h.locks[fid] == nil {
+		h.locks[fid] = &sync.Mutex{}
+	}
+	return h.locks[fid]
+}
+
+// CastData checks the request and turns it into what compose signs
+func (req CastRequest) CastData() (compose.CastData, error) {
+	if strings.TrimSpace(req.Text) == "" && len(req.Embeds) == 0 {
+		return compose.CastData{}, fmt.Errorf("A cast needs text or an embed")
+	}
+	if len(req.Text) > maxCastBytes {
+		return compose.CastData{}, fmt.Errorf("Text is %d bytes, casts allow %d", len(req.Text), maxCastBytes)
+	}
+
+	var embeds []compose.Embed
+	for _, value := range req.Embeds {
+		embed, err := compose.ParseEmbed(value)
+		if err != nil {
+			return compose.CastData{}, err
+		}
+		// The API never reads files from the machine it runs on
+		if embed.Kind == compose.EmbedFile {
+			return compose.CastData{}, fmt.Errorf("Embed %q is a file, upload it and embed its URL", value)
+		}
+		embeds = append(embeds, embed)
+	}
+	embeds, err := compose.NormalizeEmbeds(embeds)
+	if err != nil {
+		return compose.CastData{}, err
+	}
+
+	castData := compose.CastData{Message: req.Text, Embeds: embeds, Channel: req.Channel}
+
+	if req.Parent != nil {
+		if req.Parent.Fid == 0 {
+			return compose.CastData{}, fmt.Errorf("parent.fid is required")
+		}
+		hash, err := thread.ParseHash(req.Parent.Hash)
+		if err != nil {
+			return compose.CastData{}, err
+		}
+		castData.ParentCast = &protobufs.CastId{Fid: req.Parent.Fid, Hash: hash}
+		castData.Channel = ""
+	}
+	return castData, nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func tokenPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".fc-cast-serve-token"), nil
+}
+
+// LoadToken returns the saved API token, creating a random one the first
+// time
+func LoadToken() (string, string, error) {
+	path, err := tokenPath()
+	if err != nil {
+		return "", "", err
+	}
+	data, err := os.ReadFile(path)
+	if err == nil && strings.TrimSpace(string(data)) != "" {
+		return strings.TrimSpace(string(data)), path, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", err
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("Failed to generate token: %v", err)
+	}
+	token := hex.EncodeToString(buf)
+	if err := os.WriteFile(path, []byte(token), 0600); err != nil {
+		return "", "", fmt.Errorf("Failed to save token: %v", err)
+	}
+	return token, path, nil
+}
+
+// Listen opens a unix socket at socket when it is set, otherwise a TCP
+// listener on addr, which must be a loopback address
+func Listen(addr string, socket string) (net.Listener, error) {
+	if socket != "" {
+		if info, err := os.Lstat(socket); err == nil {
+			if info.Mode()&os.ModeSocket == 0 {
+				return nil, fmt.Errorf("%s exists and is not a socket", socket)
+			}
+			if conn, err := net.Dial("unix", socket); err == nil {
+				conn.Close()
+				return nil, fmt.Errorf("%s is already in use", socket)
+			}
+			// Left behind by a server that didn't shut down cleanly
+			os.Remove(socket)
+		}
+		listener, err := net.Listen("unix", socket)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to listen on %s: %v", socket, err)
+		}
+		if err := os.Chmod(socket, 0600); err != nil {
+			listener.Close()
+			return nil, err
+		}
+		return listener, nil
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid address %q: %v", addr, err)
+	}
+	if !isLoopback(host) {
+		return nil, fmt.Errorf("Refusing to listen on %s, use a loopback address such as 127.0.0.1 or a --socket", addr)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to listen on %s: %v", addr, err)
+	}
+	return listener, nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
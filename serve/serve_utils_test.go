@@ -0,0 +1,166 @@
+package serve
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mast/devhub"
+	"mast/hub"
+	"mast/protobufs"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+const testPrivateKey = "0707070707070707070707070707070707070707070707070707070707070707"
+
+// startAPI runs a mock hub and the API in front of it, signed in as fid 6596
+func startAPI(t *testing.T) *httptest.Server {
+	t.Helper()
+	handler, err := devhub.NewHandler(devhub.Options{Network: protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hubServer := httptest.NewServer(handler)
+	t.Cleanup(hubServer.Close)
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.WriteFile(filepath.Join(home, ".fc-cast-fid"), []byte("6596"), 0600)
+	os.WriteFile(filepath.Join(home, ".fc-cast-signer"), []byte(testPrivateKey), 0600)
+	if err := hub.SaveHubPreference(hubServer.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	api := httptest.NewServer(&Handler{
+		Token:      "secret",
+		Network:    protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET,
+		HubNetwork: protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET,
+	})
+	t.Cleanup(api.Close)
+	return api
+}
+
+func post(t *testing.T, api *httptest.Server, token string, body string) (int, CastResponse) {
+	t.Helper()
+	req, _ := http.NewRequest("POST", api.URL+"/v1/casts", bytes.NewBufferString(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out CastResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, out
+}
+
+func TestCastRequests(t *testing.T) {
+	api := startAPI(t)
+
+	if code, _ := post(t, api, "", `{"text":"hi"}`); code != http.StatusUnauthorized {
+		t.Errorf("no token: %d", code)
+	}
+	if code, _ := post(t, api, "wrong", `{"text":"hi"}`); code != http.StatusUnauthorized {
+		t.Errorf("wrong token: %d", code)
+	}
+	if code, resp := post(t, api, "secret", `{"text":"hi","mentions":[1]}`); code != http.StatusBadRequest || resp.Status != "error" {
+		t.Errorf("unknown field: %d %+v", code, resp)
+	}
+	if code, _ := post(t, api, "secret", `{"text":"hi","embeds":["ftp://x"]}`); code != http.StatusBadRequest {
+		t.Errorf("ftp embed: %d", code)
+	}
+	if code, _ := post(t, api, "secret", `{"text":"hi","embeds":["file:/etc/passwd"]}`); code != http.StatusBadRequest {
+		t.Errorf("file embed: %d", code)
+	}
+
+	code, first := post(t, api, "secret", `{"text":"Deploy finished","embeds":["https://ci.example.com/42"]}`)
+	if code != http.StatusOK || first.Status != "accepted" || first.Hash == "" {
+		t.Fatalf("cast: %d %+v", code, first)
+	}
+
+	code, reply := post(t, api, "secret", fmt.Sprintf(`{"text":"Details","embeds":["cast:6596:%s"],"parent":{"fid":6596,"hash":%q}}`, first.Hash, first.Hash))
+	if code != http.StatusOK || reply.Status != "accepted" {
+		t.Fatalf("reply: %d %+v", code, reply)
+	}
+
+	page, err := hub.GetMessages("/v1/castsByParent", url.Values{"fid": {"6596"}, "hash": {first.Hash}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Messages) != 1 || page.Messages[0].GetData().GetCastAddBody().Text != "Details" {
+		t.Errorf("replies = %v", page.Messages)
+	}
+}
+
+func TestConcurrentCastsAreAllSent(t *testing.T) {
+	api := startAPI(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if code, resp := post(t, api, "secret", fmt.Sprintf(`{"text":"cast %d"}`, i)); code != http.StatusOK {
+				t.Errorf("cast %d: %d %+v", i, code, resp)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	page, err := hub.GetMessages("/v1/castsByFid", url.Values{"fid": {"6596"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Messages) != 5 {
+		t.Errorf("hub has %d casts, want 5", len(page.Messages))
+	}
+}
+
+func TestListen(t *testing.T) {
+	if _, err := Listen("0.0.0.0:0", ""); err == nil {
+		t.Error("Listen accepted a public address")
+	}
+
+	listener, err := Listen("127.0.0.1:0", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.Close()
+
+	socket := filepath.Join(t.TempDir(), "mast.sock")
+	listener, err = Listen("", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen("", socket); err == nil {
+		t.Error("Listen took over a socket in use")
+	}
+	if conn, err := net.Dial("unix", socket); err != nil {
+		t.Errorf("dial socket: %v", err)
+	} else {
+		conn.Close()
+	}
+	listener.Close()
+}
+
+func TestLoadTokenIsStable(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	first, _, err := LoadToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, _ := LoadToken()
+	if len(first) != 64 || first != second {
+		t.Errorf("tokens %q and %q", first, second)
+	}
+}
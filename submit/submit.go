@@ -0,0 +1,177 @@
+package submit
+
+import (
+	"errors"
+	"fmt"
+	"mast/hub"
+	"mast/message"
+	"mast/output"
+	"mast/protobufs"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/golang/protobuf/proto"
+)
+
+var (
+	okStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#7C65C1"))
+	warnStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#767676"))
+	failStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6B6B"))
+)
+
+const (
+	StatusAccepted    = "accepted"
+	StatusDuplicate   = "duplicate"
+	StatusRejected    = "rejected"
+	StatusInvalid     = "invalid"
+	StatusRateLimited = "rate limited"
+)
+
+type Result struct {
+	Hash   string
+	Status string
+	Reason string
+	// RetryAfter is set for rate limited messages when the hub said how
+	// long to wait
+	RetryAfter time.Duration
+}
+
+// Message verifies msg locally and submits it to the preferred hub,
+// classifying the outcome instead of returning an error. Messages for a
+// network other than hubNetwork are rejected without being sent, unless
+// hubNetwork is FARCASTER_NETWORK_NONE because the hub did not report one.
+func Message(msg *protobufs.Message, hubNetwork protobufs.FarcasterNetwork) Result {
+	result := Result{Hash: fmt.Sprintf("0x%x", msg.Hash)}
+
+	if err := message.Verify(msg); err != nil {
+		result.Status = StatusInvalid
+		result.Reason = err.Error()
+		return result
+	}
+
+	if hubNetwork != protobufs.FarcasterNetwork_FARCASTER_NETWORK_NONE {
+		network, err := messageNetwork(msg)
+		if err != nil {
+			result.Status = StatusInvalid
+			result.Reason = err.Error()
+			return result
+		}
+		if network != hubNetwork {
+			result.Status = StatusRejected
+			result.Reason = fmt.Sprintf("message is for %s but the hub reports %s", hub.NetworkName(network), hub.NetworkName(hubNetwork))
+			return result
+		}
+	}
+
+	msgBytes, err := proto.Marshal(msg)
+	if err != nil {
+		result.Status = StatusInvalid
+		result.Reason = err.Error()
+		return result
+	}
+
+	_, err = hub.SubmitMessage(msgBytes)
+	if err != nil {
+		var hubErr *hub.HubError
+		if errors.As(err, &hubErr) {
+			if hubErr.RateLimited() {
+				result.Status = StatusRateLimited
+				result.Reason = hubErr.Error()
+				result.RetryAfter = hubErr.RetryAfter
+				return result
+			}
+			if hubErr.Duplicate() {
+				result.Status = StatusDuplicate
+				result.Reason = hubErr.Reason
+				return result
+			}
+			if hubErr.Reason != "" {
+				result.Status = StatusRejected
+				result.Reason = hubErr.Reason
+				return result
+			}
+		}
+		result.Status = StatusRejected
+		result.Reason = err.Error()
+		return result
+	}
+
+	result.Status = StatusAccepted
+	return result
+}
+
+// NewCastResult describes msg and the outcome of submitting it for
+// --output json
+func NewCastResult(msg *protobufs.Message, result Result) output.CastResult {
+	data := msg.GetData()
+	if data == nil && len(msg.GetDataBytes()) > 0 {
+		data = &protobufs.MessageData{}
+		proto.Unmarshal(msg.GetDataBytes(), data)
+	}
+	out := output.NewCastResult(data, result.Hash)
+	out.Status, out.Reason = result.Status, result.Reason
+	return out
+}
+
+// SubmitFile pushes every signed message stored in path to the preferred hub
+// and prints one result line per message
+func SubmitFile(path string, format string) error {
+	msgs, err := message.ReadFile(path, format)
+	if err != nil {
+		return err
+	}
+	if len(msgs) == 0 {
+		return fmt.Errorf("No messages found in %s", path)
+	}
+
+	info, err := hub.Info()
+	if err != nil {
+		return err
+	}
+	hubNetwork, _ := info.ReportedNetwork()
+
+	failed := 0
+	for i, msg := range msgs {
+		result := Message(msg, hubNetwork)
+		if output.IsJSON() {
+			if result.Status != StatusAccepted && result.Status != StatusDuplicate {
+				failed++
+			}
+			if err := output.Print(NewCastResult(msg, result)); err != nil {
+				return err
+			}
+			continue
+		}
+		line := fmt.Sprintf("[%d/%d] %s %s", i+1, len(msgs), result.Hash, result.Status)
+		if result.Reason != "" {
+			line += ": " + result.Reason
+		}
+
+		switch result.Status {
+		case StatusAccepted:
+			fmt.Println(okStyle.Render(line))
+		case StatusDuplicate:
+			fmt.Println(warnStyle.Render(line))
+		default:
+			failed++
+			fmt.Println(failStyle.Render(line))
+		}
+	}
+
+	if failed > 0 {
+		return output.Errorf(output.CodeSubmitFailed, "%d of %d messages failed to submit", failed, len(msgs))
+	}
+	return nil
+}
+
+func messageNetwork(msg *protobufs.Message) (protobufs.FarcasterNetwork, error) {
+	dataBytes, err := message.MessageDataBytes(msg)
+	if err != nil {
+		return protobufs.FarcasterNetwork_FARCASTER_NETWORK_NONE, err
+	}
+	msgData := &protobufs.MessageData{}
+	if err := proto.Unmarshal(dataBytes, msgData); err != nil {
+		return protobufs.FarcasterNetwork_FARCASTER_NETWORK_NONE, fmt.Errorf("Failed to decode message data: %v", err)
+	}
+	return msgData.Network, nil
+}
@@ -0,0 +1,129 @@
+package templates
+
+import (
+	"fmt"
+	"mast/output"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	okStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#7C65C1"))
+	mutedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#767676"))
+)
+
+// Listed is what mast templates list prints for each template under
+// --output json
+type Listed struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Vars        []string `json:"vars"`
+	Thread      bool     `json:"thread"`
+}
+
+// ListTemplates prints the saved templates with their descriptions and
+// variables
+func ListTemplates() error {
+	list, err := List()
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		dir, _ := Dir()
+		line := fmt.Sprintf("No templates in %s yet, create one with mast templates edit <name>", dir)
+		if output.IsJSON() {
+			fmt.Fprintln(os.Stderr, line)
+			return nil
+		}
+		fmt.Println(mutedStyle.Render(line))
+		return nil
+	}
+	for _, t := range list {
+		if output.IsJSON() {
+			vars := t.Vars()
+			if vars == nil {
+				vars = []string{}
+			}
+			if err := output.Print(Listed{Name: t.Name, Description: t.Description, Vars: vars, Thread: t.Thread}); err != nil {
+				return err
+			}
+			continue
+		}
+		line := okStyle.Render(t.Name)
+		if t.Description != "" {
+			line += "  " + t.Description
+		}
+		if vars := t.Vars(); len(vars) > 0 {
+			line += mutedStyle.Render("  --var " + strings.Join(vars, " --var "))
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// Show prints the file of the template called name
+func Show(name string) error {
+	if _, err := Load(name); err != nil {
+		return err
+	}
+	path, _ := Path(name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if output.IsJSON() {
+		return output.Print(map[string]string{"name": name, "path": path, "source": string(data)})
+	}
+	fmt.Print(string(data))
+	return nil
+}
+
+// Edit opens the template called name in $VISUAL or $EDITOR, starting a new
+// one from Starter, and checks it once the editor exits
+func Edit(name string) error {
+	if output.IsJSON() {
+		return output.Interactive("mast templates edit")
+	}
+	path, err := Path(name)
+	if err != nil {
+		return err
+	}
+	dir, _ := Dir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("Failed to create %s: %v", dir, err)
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := os.WriteFile(path, []byte(Starter(name)), 0600); err != nil {
+			return fmt.Errorf("Failed to create template %s: %v", name, err)
+		}
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// EDITOR may carry arguments, such as "code --wait"
+	args := append(strings.Fields(editor), path)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Editor %s failed: %v", args[0], err)
+	}
+
+	t, err := Load(name)
+	if err != nil {
+		return fmt.Errorf("%v, run mast templates edit %s again to fix it", err, name)
+	}
+	usage := "mast new --template " + name
+	for _, v := range t.Vars() {
+		usage += " --var " + v + "=…"
+	}
+	fmt.Println(okStyle.Render("Saved template " + name + ", use it with " + usage))
+	return nil
+}
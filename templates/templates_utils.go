@@ -0,0 +1,382 @@
+package templates
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+const (
+	// maxCastBytes is the longest text hubs accept in a cast
+	maxCastBytes = 320
+	// extension is what template files in the templates directory end in
+	extension = ".tmpl"
+)
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// templateKeys are the frontmatter keys a template file may set
+var templateKeys = []string{"description", "embed", "channel", "thread"}
+
+// Template is a named cast shape. Text, Embeds and Channel are Go
+// text/template sources filled in with --var values, such as
+// {{.version}}.
+type Template struct {
+	Name        string
+	Description string
+	Text        string
+	Embeds      []string
+	Channel     string
+	// Thread splits text that doesn't fit in one cast into replies
+	Thread bool
+}
+
+// Rendered is a template filled in with its variables. Parts holds the
+// text of each cast, the first one carries the embeds and channel and the
+// rest reply to it in order.
+type Rendered struct {
+	Parts   []string
+	Embeds  []string
+	Channel string
+}
+
+// Dir is where templates are saved, one file per template
+func Dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".fc-cast-templates"), nil
+}
+
+// Path returns the file for the template called name
+func Path(name string) (string, error) {
+	if !namePattern.MatchString(name) {
+		return "", fmt.Errorf("Invalid template name %q, use lowercase letters, digits, - and _", name)
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+extension), nil
+}
+
+// Parse reads a template file, which is the cast text with an optional
+// frontmatter block in front of it, such as
+//
+//	---
+//	description: Release notes
+//	channel: dev
+//	embed: https://github.com/me/app/releases/tag/v{{.version}}
+//	thread: true
+//	---
+//	App {{.version}} is out
+//
+// embed can be repeated. Every field but description and thread is
+// checked to be a valid Go template.
+func Parse(name string, source string) (Template, error) {
+	t := Template{Name: name}
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	lines := strings.Split(source, "\n")
+	if strings.TrimSpace(lines[0]) == "---" {
+		closing := 0
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == "---" {
+				closing = i
+				break
+			}
+		}
+		if closing == 0 {
+			return Template{}, fmt.Errorf("Template %s: frontmatter is missing its closing ---", name)
+		}
+
+		for i, line := range lines[1:closing] {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				return Template{}, fmt.Errorf("Template %s: frontmatter line %d should be key: value, got %q", name, i+2, line)
+			}
+			value = strings.TrimSpace(value)
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "description":
+				t.Description = value
+			case "embed":
+				if value != "" {
+					t.Embeds = append(t.Embeds, value)
+				}
+			case "channel":
+				t.Channel = value
+			case "thread":
+				if value == "" {
+					continue
+				}
+				thread, err := strconv.ParseBool(value)
+				if err != nil {
+					return Template{}, fmt.Errorf("Template %s: thread should be true or false, got %q", name, value)
+				}
+				t.Thread = thread
+			default:
+				return Template{}, fmt.Errorf("Template %s: unknown frontmatter key %q, expected one of %s", name, key, strings.Join(templateKeys, ", "))
+			}
+		}
+		lines = lines[closing+1:]
+	}
+	t.Text = strings.TrimSpace(strings.Join(lines, "\n"))
+
+	for _, field := range t.sources() {
+		if _, err := newTemplate(field); err != nil {
+			return Template{}, fmt.Errorf("Template %s: %v", name, err)
+		}
+	}
+	return t, nil
+}
+
+// Load reads the saved template called name
+func Load(name string) (Template, error) {
+	path, err := Path(name)
+	if err != nil {
+		return Template{}, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Template{}, fmt.Errorf("No template called %s, create it with mast templates edit %s", name, name)
+	}
+	if err != nil {
+		return Template{}, fmt.Errorf("Failed to read template %s: %v", name, err)
+	}
+	return Parse(name, string(data))
+}
+
+// List returns the saved templates sorted by name. A file that fails to
+// parse is reported rather than left out.
+func List() ([]Template, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %v", dir, err)
+	}
+
+	var list []Template
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), extension)
+		if !ok || entry.IsDir() || !namePattern.MatchString(name) {
+			continue
+		}
+		t, err := Load(name)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// ParseVars reads --var values given as name=value
+func ParseVars(values []string) (map[string]string, error) {
+	vars := map[string]string{}
+	for _, value := range values {
+		name, v, ok := strings.Cut(value, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("--var %q should be name=value", value)
+		}
+		vars[name] = v
+	}
+	return vars, nil
+}
+
+func (t Template) sources() []string {
+	return append([]string{t.Text, t.Channel}, t.Embeds...)
+}
+
+func newTemplate(source string) (*template.Template, error) {
+	return template.New("").Option("missingkey=error").Parse(source)
+}
+
+// Vars lists the variables the template uses, in the order they first
+// appear
+func (t Template) Vars() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, source := range t.sources() {
+		tmpl, err := newTemplate(source)
+		if err != nil || tmpl.Tree == nil {
+			continue
+		}
+		walk(tmpl.Tree.Root, func(name string) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		})
+	}
+	return names
+}
+
+// walk calls found with the first identifier of every field, such as
+// version for {{.version}}, in the tree under node
+func walk(node parse.Node, found func(string)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walk(child, found)
+		}
+	case *parse.ActionNode:
+		walk(n.Pipe, found)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			walk(cmd, found)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walk(arg, found)
+		}
+	case *parse.FieldNode:
+		found(n.Ident[0])
+	case *parse.IfNode:
+		walk(n.Pipe, found)
+		walk(n.List, found)
+		walk(n.ElseList, found)
+	case *parse.RangeNode:
+		// Fields inside range and with are relative to the new dot
+		walk(n.Pipe, found)
+		walk(n.ElseList, found)
+	case *parse.WithNode:
+		walk(n.Pipe, found)
+		walk(n.ElseList, found)
+	}
+}
+
+// Render fills the template in with vars, every variable it uses must be
+// set
+func (t Template) Render(vars map[string]string) (Rendered, error) {
+	for _, name := range t.Vars() {
+		if _, ok := vars[name]; !ok {
+			return Rendered{}, fmt.Errorf("Template %s needs --var %s=…", t.Name, name)
+		}
+	}
+
+	execute := func(source string) (string, error) {
+		tmpl, err := newTemplate(source)
+		if err != nil {
+			return "", err
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, vars); err != nil {
+			return "", fmt.Errorf("Template %s: %v", t.Name, err)
+		}
+		return strings.TrimSpace(b.String()), nil
+	}
+
+	text, err := execute(t.Text)
+	if err != nil {
+		return Rendered{}, err
+	}
+	r := Rendered{Parts: []string{text}}
+	if r.Channel, err = execute(t.Channel); err != nil {
+		return Rendered{}, err
+	}
+	for _, source := range t.Embeds {
+		embed, err := execute(source)
+		if err != nil {
+			return Rendered{}, err
+		}
+		if embed != "" {
+			r.Embeds = append(r.Embeds, embed)
+		}
+	}
+
+	if len(text) > maxCastBytes {
+		if !t.Thread {
+			return Rendered{}, fmt.Errorf("Template %s renders to %d bytes, casts allow %d, set thread: true to split it into replies", t.Name, len(text), maxCastBytes)
+		}
+		r.Parts = Split(text, maxCastBytes)
+	}
+	return r, nil
+}
+
+// Split breaks text into parts of at most max bytes, between paragraphs
+// where it can, then between lines, then between words
+func Split(text string, max int) []string {
+	var parts []string
+	for _, part := range split(text, max, []string{"\n\n", "\n", " "}) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+func split(text string, max int, seps []string) []string {
+	if len(text) <= max {
+		return []string{text}
+	}
+	if len(seps) == 0 {
+		// A single word longer than a cast, cut it on a rune boundary
+		cut := max
+		for cut > 0 && !isRuneStart(text[cut]) {
+			cut--
+		}
+		return append([]string{text[:cut]}, split(text[cut:], max, nil)...)
+	}
+
+	sep := seps[0]
+	var parts []string
+	current := ""
+	for _, piece := range strings.Split(text, sep) {
+		candidate := piece
+		if current != "" {
+			candidate = current + sep + piece
+		}
+		if len(candidate) <= max {
+			current = candidate
+			continue
+		}
+		if current != "" {
+			parts = append(parts, current)
+		}
+		sub := split(piece, max, seps[1:])
+		parts = append(parts, sub[:len(sub)-1]...)
+		current = sub[len(sub)-1]
+	}
+	return append(parts, current)
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// Starter is the file mast templates edit opens for a new template
+func Starter(name string) string {
+	return fmt.Sprintf(`---
+# Fields and text are Go templates, fill them in with --var name=value
+description:
+channel:
+embed:
+thread: false
+---
+%s {{.version}} is out
+`, name)
+}
@@ -0,0 +1,126 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+const release = `---
+description: Release notes
+channel: {{.channel}}
+embed: https://github.com/me/app/releases/tag/v{{.version}}
+embed:
+thread: true
+---
+App {{.version}} is out
+{{if .notes}}
+{{.notes}}{{end}}
+`
+
+func TestParseAndRender(t *testing.T) {
+	tmpl, err := Parse("release", release)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Description != "Release notes" || !tmpl.Thread || len(tmpl.Embeds) != 1 {
+		t.Errorf("template = %+v", tmpl)
+	}
+	if vars := tmpl.Vars(); !reflect.DeepEqual(vars, []string{"version", "notes", "channel"}) {
+		t.Errorf("Vars = %v", vars)
+	}
+
+	if _, err := tmpl.Render(map[string]string{"version": "1.4.0"}); err == nil || !strings.Contains(err.Error(), "--var notes=") {
+		t.Errorf("missing vars: %v", err)
+	}
+
+	r, err := tmpl.Render(map[string]string{"version": "1.4.0", "notes": "", "channel": "dev"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Rendered{
+		Parts:   []string{"App 1.4.0 is out"},
+		Embeds:  []string{"https://github.com/me/app/releases/tag/v1.4.0"},
+		Channel: "dev",
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("Render = %+v", r)
+	}
+
+	notes := strings.Repeat("- fixed a bug\n", 40)
+	r, err = tmpl.Render(map[string]string{"version": "1.4.0", "notes": notes, "channel": "dev"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Parts) < 2 || r.Parts[0] != "App 1.4.0 is out" {
+		t.Errorf("thread parts = %q", r.Parts)
+	}
+
+	tmpl.Thread = false
+	if _, err := tmpl.Render(map[string]string{"version": "1.4.0", "notes": notes, "channel": "dev"}); err == nil {
+		t.Error("a long cast without thread: true should fail")
+	}
+
+	for _, source := range []string{
+		"---\ncolour: red\n---\nhi",
+		"---\nthread: maybe\n---\nhi",
+		"---\nchannel: dev\nhi",
+		"Hello {{.name",
+	} {
+		if _, err := Parse("bad", source); err == nil {
+			t.Errorf("Parse accepted %q", source)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	text := "First paragraph.\n\n" + strings.Repeat("word ", 30) + "\n\n" + strings.Repeat("é", 30)
+	parts := Split(text, 40)
+	for _, part := range parts {
+		if len(part) > 40 || !utf8.ValidString(part) {
+			t.Errorf("part %q is too long or not valid UTF-8", part)
+		}
+	}
+	squash := func(s string) string { return strings.Join(strings.Fields(s), "") }
+	if parts[0] != "First paragraph." || squash(strings.Join(parts, "")) != squash(text) {
+		t.Errorf("parts = %q", parts)
+	}
+}
+
+func TestListAndLoad(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if list, err := List(); err != nil || len(list) != 0 {
+		t.Errorf("empty List = %v, %v", list, err)
+	}
+
+	dir := filepath.Join(home, ".fc-cast-templates")
+	os.MkdirAll(dir, 0700)
+	os.WriteFile(filepath.Join(dir, "weekly.tmpl"), []byte("Week {{.week}} update"), 0600)
+	os.WriteFile(filepath.Join(dir, "release.tmpl"), []byte(release), 0600)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0600)
+
+	list, err := List()
+	if err != nil || len(list) != 2 || list[0].Name != "release" || list[1].Name != "weekly" {
+		t.Fatalf("List = %+v, %v", list, err)
+	}
+	if _, err := Load("missing"); err == nil {
+		t.Error("Load found a missing template")
+	}
+	if _, err := Path("../escape"); err == nil {
+		t.Error("Path accepted ../escape")
+	}
+}
+
+func TestParseVars(t *testing.T) {
+	vars, err := ParseVars([]string{"version=1.4.0", "notes=a=b", "empty="})
+	if err != nil || !reflect.DeepEqual(vars, map[string]string{"version": "1.4.0", "notes": "a=b", "empty": ""}) {
+		t.Errorf("ParseVars = %v, %v", vars, err)
+	}
+	if _, err := ParseVars([]string{"version"}); err == nil {
+		t.Error("ParseVars accepted a value without =")
+	}
+}
@@ -0,0 +1,233 @@
+package thread
+
+import (
+	"bytes"
+	"fmt"
+	"mast/feed"
+	"mast/hub"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	titleStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFFFF")).Background(lipgloss.Color("#7C65C1")).Padding(0, 1)
+	authorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#7C65C1")).Bold(true)
+	mutedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#767676"))
+	cursorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#7C65C1"))
+)
+
+// row is a visible node in the tree and the line its header starts on
+type row struct {
+	node  *Node
+	depth int
+	line  int
+	lines int
+}
+
+type model struct {
+	root     *Node
+	focus    []byte
+	viewport viewport.Model
+	rows     []row
+	cursor   int
+	ready    bool
+}
+
+func newModel(root *Node, focus []byte) model {
+	return model{root: root, focus: focus}
+}
+
+func (m model) Init() tea.Cmd {
+	return nil
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		headerHeight, footerHeight := 2, 2
+		if !m.ready {
+			m.viewport = viewport.New(msg.Width, msg.Height-headerHeight-footerHeight)
+			m.ready = true
+			m.render()
+			for i, r := range m.rows {
+				if bytes.Equal(r.node.Cast.Hash, m.focus) {
+					m.cursor = i
+				}
+			}
+		} else {
+			m.viewport.Width = msg.Width
+			m.viewport.Height = msg.Height - headerHeight - footerHeight
+		}
+		m.render()
+		m.scrollToCursor()
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "esc", "ctrl+c":
+			return m, tea.Quit
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.rows)-1 {
+				m.cursor++
+			}
+		case "enter", " ":
+			if len(m.rows) > 0 {
+				node := m.rows[m.cursor].node
+				if len(node.Replies) > 0 {
+					node.Collapsed = !node.Collapsed
+				}
+			}
+		case "pgdown", "pgup", "home", "end":
+			var cmd tea.Cmd
+			m.viewport, cmd = m.viewport.Update(msg)
+			return m, cmd
+		default:
+			return m, nil
+		}
+		m.render()
+		m.scrollToCursor()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.viewport, cmd = m.viewport.Update(msg)
+	return m, cmd
+}
+
+// render lays out the visible part of the tree into the viewport
+func (m *model) render() {
+	m.rows = m.rows[:0]
+	m.collect(m.root, 0)
+	if m.cursor >= len(m.rows) {
+		m.cursor = len(m.rows) - 1
+	}
+
+	var lines []string
+	now := time.Now()
+	for i := range m.rows {
+		r := &m.rows[i]
+		r.line = len(lines)
+		block := m.renderNode(r.node, r.depth, i == m.cursor, now)
+		r.lines = len(block)
+		lines = append(lines, block...)
+		lines = append(lines, "")
+	}
+	m.viewport.SetContent(strings.Join(lines, "\n"))
+}
+
+func (m *model) collect(n *Node, depth int) {
+	m.rows = append(m.rows, row{node: n, depth: depth})
+	if n.Collapsed {
+		return
+	}
+	for _, reply := range n.Replies {
+		m.collect(reply, depth+1)
+	}
+}
+
+func (m model) renderNode(n *Node, depth int, selected bool, now time.Time) []string {
+	indent := strings.Repeat("  ", depth)
+	bar := " "
+	if selected {
+		bar = cursorStyle.Render("│")
+	}
+
+	marker := "• "
+	switch {
+	case len(n.Replies) > 0 && n.Collapsed:
+		marker = "▸ "
+	case len(n.Replies) > 0:
+		marker = "▾ "
+	}
+
+	header := marker + authorStyle.Render(n.Cast.AuthorName()) + mutedStyle.Render(" · "+feed.RelativeTime(n.Cast.Timestamp, now))
+	if n.Collapsed {
+		header += mutedStyle.Render(fmt.Sprintf(" · %d hidden", n.Count()-1))
+	}
+	if bytes.Equal(n.Cast.Hash, m.focus) && !bytes.Equal(m.root.Cast.Hash, m.focus) {
+		header += mutedStyle.Render(" · this cast")
+	}
+	lines := []string{bar + indent + header}
+
+	width := m.viewport.Width - len(indent) - 4
+	if width < 20 {
+		width = 20
+	}
+	if strings.TrimSpace(n.Cast.Text) != "" {
+		for _, line := range strings.Split(lipgloss.NewStyle().Width(width).Render(n.Cast.Text), "\n") {
+			lines = append(lines, bar+indent+"  "+strings.TrimRight(line, " "))
+		}
+	}
+	for _, embed := range n.Cast.Embeds {
+		lines = append(lines, bar+indent+"  "+mutedStyle.Render("🔗 "+embed))
+	}
+	return lines
+}
+
+// scrollToCursor moves the viewport so the selected cast is visible
+func (m *model) scrollToCursor() {
+	if len(m.rows) == 0 {
+		return
+	}
+	r := m.rows[m.cursor]
+	switch {
+	case r.line < m.viewport.YOffset:
+		m.viewport.SetYOffset(r.line)
+	case r.line+r.lines > m.viewport.YOffset+m.viewport.Height:
+		m.viewport.SetYOffset(r.line + r.lines - m.viewport.Height)
+	}
+}
+
+func (m model) View() string {
+	if !m.ready {
+		return "\n  Loading…"
+	}
+	title := titleStyle.Render(fmt.Sprintf("Thread · %d casts", m.root.Count()))
+	help := mutedStyle.Render("↑/k up • ↓/j down • enter/space expand or collapse • q quit")
+	return fmt.Sprintf("%s\n\n%s\n\n%s", title, m.viewport.View(), help)
+}
+
+// Show loads the thread around the cast fid/hash and opens it in a viewer,
+// or writes it as JSON or Markdown instead
+func Show(fid uint64, hash []byte, format string) error {
+	usernames := feed.NewUsernames()
+	root, err := Load(fid, hash, usernames)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		return WriteJSON(os.Stdout, root)
+	case "markdown":
+		return WriteMarkdown(os.Stdout, root)
+	}
+
+	_, err = tea.NewProgram(newModel(root, hash), tea.WithAltScreen()).Run()
+	return err
+}
+
+// ShowCast parses the arguments of mast show and calls Show
+func ShowCast(hashValue string, user string, format string) error {
+	hash, err := ParseHash(hashValue)
+	if err != nil {
+		return err
+	}
+	if user == "" {
+		return fmt.Errorf("--fid is required, hubs look casts up by author and hash")
+	}
+	fid, err := hub.ParseUser(user)
+	if err != nil {
+		return err
+	}
+	return Show(fid, hash, format)
+}
@@ -0,0 +1,186 @@
+package thread
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mast/feed"
+	"mast/hub"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxAncestors and maxCasts keep runaway threads from turning into
+	// thousands of hub requests
+	maxAncestors = 100
+	maxCasts     = 1000
+)
+
+// Node is a cast together with the replies to it, oldest first
+type Node struct {
+	Cast      feed.Cast
+	Replies   []*Node
+	Collapsed bool
+}
+
+// ParseHash accepts a cast hash with or without its 0x prefix
+func ParseHash(value string) ([]byte, error) {
+	hash, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(value), "0x"))
+	if err != nil || len(hash) != 20 {
+		return nil, fmt.Errorf("Invalid cast hash %q, expected 20 bytes of hex", value)
+	}
+	return hash, nil
+}
+
+func castParams(fid uint64, hash []byte) url.Values {
+	return url.Values{
+		"fid":  {strconv.FormatUint(fid, 10)},
+		"hash": {"0x" + hex.EncodeToString(hash)},
+	}
+}
+
+func fetchCast(fid uint64, hash []byte, usernames *feed.Usernames) (feed.Cast, error) {
+	msg, err := hub.GetMessage("/v1/castById", castParams(fid, hash))
+	if hub.IsNotFound(err) {
+		return feed.Cast{}, fmt.Errorf("Cast 0x%x by fid %d was not found on the hub", hash, fid)
+	}
+	if err != nil {
+		return feed.Cast{}, err
+	}
+	c, ok := feed.FromMessage(msg)
+	if !ok {
+		return feed.Cast{}, fmt.Errorf("Message 0x%x is not a cast", hash)
+	}
+	usernames.Fill(&c)
+	return c, nil
+}
+
+// Load fetches the cast fid/hash, walks up its parents to the root of the
+// thread and then fetches every reply below the root. Parents the hub no
+// longer has end the walk early.
+func Load(fid uint64, hash []byte, usernames *feed.Usernames) (*Node, error) {
+	root, err := fetchCast(fid, hash, usernames)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < maxAncestors && root.ParentFid != 0; i++ {
+		parent, err := fetchCast(root.ParentFid, root.ParentHash, usernames)
+		if err != nil {
+			break
+		}
+		root = parent
+	}
+
+	node := &Node{Cast: root}
+	count := 1
+	if err := loadReplies(node, usernames, &count); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func loadReplies(node *Node, usernames *feed.Usernames, count *int) error {
+	params := castParams(node.Cast.Fid, node.Cast.Hash)
+	for {
+		page, err := hub.GetMessages("/v1/castsByParent", params)
+		if err != nil {
+			return err
+		}
+		for _, msg := range page.Messages {
+			c, ok := feed.FromMessage(msg)
+			if !ok || *count >= maxCasts {
+				continue
+			}
+			usernames.Fill(&c)
+			node.Replies = append(node.Replies, &Node{Cast: c})
+			*count++
+		}
+		if page.NextPageToken == "" || *count >= maxCasts {
+			break
+		}
+		params.Set("pageToken", page.NextPageToken)
+	}
+
+	for _, reply := range node.Replies {
+		if err := loadReplies(reply, usernames, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Count is the number of casts in the tree below and including n
+func (n *Node) Count() int {
+	count := 1
+	for _, reply := range n.Replies {
+		count += reply.Count()
+	}
+	return count
+}
+
+type jsonCast struct {
+	Hash      string      `json:"hash"`
+	Fid       uint64      `json:"fid"`
+	Username  string      `json:"username,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+	Text      string      `json:"text"`
+	Embeds    []string    `json:"embeds,omitempty"`
+	ParentURL string      `json:"parentUrl,omitempty"`
+	Replies   []*jsonCast `json:"replies"`
+}
+
+func toJSON(n *Node) *jsonCast {
+	out := &jsonCast{
+		Hash:      fmt.Sprintf("0x%x", n.Cast.Hash),
+		Fid:       n.Cast.Fid,
+		Username:  n.Cast.Author,
+		Timestamp: n.Cast.Timestamp.UTC(),
+		Text:      n.Cast.Text,
+		Embeds:    n.Cast.Embeds,
+		ParentURL: n.Cast.ParentURL,
+		Replies:   []*jsonCast{},
+	}
+	for _, reply := range n.Replies {
+		out.Replies = append(out.Replies, toJSON(reply))
+	}
+	return out
+}
+
+// WriteJSON writes the tree as nested JSON objects
+func WriteJSON(w io.Writer, root *Node) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(toJSON(root))
+}
+
+// WriteMarkdown writes the tree as a nested Markdown list
+func WriteMarkdown(w io.Writer, root *Node) error {
+	var b strings.Builder
+	writeMarkdownNode(&b, root, 0)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeMarkdownNode(b *strings.Builder, n *Node, depth int) {
+	indent := strings.Repeat("  ", depth)
+	// Lines end in two spaces so Markdown keeps the line breaks
+	fmt.Fprintf(b, "%s- **%s** · %s · `0x%x`  \n", indent, n.Cast.AuthorName(), n.Cast.Timestamp.UTC().Format("2006-01-02 15:04 UTC"), n.Cast.Hash)
+	for _, line := range strings.Split(n.Cast.Text, "\n") {
+		if strings.TrimSpace(line) != "" {
+			fmt.Fprintf(b, "%s  %s  \n", indent, line)
+		}
+	}
+	for _, embed := range n.Cast.Embeds {
+		if strings.Contains(embed, "://") {
+			embed = "<" + embed + ">"
+		}
+		fmt.Fprintf(b, "%s  %s  \n", indent, embed)
+	}
+	for _, reply := range n.Replies {
+		writeMarkdownNode(b, reply, depth+1)
+	}
+}
@@ -0,0 +1,118 @@
+package thread
+
+import (
+	"bytes"
+	"encoding/json"
+	"mast/devhub"
+	"mast/feed"
+	"mast/hub"
+	"mast/message"
+	"mast/protobufs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+const testPrivateKey = "0707070707070707070707070707070707070707070707070707070707070707"
+
+func startHub(t *testing.T) string {
+	t.Helper()
+	handler, err := devhub.NewHandler(devhub.Options{Network: protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	t.Setenv("HOME", t.TempDir())
+	if err := hub.SaveHubPreference(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	return server.URL
+}
+
+func cast(t *testing.T, hubURL string, fid uint64, ts uint32, text string, parent *protobufs.Message) *protobufs.Message {
+	t.Helper()
+	body := &protobufs.CastAddBody{Text: text}
+	if parent != nil {
+		body.Parent = &protobufs.CastAddBody_ParentCastId{ParentCastId: &protobufs.CastId{Fid: parent.Data.Fid, Hash: parent.Hash}}
+	}
+	msgData := &protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_CAST_ADD,
+		Fid:       fid,
+		Timestamp: ts,
+		Network:   protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET,
+		Body:      &protobufs.MessageData_CastAddBody{CastAddBody: body},
+	}
+	msg, err := message.Sign(msgData, testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgBytes, _ := proto.Marshal(msg)
+	resp, err := http.Post(hubURL+"/v1/submitMessage", "application/octet-stream", bytes.NewReader(msgBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("submit %q returned %d", text, resp.StatusCode)
+	}
+	msg.Data = msgData
+	return msg
+}
+
+func TestLoadWalksToRootAndFetchesReplies(t *testing.T) {
+	hubURL := startHub(t)
+	now := message.Timestamp(time.Now())
+
+	root := cast(t, hubURL, 1, now-50, "root", nil)
+	first := cast(t, hubURL, 2, now-40, "first reply", root)
+	cast(t, hubURL, 3, now-30, "second reply", root)
+	nested := cast(t, hubURL, 1, now-20, "nested reply", first)
+
+	tree, err := Load(nested.Data.Fid, nested.Hash, feed.NewUsernames())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Cast.Text != "root" || tree.Count() != 4 {
+		t.Fatalf("root %q with %d casts", tree.Cast.Text, tree.Count())
+	}
+	if len(tree.Replies) != 2 || tree.Replies[0].Cast.Text != "first reply" || tree.Replies[0].Replies[0].Cast.Text != "nested reply" {
+		t.Errorf("unexpected tree shape")
+	}
+
+	var out bytes.Buffer
+	if err := WriteJSON(&out, tree); err != nil {
+		t.Fatal(err)
+	}
+	var decoded jsonCast
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || len(decoded.Replies) != 2 || decoded.Replies[0].Replies[0].Fid != 1 {
+		t.Errorf("json = %s, %v", out.String(), err)
+	}
+
+	out.Reset()
+	if err := WriteMarkdown(&out, tree); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	if !strings.HasPrefix(lines[0], "- **fid:1**") || !strings.HasPrefix(lines[4], "    - **fid:1**") || lines[5] != "      nested reply  " {
+		t.Errorf("markdown =\n%s", out.String())
+	}
+
+	if _, err := Load(1, bytes.Repeat([]byte{9}, 20), feed.NewUsernames()); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("missing cast error = %v", err)
+	}
+}
+
+func TestParseHash(t *testing.T) {
+	if _, err := ParseHash("0x" + strings.Repeat("ab", 20)); err != nil {
+		t.Error(err)
+	}
+	if _, err := ParseHash("0xabcd"); err == nil {
+		t.Error("short hashes should be rejected")
+	}
+}
@@ -0,0 +1,114 @@
+package upload
+
+import (
+	"fmt"
+	"mast/output"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	okStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#7C65C1"))
+	mutedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#767676"))
+)
+
+// Configure saves config as the uploader used for attachments
+func Configure(config Config) error {
+	if err := SaveConfig(config); err != nil {
+		return err
+	}
+	if output.IsJSON() {
+		return output.PrintSetting("uploader", config.masked(), true, "")
+	}
+	fmt.Println(okStyle.Render(fmt.Sprintf("Saved the %s uploader, attach files with mast new --attach", config.Type)))
+	return nil
+}
+
+// ShowConfig prints the saved uploader with its secrets masked
+func ShowConfig() error {
+	config, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	if output.IsJSON() {
+		return output.PrintSetting("uploader", config.masked(), false, "")
+	}
+	rows := [][2]string{
+		{"Type", config.Type},
+		{"URL", config.URL},
+		{"Endpoint", config.Endpoint},
+		{"Gateway", config.Gateway},
+		{"Bucket", config.Bucket},
+		{"Region", config.Region},
+		{"Access key", config.AccessKey},
+		{"Secret key", mask(config.SecretKey)},
+		{"Token", mask(config.Token)},
+		{"Public URL", config.PublicURL},
+	}
+	for name := range config.Headers {
+		rows = append(rows, [2]string{"Header", name + ": " + mask(config.Headers[name])})
+	}
+	for _, row := range rows {
+		if row[1] != "" {
+			fmt.Printf("%s %s\n", mutedStyle.Render(fmt.Sprintf("%-11s", row[0])), row[1])
+		}
+	}
+	return nil
+}
+
+// masked is config with its secrets masked for printing
+func (c Config) masked() Config {
+	c.SecretKey = mask(c.SecretKey)
+	c.Token = mask(c.Token)
+	if len(c.Headers) > 0 {
+		headers := map[string]string{}
+		for name, value := range c.Headers {
+			headers[name] = mask(value)
+		}
+		c.Headers = headers
+	}
+	return c
+}
+
+func mask(secret string) string {
+	if len(secret) <= 4 {
+		return strings.Repeat("*", len(secret))
+	}
+	return strings.Repeat("*", 8) + secret[len(secret)-4:]
+}
+
+// Uploaded is what mast upload prints for each file under --output json
+type Uploaded struct {
+	Path string `json:"path"`
+	URL  string `json:"url"`
+}
+
+// Files uploads each path with the saved uploader and prints its URL
+func Files(paths []string) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("At least one file is required")
+	}
+	config, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	uploader, err := New(config)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		fileURL, err := UploadFile(uploader, path)
+		if err != nil {
+			return err
+		}
+		if output.IsJSON() {
+			if err := output.Print(Uploaded{Path: path, URL: fileURL}); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Println(fileURL)
+	}
+	return nil
+}
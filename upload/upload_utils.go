@@ -0,0 +1,440 @@
+package upload
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	TypeIPFS = "ipfs"
+	TypeS3   = "s3"
+	TypeHTTP = "http"
+
+	DefaultIPFSEndpoint = "https://api.pinata.cloud/pinning/pinFileToIPFS"
+	DefaultIPFSGateway  = "https://ipfs.io"
+
+	// maxFileBytes is the largest file mast will upload
+	maxFileBytes = 25 << 20
+)
+
+// Uploader stores a file somewhere it can be fetched from and returns its
+// public URL, which becomes a cast embed
+type Uploader interface {
+	Upload(name string, contentType string, data []byte) (string, error)
+}
+
+// Config is the uploader saved in ~/.fc-cast-uploader.json. Which fields
+// apply depends on Type.
+type Config struct {
+	Type string `json:"type"`
+
+	// URL is where files are PUT for the http uploader, with the file name
+	// appended
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Endpoint is the pinning API for ipfs or the S3 API for s3
+	Endpoint string `json:"endpoint,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Gateway  string `json:"gateway,omitempty"`
+
+	Bucket    string `json:"bucket,omitempty"`
+	Region    string `json:"region,omitempty"`
+	AccessKey string `json:"accessKey,omitempty"`
+	SecretKey string `json:"secretKey,omitempty"`
+	PathStyle bool   `json:"pathStyle,omitempty"`
+
+	// PublicURL is the base uploaded files are served from when it differs
+	// from where they are uploaded to, such as a CDN in front of a bucket
+	PublicURL string `json:"publicUrl,omitempty"`
+}
+
+func configPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".fc-cast-uploader.json"), nil
+}
+
+// LoadConfig reads the saved uploader
+func LoadConfig() (Config, error) {
+	path, err := configPath()
+	if err != nil {
+		return Config{}, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Config{}, fmt.Errorf("No uploader configured, set one up with mast uploader")
+	}
+	if err != nil {
+		return Config{}, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("Failed to read %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// SaveConfig checks config and saves it, readable only by the user since it
+// holds credentials
+func SaveConfig(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Validate reports the first missing field config's type needs
+func (c Config) Validate() error {
+	switch c.Type {
+	case TypeHTTP:
+		if !isHTTPURL(c.URL) {
+			return fmt.Errorf("The http uploader needs --url, the http(s) URL files are PUT under")
+		}
+	case TypeIPFS:
+		if c.Token == "" {
+			return fmt.Errorf("The ipfs uploader needs --token for the pinning API")
+		}
+	case TypeS3:
+		if !isHTTPURL(c.Endpoint) || c.Bucket == "" || c.AccessKey == "" || c.SecretKey == "" {
+			return fmt.Errorf("The s3 uploader needs --endpoint, --bucket, --access-key and --secret-key")
+		}
+	default:
+		return fmt.Errorf("Unknown uploader type %q, expected %s, %s or %s", c.Type, TypeIPFS, TypeS3, TypeHTTP)
+	}
+	if c.PublicURL != "" && !isHTTPURL(c.PublicURL) {
+		return fmt.Errorf("--public-url must be an http(s) URL")
+	}
+	return nil
+}
+
+func isHTTPURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+// New returns the uploader config describes
+func New(config Config) (Uploader, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: 2 * time.Minute}
+	switch config.Type {
+	case TypeHTTP:
+		return &httpUploader{config: config, client: client}, nil
+	case TypeIPFS:
+		return &ipfsUploader{config: config, client: client}, nil
+	default:
+		return &s3Uploader{config: config, client: client}, nil
+	}
+}
+
+// File uploads the file at path with the saved uploader and returns its URL
+func File(path string) (string, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return "", err
+	}
+	uploader, err := New(config)
+	if err != nil {
+		return "", err
+	}
+	return UploadFile(uploader, path)
+}
+
+// UploadFile reads path and stores it with uploader under a name derived
+// from its contents, so uploading the same file twice gives the same URL
+func UploadFile(uploader Uploader, path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > maxFileBytes {
+		return "", fmt.Errorf("%s is %d MB, mast uploads files up to %d MB", path, info.Size()>>20, maxFileBytes>>20)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	fileURL, err := uploader.Upload(ObjectName(path, data), ContentType(path, data), data)
+	if err != nil {
+		return "", fmt.Errorf("Failed to upload %s: %v", filepath.Base(path), err)
+	}
+	return fileURL, nil
+}
+
+// ObjectName names an upload after a hash of its contents, keeping the
+// extension so servers pick the right content type
+func ObjectName(path string, data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8]) + strings.ToLower(filepath.Ext(path))
+}
+
+// ContentType guesses the MIME type from the extension, then the contents
+func ContentType(path string, data []byte) string {
+	if contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path))); contentType != "" {
+		return contentType
+	}
+	return http.DetectContentType(data)
+}
+
+// publicURL joins the configured public base with name, or returns
+// fallback when there is none
+func (c Config) publicURL(name string, fallback string) string {
+	if c.PublicURL == "" {
+		return fallback
+	}
+	return strings.TrimSuffix(c.PublicURL, "/") + "/" + url.PathEscape(name)
+}
+
+func checkResponse(resp *http.Response) ([]byte, error) {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail := strings.TrimSpace(string(body))
+		if len(detail) > 200 {
+			detail = detail[:200]
+		}
+		return nil, fmt.Errorf("HTTP status %d: %s", resp.StatusCode, detail)
+	}
+	return body, nil
+}
+
+// httpUploader PUTs files under a base URL, such as a WebDAV share or the
+// mock hub's /uploads/
+type httpUploader struct {
+	config Config
+	client *http.Client
+}
+
+func (u *httpUploader) Upload(name string, contentType string, data []byte) (string, error) {
+	target := strings.TrimSuffix(u.config.URL, "/") + "/" + url.PathEscape(name)
+	req, err := http.NewRequest(http.MethodPut, target, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range u.config.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if _, err := checkResponse(resp); err != nil {
+		return "", err
+	}
+	return u.config.publicURL(name, target), nil
+}
+
+// ipfsUploader pins files through a pinning API that takes a multipart
+// "file" field, such as Pinata's pinFileToIPFS or a Kubo node's
+// /api/v0/add, and serves them from a gateway
+type ipfsUploader struct {
+	config Config
+	client *http.Client
+}
+
+func (u *ipfsUploader) Upload(name string, contentType string, data []byte) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		return "", err
+	}
+	part.Write(data)
+	form.Close()
+
+	endpoint := u.config.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultIPFSEndpoint
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+u.config.Token)
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	respBody, err := checkResponse(resp)
+	if err != nil {
+		return "", err
+	}
+
+	// Pinning services disagree on the field name for the CID
+	var pinned struct {
+		IpfsHash string `json:"IpfsHash"`
+		Hash     string `json:"Hash"`
+		Cid      string `json:"cid"`
+	}
+	if err := json.Unmarshal(respBody, &pinned); err != nil {
+		return "", fmt.Errorf("Failed to decode pinning response: %v", err)
+	}
+	cid := pinned.IpfsHash
+	if cid == "" {
+		cid = pinned.Hash
+	}
+	if cid == "" {
+		cid = pinned.Cid
+	}
+	if cid == "" {
+		return "", fmt.Errorf("The pinning API did not return a CID")
+	}
+
+	gateway := u.config.Gateway
+	if gateway == "" {
+		gateway = DefaultIPFSGateway
+	}
+	return u.config.publicURL(cid, strings.TrimSuffix(gateway, "/")+"/ipfs/"+cid), nil
+}
+
+// s3Uploader PUTs files into a bucket of any S3-compatible store, signing
+// requests with AWS Signature Version 4
+type s3Uploader struct {
+	config Config
+	client *http.Client
+}
+
+func (u *s3Uploader) Upload(name string, contentType string, data []byte) (string, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(u.config.Endpoint, "/"))
+	if err != nil {
+		return "", err
+	}
+	target := *endpoint
+	if u.config.PathStyle {
+		target.Path = endpoint.Path + "/" + u.config.Bucket + "/" + name
+	} else {
+		target.Host = u.config.Bucket + "." + endpoint.Host
+		target.Path = endpoint.Path + "/" + name
+	}
+
+	req, err := http.NewRequest(http.MethodPut, target.String(), bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	region := u.config.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	signV4(req, data, region, u.config.AccessKey, u.config.SecretKey, time.Now())
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if _, err := checkResponse(resp); err != nil {
+		return "", err
+	}
+	return u.config.publicURL(name, target.String()), nil
+}
+
+// signV4 adds an AWS Signature Version 4 Authorization header to req,
+// signing every header already set on it along with the host
+func signV4(req *http.Request, payload []byte, region string, accessKey string, secretKey string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for key, values := range req.Header {
+		headers[strings.ToLower(key)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsEscapePath(req.URL.Path),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+// awsEscapePath percent-encodes everything but unreserved characters and
+// slashes, as SigV4 canonical URIs require
+func awsEscapePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	var b strings.Builder
+	for _, c := range []byte(path) {
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,120 @@
+package whois
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const cardWidth = 64
+
+var (
+	cardStyle  = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("#7C65C1")).Padding(1, 2).Width(cardWidth)
+	nameStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#7C65C1")).Bold(true)
+	mutedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#767676"))
+	labelStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#767676")).Width(15)
+	boldStyle  = lipgloss.NewStyle().Bold(true)
+)
+
+// Card renders p as a bordered profile card
+func Card(p Profile) string {
+	inner := cardWidth - 4
+
+	header := nameStyle.Render(p.DisplayName)
+	if p.DisplayName == "" {
+		header = nameStyle.Render(fmt.Sprintf("fid %d", p.Fid))
+	}
+	if p.Username != "" {
+		header += mutedStyle.Render(" @" + p.Username)
+	}
+	header += mutedStyle.Render(fmt.Sprintf(" · fid %d", p.Fid))
+	lines := []string{header}
+
+	if p.Bio != "" {
+		lines = append(lines, "", lipgloss.NewStyle().Width(inner).Render(p.Bio))
+	}
+	if p.URL != "" {
+		lines = append(lines, "", "🔗 "+p.URL)
+	}
+	lines = append(lines, "", boldStyle.Render(p.Followers.String())+mutedStyle.Render(" followers · ")+boldStyle.Render(p.Following.String())+mutedStyle.Render(" following"))
+
+	var rows []string
+	addRows := func(label string, values []string) {
+		for i, value := range values {
+			if i > 0 {
+				label = ""
+			}
+			rows = append(rows, labelStyle.Render(label)+value)
+		}
+	}
+
+	var names []string
+	for _, proof := range p.UsernameProofs {
+		names = append(names, proof.Name+mutedStyle.Render(" ("+proof.Type+")"))
+	}
+	addRows("Names", names)
+
+	var addresses []string
+	for _, v := range p.Verifications {
+		addresses = append(addresses, shorten(v.Address)+mutedStyle.Render(" ("+v.Protocol+")"))
+	}
+	addRows("Verified", addresses)
+
+	if p.Storage.Units > 0 || len(p.Storage.Limits) > 0 {
+		storage := []string{fmt.Sprintf("%d %s", p.Storage.Units, plural(p.Storage.Units, "unit", "units"))}
+		for _, limit := range p.Storage.Limits {
+			storage = append(storage, fmt.Sprintf("%-16s%s", limit.Name, mutedStyle.Render(fmt.Sprintf("%d / %d", limit.Used, limit.Limit))))
+		}
+		addRows("Storage", storage)
+	}
+	if p.PfpURL != "" {
+		addRows("Avatar", []string{mutedStyle.Render(shortenURL(p.PfpURL, inner-15))})
+	}
+
+	if len(rows) > 0 {
+		lines = append(lines, "")
+		lines = append(lines, rows...)
+	}
+	return cardStyle.Render(strings.Join(lines, "\n"))
+}
+
+func plural(n int, one string, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// shorten abbreviates long addresses to their first and last characters
+func shorten(address string) string {
+	if len(address) <= 16 {
+		return address
+	}
+	return address[:8] + "…" + address[len(address)-6:]
+}
+
+func shortenURL(u string, width int) string {
+	runes := []rune(u)
+	if width < 2 || len(runes) <= width {
+		return u
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// Show looks up user and prints its profile card, or the profile as JSON
+func Show(user string, asJSON bool) error {
+	p, err := Lookup(user)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(p)
+	}
+	fmt.Println(Card(p))
+	return nil
+}
@@ -0,0 +1,300 @@
+package whois
+
+import (
+	"encoding/json"
+	"fmt"
+	"mast/channels"
+	"mast/hub"
+	"mast/message"
+	"mast/protobufs"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// linkPageSize and maxLinkPages bound how many follows are counted, so
+	// accounts with huge followings don't take hundreds of requests
+	linkPageSize = 1000
+	maxLinkPages = 20
+)
+
+// Profile is everything the hub knows about a fid that whois shows
+type Profile struct {
+	Fid            uint64          `json:"fid"`
+	Username       string          `json:"username,omitempty"`
+	DisplayName    string          `json:"displayName,omitempty"`
+	Bio            string          `json:"bio,omitempty"`
+	PfpURL         string          `json:"pfpUrl,omitempty"`
+	URL            string          `json:"url,omitempty"`
+	UsernameProofs []UsernameProof `json:"usernameProofs"`
+	Verifications  []Verification  `json:"verifications"`
+	Followers      Count           `json:"followers"`
+	Following      Count           `json:"following"`
+	Storage        Storage         `json:"storage"`
+}
+
+// UsernameProof is a name the fid owns, as an fname or an ENS name
+type UsernameProof struct {
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Owner     string    `json:"owner"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Verification is an address the fid has proven it controls
+type Verification struct {
+	Address   string    `json:"address"`
+	Protocol  string    `json:"protocol"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Count is a number of links. Capped is set when counting stopped at the
+// page limit and the real number is higher.
+type Count struct {
+	Count  int  `json:"count"`
+	Capped bool `json:"capped,omitempty"`
+}
+
+func (c Count) String() string {
+	s := channels.FormatFollowers(c.Count)
+	if c.Capped {
+		s += "+"
+	}
+	return s
+}
+
+// Storage is the storage the fid has rented and how much of each store it
+// uses
+type Storage struct {
+	Units  int            `json:"units"`
+	Limits []StorageLimit `json:"limits"`
+}
+
+type StorageLimit struct {
+	Name  string `json:"name"`
+	Used  int    `json:"used"`
+	Limit int    `json:"limit"`
+}
+
+// Lookup resolves user, a fid or a username, and loads its profile
+func Lookup(user string) (Profile, error) {
+	fid, err := hub.ParseUser(user)
+	if err != nil {
+		return Profile{}, err
+	}
+	return Load(fid)
+}
+
+// Load fetches the user data, username proofs, verifications, follow counts
+// and storage limits of fid
+func Load(fid uint64) (Profile, error) {
+	p := Profile{Fid: fid}
+	if err := p.loadUserData(); err != nil {
+		return p, err
+	}
+	if err := p.loadUsernameProofs(); err != nil {
+		return p, err
+	}
+	if err := p.loadVerifications(); err != nil {
+		return p, err
+	}
+
+	var err error
+	fidParam := strconv.FormatUint(fid, 10)
+	if p.Followers, err = countLinks("/v1/linksByTargetFid", url.Values{"target_fid": {fidParam}}); err != nil {
+		return p, err
+	}
+	if p.Following, err = countLinks("/v1/linksByFid", url.Values{"fid": {fidParam}}); err != nil {
+		return p, err
+	}
+
+	if err := p.loadStorage(); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+func (p *Profile) loadUserData() error {
+	params := url.Values{"fid": {strconv.FormatUint(p.Fid, 10)}}
+	for {
+		page, err := hub.GetMessages("/v1/userDataByFid", params)
+		if err != nil {
+			return err
+		}
+		// Pages come oldest first, so later values replace earlier ones
+		for _, msg := range page.Messages {
+			body := msg.GetData().GetUserDataBody()
+			switch body.GetType() {
+			case protobufs.UserDataType_USER_DATA_TYPE_USERNAME:
+				p.Username = body.Value
+			case protobufs.UserDataType_USER_DATA_TYPE_DISPLAY:
+				p.DisplayName = body.Value
+			case protobufs.UserDataType_USER_DATA_TYPE_BIO:
+				p.Bio = body.Value
+			case protobufs.UserDataType_USER_DATA_TYPE_PFP:
+				p.PfpURL = body.Value
+			case protobufs.UserDataType_USER_DATA_TYPE_URL:
+				p.URL = body.Value
+			}
+		}
+		if page.NextPageToken == "" {
+			return nil
+		}
+		params.Set("pageToken", page.NextPageToken)
+	}
+}
+
+type proofsResponse struct {
+	Proofs []json.RawMessage `json:"proofs"`
+}
+
+var usernameTypes = map[protobufs.UserNameType]string{
+	protobufs.UserNameType_USERNAME_TYPE_FNAME:  "fname",
+	protobufs.UserNameType_USERNAME_TYPE_ENS_L1: "ens",
+}
+
+func (p *Profile) loadUsernameProofs() error {
+	body, err := hub.Get("/v1/userNameProofsByFid", url.Values{"fid": {strconv.FormatUint(p.Fid, 10)}})
+	if err != nil {
+		return err
+	}
+	var response proofsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("Failed to decode hub response: %v", err)
+	}
+
+	p.UsernameProofs = []UsernameProof{}
+	for _, raw := range response.Proofs {
+		proof := &protobufs.UserNameProof{}
+		if err := hub.DecodeJSON(raw, proof); err != nil {
+			return err
+		}
+		kind, ok := usernameTypes[proof.Type]
+		if !ok {
+			kind = strings.ToLower(strings.TrimPrefix(proof.Type.String(), "USERNAME_TYPE_"))
+		}
+		p.UsernameProofs = append(p.UsernameProofs, UsernameProof{
+			Name:      string(proof.Name),
+			Type:      kind,
+			Owner:     fmt.Sprintf("0x%x", proof.Owner),
+			Timestamp: time.Unix(int64(proof.Timestamp), 0).UTC(),
+		})
+	}
+	sort.SliceStable(p.UsernameProofs, func(i, j int) bool { return p.UsernameProofs[i].Type < p.UsernameProofs[j].Type })
+	return nil
+}
+
+// verificationsResponse is decoded by hand because hubs name the body
+// verificationAddAddressBody since Solana support was added, which the
+// generated protobufs still call verificationAddEthAddressBody
+type verificationsResponse struct {
+	Messages []struct {
+		Data struct {
+			Timestamp   uint32            `json:"timestamp"`
+			AddressBody *verificationBody `json:"verificationAddAddressBody"`
+			EthBody     *verificationBody `json:"verificationAddEthAddressBody"`
+		} `json:"data"`
+	} `json:"messages"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+type verificationBody struct {
+	Address  string `json:"address"`
+	Protocol string `json:"protocol"`
+}
+
+func (p *Profile) loadVerifications() error {
+	params := url.Values{"fid": {strconv.FormatUint(p.Fid, 10)}}
+	p.Verifications = []Verification{}
+	for {
+		body, err := hub.Get("/v1/verificationsByFid", params)
+		if err != nil {
+			return err
+		}
+		var response verificationsResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			return fmt.Errorf("Failed to decode hub response: %v", err)
+		}
+
+		for _, msg := range response.Messages {
+			v := msg.Data.AddressBody
+			if v == nil {
+				v = msg.Data.EthBody
+			}
+			if v == nil || v.Address == "" {
+				continue
+			}
+			protocol := strings.ToLower(strings.TrimPrefix(v.Protocol, "PROTOCOL_"))
+			if protocol == "" {
+				protocol = "ethereum"
+			}
+			p.Verifications = append(p.Verifications, Verification{
+				Address:   v.Address,
+				Protocol:  protocol,
+				Timestamp: message.FromTimestamp(msg.Data.Timestamp).UTC(),
+			})
+		}
+		if response.NextPageToken == "" {
+			return nil
+		}
+		params.Set("pageToken", response.NextPageToken)
+	}
+}
+
+// countLinks counts the follow links returned by a links endpoint
+func countLinks(path string, params url.Values) (Count, error) {
+	params.Set("link_type", "follow")
+	params.Set("pageSize", strconv.Itoa(linkPageSize))
+	var count Count
+	for i := 0; i < maxLinkPages; i++ {
+		page, err := hub.GetMessages(path, params)
+		if err != nil {
+			return count, err
+		}
+		count.Count += len(page.Messages)
+		if page.NextPageToken == "" {
+			return count, nil
+		}
+		params.Set("pageToken", page.NextPageToken)
+	}
+	count.Capped = true
+	return count, nil
+}
+
+type storageResponse struct {
+	Limits []struct {
+		StoreType string `json:"storeType"`
+		Name      string `json:"name"`
+		Limit     int    `json:"limit"`
+		Used      int    `json:"used"`
+	} `json:"limits"`
+	Units int `json:"units"`
+}
+
+func (p *Profile) loadStorage() error {
+	body, err := hub.Get("/v1/storageLimitsByFid", url.Values{"fid": {strconv.FormatUint(p.Fid, 10)}})
+	if err != nil {
+		return err
+	}
+	var response storageResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("Failed to decode hub response: %v", err)
+	}
+
+	p.Storage = Storage{Units: response.Units, Limits: []StorageLimit{}}
+	for _, limit := range response.Limits {
+		name := limit.Name
+		if name == "" {
+			name = strings.TrimPrefix(limit.StoreType, "STORE_TYPE_")
+		}
+		p.Storage.Limits = append(p.Storage.Limits, StorageLimit{
+			Name:  strings.ReplaceAll(strings.ToLower(name), "_", " "),
+			Used:  limit.Used,
+			Limit: limit.Limit,
+		})
+	}
+	return nil
+}
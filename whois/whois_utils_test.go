@@ -0,0 +1,165 @@
+package whois
+
+import (
+	"bytes"
+	"encoding/json"
+	"mast/devhub"
+	"mast/hub"
+	"mast/message"
+	"mast/protobufs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+const testPrivateKey = "0707070707070707070707070707070707070707070707070707070707070707"
+
+func startHub(t *testing.T) string {
+	t.Helper()
+	handler, err := devhub.NewHandler(devhub.Options{Network: protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	t.Setenv("HOME", t.TempDir())
+	if err := hub.SaveHubPreference(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	return server.URL
+}
+
+func submit(t *testing.T, hubURL string, msgData *protobufs.MessageData) {
+	t.Helper()
+	msgData.Network = protobufs.FarcasterNetwork_FARCASTER_NETWORK_DEVNET
+	msg, err := message.Sign(msgData, testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgBytes, _ := proto.Marshal(msg)
+	resp, err := http.Post(hubURL+"/v1/submitMessage", "application/octet-stream", bytes.NewReader(msgBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("submit returned %d", resp.StatusCode)
+	}
+}
+
+func userData(fid uint64, ts uint32, kind protobufs.UserDataType, value string) *protobufs.MessageData {
+	return &protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_USER_DATA_ADD,
+		Fid:       fid,
+		Timestamp: ts,
+		Body:      &protobufs.MessageData_UserDataBody{UserDataBody: &protobufs.UserDataBody{Type: kind, Value: value}},
+	}
+}
+
+func follow(fid uint64, ts uint32, target uint64) *protobufs.MessageData {
+	return &protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_LINK_ADD,
+		Fid:       fid,
+		Timestamp: ts,
+		Body: &protobufs.MessageData_LinkBody{LinkBody: &protobufs.LinkBody{
+			Type:   "follow",
+			Target: &protobufs.LinkBody_TargetFid{TargetFid: target},
+		}},
+	}
+}
+
+func TestLoadCombinesProfileData(t *testing.T) {
+	hubURL := startHub(t)
+	now := message.Timestamp(time.Now())
+	const fid = 6596
+
+	submit(t, hubURL, userData(fid, now-100, protobufs.UserDataType_USER_DATA_TYPE_DISPLAY, "Old Name"))
+	submit(t, hubURL, userData(fid, now-90, protobufs.UserDataType_USER_DATA_TYPE_DISPLAY, "Steve"))
+	submit(t, hubURL, userData(fid, now-90, protobufs.UserDataType_USER_DATA_TYPE_USERNAME, "stevedylandev"))
+	submit(t, hubURL, userData(fid, now-90, protobufs.UserDataType_USER_DATA_TYPE_BIO, "Building mast"))
+	submit(t, hubURL, userData(fid, now-90, protobufs.UserDataType_USER_DATA_TYPE_URL, "https://stevedylan.dev"))
+
+	owner := bytes.Repeat([]byte{0xab}, 20)
+	submit(t, hubURL, &protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_USERNAME_PROOF,
+		Fid:       fid,
+		Timestamp: now - 80,
+		Body: &protobufs.MessageData_UsernameProofBody{UsernameProofBody: &protobufs.UserNameProof{
+			Timestamp: 1700000000,
+			Name:      []byte("stevedylandev"),
+			Owner:     owner,
+			Signature: bytes.Repeat([]byte{1}, 65),
+			Fid:       fid,
+			Type:      protobufs.UserNameType_USERNAME_TYPE_FNAME,
+		}},
+	})
+	submit(t, hubURL, &protobufs.MessageData{
+		Type:      protobufs.MessageType_MESSAGE_TYPE_VERIFICATION_ADD_ETH_ADDRESS,
+		Fid:       fid,
+		Timestamp: now - 70,
+		Body: &protobufs.MessageData_VerificationAddEthAddressBody{VerificationAddEthAddressBody: &protobufs.VerificationAddEthAddressBody{
+			Address:      owner,
+			EthSignature: bytes.Repeat([]byte{2}, 65),
+			BlockHash:    bytes.Repeat([]byte{3}, 32),
+		}},
+	})
+
+	for _, follower := range []uint64{3, 4, 5} {
+		submit(t, hubURL, follow(follower, now-60, fid))
+	}
+	submit(t, hubURL, follow(fid, now-50, 3))
+
+	p, err := Lookup("6596")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.DisplayName != "Steve" || p.Username != "stevedylandev" || p.Bio != "Building mast" || p.URL != "https://stevedylan.dev" {
+		t.Errorf("user data = %+v", p)
+	}
+	if len(p.UsernameProofs) != 1 || p.UsernameProofs[0].Name != "stevedylandev" || p.UsernameProofs[0].Type != "fname" {
+		t.Errorf("username proofs = %+v", p.UsernameProofs)
+	}
+	if len(p.Verifications) != 1 || p.Verifications[0].Address != "0x"+strings.Repeat("ab", 20) || p.Verifications[0].Protocol != "ethereum" {
+		t.Errorf("verifications = %+v", p.Verifications)
+	}
+	if p.Followers != (Count{Count: 3}) || p.Following != (Count{Count: 1}) {
+		t.Errorf("followers %+v, following %+v", p.Followers, p.Following)
+	}
+	if p.Storage.Units != 1 || len(p.Storage.Limits) == 0 || p.Storage.Limits[0].Name != "casts" {
+		t.Errorf("storage = %+v", p.Storage)
+	}
+
+	card := Card(p)
+	for _, want := range []string{"Steve", "@stevedylandev", "fid 6596", "3", "followers", "0xababab", "(fname)", "1 unit"} {
+		if !strings.Contains(card, want) {
+			t.Errorf("card is missing %q:\n%s", want, card)
+		}
+	}
+
+	out, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `"followers":{"count":3}`) {
+		t.Errorf("json = %s", out)
+	}
+}
+
+func TestCountString(t *testing.T) {
+	cases := map[Count]string{
+		{Count: 42}:                  "42",
+		{Count: 12300}:               "12.3k",
+		{Count: 20000, Capped: true}: "20k+",
+	}
+	for count, want := range cases {
+		if got := count.String(); got != want {
+			t.Errorf("%+v.String() = %q, want %q", count, got, want)
+		}
+	}
+}